@@ -0,0 +1,85 @@
+// Command import loads users, categories, tickets or comments from another
+// helpdesk's CSV or JSON export.
+//
+//	go run ./cmd/import -entity users -file users.csv -mapping mapping.json -dry-run
+//
+// Import users and categories before tickets, and tickets before comments,
+// so references resolve. Re-running an import updates what it created.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"quickdesk-backend/internal/config"
+	"quickdesk-backend/internal/importer"
+	"quickdesk-backend/pkg/database"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	entity := flag.String("entity", "", "users, categories, tickets or comments")
+	file := flag.String("file", "", "CSV or JSON file to import")
+	format := flag.String("format", "", "csv or json (default from the file extension)")
+	mappingFile := flag.String("mapping", "", "JSON file mapping target fields to source columns")
+	source := flag.String("source", importer.DefaultSource, "name of the system the external IDs come from")
+	dryRun := flag.Bool("dry-run", false, "validate every row without saving")
+	flag.Parse()
+
+	if *entity == "" || *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	cfg := config.Load()
+
+	db, err := database.Initialize(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+
+	opts := importer.Options{
+		Entity: *entity,
+		Format: *format,
+		Source: *source,
+		DryRun: *dryRun,
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	if *mappingFile != "" {
+		raw, err := os.ReadFile(*mappingFile)
+		if err != nil {
+			log.Fatal("Failed to read mapping:", err)
+		}
+		if err := json.Unmarshal(raw, &opts.Mapping); err != nil {
+			log.Fatal("Invalid mapping:", err)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal("Failed to open file:", err)
+	}
+	defer f.Close()
+
+	result, err := importer.Run(db, f, opts)
+	if err != nil {
+		log.Fatal("Import failed:", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(result)
+
+	if result.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+// Command webhook-receiver is a local endpoint for trying out webhooks. It
+// checks each delivery's signature and prints its headers and payload.
+//
+//	go run ./cmd/webhook-receiver -addr :9000 -secret <webhook secret>
+//
+// Register http://localhost:9000/ as a webhook URL, with the server
+// started with WEBHOOK_ALLOW_PRIVATE=true. With -fail the
+// receiver answers every delivery with a 500, to watch retries and the
+// webhook being disabled.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"quickdesk-backend/internal/webhooks"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", "", "webhook secret for checking signatures; unchecked when empty")
+	fail := flag.Bool("fail", false, "answer every delivery with 500")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		signature := "unchecked"
+		if *secret != "" {
+			signature = "valid"
+			if !webhooks.Verify(*secret, r.Header.Get(webhooks.HeaderTimestamp), r.Header.Get(webhooks.HeaderSignature), body, time.Now()) {
+				signature = "INVALID"
+			}
+		}
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Reset()
+			pretty.Write(body)
+		}
+		fmt.Printf("%s %s delivery %s, signature %s\n%s\n\n",
+			time.Now().Format(time.TimeOnly), r.Header.Get(webhooks.HeaderEvent),
+			r.Header.Get(webhooks.HeaderDelivery), signature, pretty.String())
+
+		switch {
+		case signature == "INVALID":
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+		case *fail:
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+		default:
+			fmt.Fprintln(w, "ok")
+		}
+	})
+
+	log.Printf("Listening for webhooks on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
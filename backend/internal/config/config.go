@@ -12,6 +12,46 @@ type Config struct {
 	SMTPPort    string
 	SMTPUser    string
 	SMTPPass    string
+
+	// Email transport: "smtp", "maildir" for local development or "memory"
+	EmailTransport string
+	EmailFrom      string // Defaults to SMTPUser
+	MaildirPath    string
+	// Support mailbox for replies, tagged per ticket, e.g. support@example.com
+	ReplyToAddress string
+
+	FrontendURL string // Base URL for links in emails
+	// SLA targets in hours per priority, e.g. "urgent=4,high=8,medium=24,low=72"
+	SLAFirstResponseHours string
+	SLAResolutionHours    string
+	// How long before a ticket's due date its assignee is reminded, e.g. "24h"
+	DueReminderLead string
+	// How long before a ticket misses an SLA target it is flagged, e.g. "30m"
+	SLAWarningLead string
+
+	// Inbound email. Mail servers post raw messages with InboundToken, or
+	// the IMAP mailbox is polled when IMAPHost is set.
+	InboundToken         string
+	InboundAutoProvision bool   // Create requester accounts for unknown senders
+	InboundCategory      string // Category ID or name for new tickets; empty picks the oldest
+	InboundAuthServID    string // Trust Authentication-Results from this server; empty skips the check
+	AttachmentDir        string
+	IMAPHost             string
+	IMAPPort             string
+	IMAPTLS              bool
+	IMAPUser             string
+	IMAPPass             string
+	IMAPMailbox          string
+	IMAPPollInterval     string // e.g. "1m"
+
+	EmailWorkers   string // Outbox delivery workers per instance
+	WebhookWorkers string // Webhook delivery workers per instance
+	// Let webhooks reach private addresses such as localhost; for local
+	// testing only
+	WebhookAllowPrivate bool
+
+	// How long in-app notifications are kept, e.g. "720h"
+	NotificationRetention string
 }
 
 func Load() *Config {
@@ -23,6 +63,38 @@ func Load() *Config {
 		SMTPPort:    getEnv("SMTP_PORT", "587"),
 		SMTPUser:    getEnv("SMTP_USER", ""),
 		SMTPPass:    getEnv("SMTP_PASS", ""),
+
+		EmailTransport: getEnv("EMAIL_TRANSPORT", "smtp"),
+		EmailFrom:      getEnv("EMAIL_FROM", ""),
+		MaildirPath:    getEnv("MAILDIR_PATH", "maildir"),
+		ReplyToAddress: getEnv("REPLY_TO_ADDRESS", ""),
+
+		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:5173"),
+
+		SLAFirstResponseHours: getEnv("SLA_FIRST_RESPONSE_HOURS", "urgent=1,high=4,medium=8,low=24"),
+		SLAResolutionHours:    getEnv("SLA_RESOLUTION_HOURS", "urgent=4,high=24,medium=72,low=168"),
+		DueReminderLead:       getEnv("DUE_REMINDER_LEAD", "24h"),
+		SLAWarningLead:        getEnv("SLA_WARNING_LEAD", "30m"),
+
+		InboundToken:         getEnv("INBOUND_TOKEN", ""),
+		InboundAutoProvision: getEnv("INBOUND_AUTO_PROVISION", "false") == "true",
+		InboundCategory:      getEnv("INBOUND_CATEGORY", ""),
+		InboundAuthServID:    getEnv("INBOUND_AUTHSERV_ID", ""),
+		AttachmentDir:        getEnv("ATTACHMENT_DIR", "uploads"),
+		IMAPHost:             getEnv("IMAP_HOST", ""),
+		IMAPPort:             getEnv("IMAP_PORT", "993"),
+		IMAPTLS:              getEnv("IMAP_TLS", "true") == "true",
+		IMAPUser:             getEnv("IMAP_USER", ""),
+		IMAPPass:             getEnv("IMAP_PASS", ""),
+		IMAPMailbox:          getEnv("IMAP_MAILBOX", "INBOX"),
+		IMAPPollInterval:     getEnv("IMAP_POLL_INTERVAL", "1m"),
+
+		EmailWorkers:   getEnv("EMAIL_WORKERS", "2"),
+		WebhookWorkers: getEnv("WEBHOOK_WORKERS", "2"),
+
+		WebhookAllowPrivate: getEnv("WEBHOOK_ALLOW_PRIVATE", "false") == "true",
+
+		NotificationRetention: getEnv("NOTIFICATION_RETENTION", "720h"),
 	}
 }
 
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/internal/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// analyticsCacheTTL bounds how stale cached analytics can be
+const analyticsCacheTTL = 5 * time.Minute
+
+type AnalyticsController struct {
+	db    *gorm.DB
+	cache *reports.Cache
+}
+
+// NewAnalyticsController caches results until the next ticket write
+func NewAnalyticsController(db *gorm.DB, bus *events.Bus) *AnalyticsController {
+	cache := reports.NewCache(analyticsCacheTTL)
+	bus.Subscribe(func(events.Event) { cache.Invalidate() })
+	return &AnalyticsController{db: db, cache: cache}
+}
+
+// GetVolume returns tickets created and resolved per hour, day or week,
+// optionally split by category, priority or status
+func (ac *AnalyticsController) GetVolume(w http.ResponseWriter, r *http.Request) {
+	opts, err := reports.ParseVolumeOptions(r.URL.Query(), time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Agents and admins see the same tickets, so they share cache entries
+	viewer := viewerFromRequest(r)
+	scope := "all"
+	if viewer.Role != models.RoleAgent && viewer.Role != models.RoleAdmin {
+		scope = viewer.UserID.String()
+	}
+	key := fmt.Sprintf("volume|%s|%s|%s|%s|%d|%d", scope, opts.Interval, opts.GroupBy,
+		opts.Location, opts.From.Unix(), opts.To.Unix())
+
+	cached, generation, ok := ac.cache.Get(key)
+	if ok {
+		utils.WriteJSON(w, http.StatusOK, cached)
+		return
+	}
+
+	volume, err := reports.BuildVolume(ac.db, viewer, opts)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute ticket volume")
+		return
+	}
+	ac.cache.Set(key, volume, generation)
+
+	utils.WriteJSON(w, http.StatusOK, volume)
+}
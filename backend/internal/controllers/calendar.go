@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"net/http"
+	"quickdesk-backend/internal/due"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/auth"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CalendarController serves each agent's due tickets as an ICS feed.
+// Calendar clients cannot send a login token, so the feed URL carries a
+// signed token of its own.
+type CalendarController struct {
+	db          *gorm.DB
+	frontendURL string
+}
+
+func NewCalendarController(db *gorm.DB, frontendURL string) *CalendarController {
+	return &CalendarController{db: db, frontendURL: frontendURL}
+}
+
+// GetFeedURL returns the caller's calendar subscription URL
+func (cc *CalendarController) GetFeedURL(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var user models.User
+	if err := cc.db.Select("id", "calendar_version").First(&user, "id = ?", userID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	cc.writeFeedURL(w, r, &user)
+}
+
+// RotateFeedURL revokes the caller's calendar subscription URLs and returns
+// a new one, e.g. after a URL leaked
+func (cc *CalendarController) RotateFeedURL(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	if err := cc.db.Model(&models.User{}).Where("id = ?", userID).
+		UpdateColumn("calendar_version", gorm.Expr("calendar_version + 1")).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to rotate feed URL")
+		return
+	}
+
+	var user models.User
+	if err := cc.db.Select("id", "calendar_version").First(&user, "id = ?", userID).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to rotate feed URL")
+		return
+	}
+	cc.writeFeedURL(w, r, &user)
+}
+
+func (cc *CalendarController) writeFeedURL(w http.ResponseWriter, r *http.Request, user *models.User) {
+	token, err := auth.GenerateCalendarToken(user.ID, user.CalendarVersion)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create feed URL")
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	url := scheme + "://" + r.Host + "/api/calendar/" + token + ".ics"
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{
+		"url":        url,
+		"webcal_url": "webcal" + strings.TrimPrefix(strings.TrimPrefix(url, "https"), "http"),
+	})
+}
+
+// GetFeed serves the ICS feed a token belongs to. Feeds of users who are
+// no longer agents are empty rather than an error, so clients stop
+// showing stale tickets.
+func (cc *CalendarController) GetFeed(w http.ResponseWriter, r *http.Request) {
+	// The token contains dots, so the extension is trimmed here rather
+	// than matched by the route
+	token := strings.TrimSuffix(chi.URLParam(r, "token"), ".ics")
+	userID, err := auth.ValidateCalendarToken(token, func(userID uuid.UUID) (int, error) {
+		var user models.User
+		err := cc.db.Select("calendar_version").First(&user, "id = ?", userID).Error
+		return user.CalendarVersion, err
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var user models.User
+	if err := cc.db.First(&user, "id = ?", userID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var tickets []models.Ticket
+	if user.IsActive && user.Role != models.RoleUser {
+		tickets, err = due.AgentTickets(cc.db, user.ID)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch tickets")
+			return
+		}
+	}
+
+	name := "QuickDesk: " + user.FirstName + " " + user.LastName
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="quickdesk.ics"`)
+	w.Write(due.Calendar(name, tickets, cc.frontendURL, time.Now()))
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"quickdesk-backend/internal/csat"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// CSATController serves the public survey links; the signed token in the
+// URL stands in for a login
+type CSATController struct {
+	db *gorm.DB
+}
+
+func NewCSATController(db *gorm.DB) *CSATController {
+	return &CSATController{db: db}
+}
+
+type CSATRequest struct {
+	Rating  json.RawMessage `json:"rating"` // 1 to 5, "good" or "bad"
+	Comment string          `json:"comment"`
+}
+
+// GetSurvey returns what the survey page shows: the ticket subject and any
+// earlier answer
+func (cc *CSATController) GetSurvey(w http.ResponseWriter, r *http.Request) {
+	survey, ok := cc.findSurvey(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"ticket_id":      survey.TicketID,
+		"ticket_subject": survey.Ticket.Subject,
+		"rating":         survey.Rating,
+		"comment":        survey.Comment,
+		"expires_at":     survey.ExpiresAt,
+	})
+}
+
+// SubmitSurvey records a rating from a one-click link. The rating may come
+// from the body or the link's `rating` parameter.
+func (cc *CSATController) SubmitSurvey(w http.ResponseWriter, r *http.Request) {
+	var req CSATRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	raw := r.URL.Query().Get("rating")
+	if len(req.Rating) > 0 {
+		// Accept both 4 and "4"
+		if unquoted, err := strconv.Unquote(string(req.Rating)); err == nil {
+			raw = unquoted
+		} else {
+			raw = string(req.Rating)
+		}
+	}
+	rating, err := csat.ParseRating(raw)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	survey, ok := cc.findSurvey(w, r)
+	if !ok {
+		return
+	}
+
+	if err := csat.Respond(cc.db, survey, rating, req.Comment); err != nil {
+		if errors.Is(err, csat.ErrExpired) {
+			utils.WriteError(w, http.StatusGone, err.Error())
+			return
+		}
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save response")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Thank you for your feedback",
+		"rating":  rating,
+	})
+}
+
+func (cc *CSATController) findSurvey(w http.ResponseWriter, r *http.Request) (*models.SatisfactionSurvey, bool) {
+	survey, err := csat.Find(cc.db, chi.URLParam(r, "token"))
+	if errors.Is(err, csat.ErrExpired) {
+		utils.WriteError(w, http.StatusGone, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Survey not found")
+		return nil, false
+	}
+	return survey, true
+}
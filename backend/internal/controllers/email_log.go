@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/email"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailLogController lets admins browse the outbox and re-send messages
+type EmailLogController struct {
+	db    *gorm.DB
+	email *email.EmailService
+}
+
+func NewEmailLogController(db *gorm.DB, emailService *email.EmailService) *EmailLogController {
+	return &EmailLogController{db: db, email: emailService}
+}
+
+// GetEmails lists outgoing emails newest first, filtered by `status`,
+// `ticket_id` and `recipient` (substring)
+func (ec *EmailLogController) GetEmails(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	page, _ := strconv.Atoi(params.Get("page"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := ec.db.Model(&models.OutboundEmail{})
+	if status := params.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if ticketID := params.Get("ticket_id"); ticketID != "" {
+		if _, err := uuid.Parse(ticketID); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid ticket_id")
+			return
+		}
+		query = query.Where("ticket_id = ?", ticketID)
+	}
+	if recipient := params.Get("recipient"); recipient != "" {
+		query = query.Where("recipients ILIKE ?", "%"+recipient+"%")
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch emails")
+		return
+	}
+	var emails []models.OutboundEmail
+	if err := query.Omit("message").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&emails).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch emails")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"emails": emails,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
+
+// GetEmail returns one outgoing email with its rendered message
+func (ec *EmailLogController) GetEmail(w http.ResponseWriter, r *http.Request) {
+	var outbound models.OutboundEmail
+	if err := ec.db.First(&outbound, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Email not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"email":   outbound,
+		"message": string(outbound.Message),
+	})
+}
+
+// ResendEmail queues a copy of an email for delivery
+func (ec *EmailLogController) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Email not found")
+		return
+	}
+
+	copied, err := ec.email.Resend(id)
+	if errors.Is(err, email.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Email not found")
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to queue email")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, copied)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/email"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailTemplateController lets admins edit the email templates per locale
+// and preview them
+type EmailTemplateController struct {
+	db    *gorm.DB
+	email *email.EmailService
+}
+
+func NewEmailTemplateController(db *gorm.DB, emailService *email.EmailService) *EmailTemplateController {
+	return &EmailTemplateController{db: db, email: emailService}
+}
+
+type EmailTemplateRequest struct {
+	Subject  *string `json:"subject"`
+	HTMLBody *string `json:"html_body"`
+	TextBody *string `json:"text_body"`
+}
+
+type EmailTemplatePreviewRequest struct {
+	EmailTemplateRequest
+	Locale   string     `json:"locale"`
+	TicketID *uuid.UUID `json:"ticket_id"` // Render against a real ticket instead of a sample
+}
+
+// GetTemplates lists every template with its built-in content and the
+// locales admins have overridden
+func (ec *EmailTemplateController) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	var stored []models.EmailTemplate
+	if err := ec.db.Order("name, locale").Find(&stored).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch templates")
+		return
+	}
+
+	templates := make([]map[string]interface{}, 0, len(email.TemplateNames()))
+	for _, name := range email.TemplateNames() {
+		builtIn, _ := email.DefaultTemplate(name)
+		overrides := []models.EmailTemplate{}
+		for _, t := range stored {
+			if t.Name == name {
+				overrides = append(overrides, t)
+			}
+		}
+		templates = append(templates, map[string]interface{}{
+			"name":      name,
+			"default":   builtIn,
+			"overrides": overrides,
+		})
+	}
+
+	utils.WriteJSON(w, http.StatusOK, templates)
+}
+
+// GetTemplate returns the template a recipient with `locale` would get
+func (ec *EmailTemplateController) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	template, locale, err := ec.email.ResolveTemplate(name, r.URL.Query().Get("locale"))
+	if !ec.checkResolved(w, err) {
+		return
+	}
+
+	var stored int64
+	ec.db.Model(&models.EmailTemplate{}).Where("name = ? AND locale = ?", name, locale).Count(&stored)
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"name":       name,
+		"locale":     locale,
+		"is_default": stored == 0,
+		"template":   template,
+	})
+}
+
+// SaveTemplate creates or replaces a template's override for a locale.
+// Missing parts are taken from the template currently used.
+func (ec *EmailTemplateController) SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var req EmailTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	locale, err := email.CanonicalLocale(chi.URLParam(r, "locale"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	current, _, err := ec.email.ResolveTemplate(name, locale)
+	if !ec.checkResolved(w, err) {
+		return
+	}
+	template := applyEmailTemplateRequest(current, &req)
+	if strings.TrimSpace(template.Subject) == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Subject is required")
+		return
+	}
+	// A template has to render before it can replace the one in use
+	if _, err := email.Render(template, ec.email.SampleData(nil)); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+
+	userID, _ := utils.GetUserIDFromContext(r)
+	stored := models.EmailTemplate{Name: name, Locale: locale}
+	err = ec.db.Where(stored).First(&stored).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save template")
+		return
+	}
+	if stored.ID == uuid.Nil {
+		stored.ID = uuid.New()
+	}
+	stored.Subject, stored.HTMLBody, stored.TextBody = template.Subject, template.HTML, template.Text
+	stored.UpdatedByID = &userID
+	if err := ec.db.Save(&stored).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save template")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, stored)
+}
+
+// DeleteTemplate drops a locale's override, so the locale falls back to
+// its base language or the built-in template
+func (ec *EmailTemplateController) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	locale, err := email.CanonicalLocale(chi.URLParam(r, "locale"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := ec.db.Where("name = ? AND locale = ?", chi.URLParam(r, "name"), locale).Delete(&models.EmailTemplate{})
+	if result.Error != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete template")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.WriteError(w, http.StatusNotFound, "Template not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Template deleted successfully"})
+}
+
+// PreviewTemplate renders the template for `locale` against a sample or
+// real ticket. Parts sent in the body replace the stored ones, so edits can
+// be previewed before saving.
+func (ec *EmailTemplateController) PreviewTemplate(w http.ResponseWriter, r *http.Request) {
+	var req EmailTemplatePreviewRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	current, _, err := ec.email.ResolveTemplate(chi.URLParam(r, "name"), req.Locale)
+	if !ec.checkResolved(w, err) {
+		return
+	}
+
+	var ticket *models.Ticket
+	if req.TicketID != nil {
+		ticket = &models.Ticket{}
+		if err := ec.db.Preload("CreatedBy").First(ticket, "id = ?", *req.TicketID).Error; err != nil {
+			utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+			return
+		}
+	}
+
+	rendered, err := email.Render(applyEmailTemplateRequest(current, &req.EmailTemplateRequest), ec.email.SampleData(ticket))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, rendered)
+}
+
+// checkResolved writes the response for a failed template lookup
+func (ec *EmailTemplateController) checkResolved(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, email.ErrUnknownTemplate):
+		utils.WriteError(w, http.StatusNotFound, "Template not found")
+		return false
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch template")
+		return false
+	}
+	return true
+}
+
+func applyEmailTemplateRequest(template email.Template, req *EmailTemplateRequest) email.Template {
+	if req.Subject != nil {
+		template.Subject = *req.Subject
+	}
+	if req.HTMLBody != nil {
+		template.HTML = *req.HTMLBody
+	}
+	if req.TextBody != nil {
+		template.Text = *req.TextBody
+	}
+	return template
+}
@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/export"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many tickets an export loads at a time
+const exportBatchSize = 500
+
+// ticketColumn is an exportable ticket column. commentCounts holds the
+// comment count of every ticket in the current batch.
+type ticketColumn struct {
+	name  string
+	value func(t *models.Ticket, commentCounts map[uuid.UUID]int64) interface{}
+}
+
+var ticketColumns = []ticketColumn{
+	{"id", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.ID.String() }},
+	{"subject", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.Subject }},
+	{"description", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.Description }},
+	{"status", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return string(t.Status) }},
+	{"priority", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return string(t.Priority) }},
+	{"category", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.Category.Name }},
+	{"created_by", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return fullName(&t.CreatedBy) }},
+	{"created_by_email", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.CreatedBy.Email }},
+	{"assigned_to", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return fullName(t.AssignedTo) }},
+	{"assigned_to_email", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} {
+		if t.AssignedTo == nil {
+			return nil
+		}
+		return t.AssignedTo.Email
+	}},
+	{"tags", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} {
+		names := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			names[i] = tag.Name
+		}
+		return strings.Join(names, ", ")
+	}},
+	{"up_votes", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.UpVotes }},
+	{"down_votes", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.DownVotes }},
+	{"view_count", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.ViewCount }},
+	{"comment_count", func(t *models.Ticket, counts map[uuid.UUID]int64) interface{} { return counts[t.ID] }},
+	{"created_at", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.CreatedAt }},
+	{"updated_at", func(t *models.Ticket, _ map[uuid.UUID]int64) interface{} { return t.UpdatedAt }},
+}
+
+// defaultTicketColumns are exported unless an admin picks others
+var defaultTicketColumns = []string{
+	"id", "subject", "status", "priority", "category", "created_by", "assigned_to", "tags", "created_at", "updated_at",
+}
+
+// exportColumns resolves a comma-separated `columns` parameter, falling
+// back to the default columns when it is empty
+func exportColumns(param string) ([]ticketColumn, error) {
+	names := defaultTicketColumns
+	if param != "" {
+		names = strings.Split(param, ",")
+	}
+
+	columns := make([]ticketColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, column := range ticketColumns {
+			if column.name == name {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown export column %q", name)
+		}
+	}
+	return columns, nil
+}
+
+// ExportTickets streams the tickets matched by the GetTickets filters as
+// CSV, JSON or XLSX. Tickets are loaded in keyset batches so exports of any
+// size use constant memory.
+func (tc *TicketController) ExportTickets(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	viewer := viewerFromRequest(r)
+
+	format := params.Get("format")
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if !export.Supported(format) {
+		utils.WriteError(w, http.StatusBadRequest, "format must be csv, json or xlsx")
+		return
+	}
+
+	// Only admins may choose columns; everyone else gets the defaults
+	if params.Get("columns") != "" && viewer.Role != models.RoleAdmin {
+		utils.WriteError(w, http.StatusForbidden, "Only admins can choose export columns")
+		return
+	}
+	columns, err := exportColumns(params.Get("columns"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sort, err := query.ParseSort(params.Get("sort_by"), params.Get("sort_order"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	db, err := tc.filteredTickets(r)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+	db = db.Session(&gorm.Session{})
+
+	wantCounts := false
+	for _, column := range columns {
+		wantCounts = wantCounts || column.name == "comment_count"
+	}
+
+	// The first batch is loaded before the response starts, so a failing
+	// query still gets a 500
+	tickets, counts, err := tc.exportBatch(sort, db, wantCounts)
+	if err != nil {
+		log.Printf("ticket export: %v", err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to export tickets")
+		return
+	}
+
+	filename := fmt.Sprintf("tickets-%s.%s", time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	writer, _ := export.NewWriter(format, w)
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.name
+	}
+	if err := writer.WriteHeader(names); err != nil {
+		log.Printf("ticket export: %v", err)
+		return
+	}
+
+	// Once the response has started, failures abort it so clients do not
+	// take a truncated export for a complete one
+	for {
+		for i := range tickets {
+			values := make([]interface{}, len(columns))
+			for j, column := range columns {
+				values[j] = column.value(&tickets[i], counts)
+			}
+			if err := writer.WriteRow(values); err != nil {
+				log.Printf("ticket export: %v", err)
+				return
+			}
+		}
+
+		if len(tickets) < exportBatchSize {
+			break
+		}
+		cursor, err := sort.Cursor(tc.db, &tickets[len(tickets)-1])
+		if err != nil {
+			log.Printf("ticket export: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+		page, err := sort.After(db, cursor)
+		if err == nil {
+			tickets, counts, err = tc.exportBatch(sort, page, wantCounts)
+		}
+		if err != nil {
+			log.Printf("ticket export: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Printf("ticket export: %v", err)
+	}
+}
+
+// exportBatch loads the next batch of an export and, when asked, the
+// comment counts of its tickets
+func (tc *TicketController) exportBatch(sort query.Sort, page *gorm.DB, wantCounts bool) ([]models.Ticket, map[uuid.UUID]int64, error) {
+	var tickets []models.Ticket
+	if err := sort.Order(page).
+		Preload("CreatedBy").
+		Preload("AssignedTo").
+		Preload("Category").
+		Preload("Tags").
+		Limit(exportBatchSize).
+		Find(&tickets).Error; err != nil {
+		return nil, nil, err
+	}
+	if !wantCounts {
+		return tickets, nil, nil
+	}
+	counts, err := tc.commentCounts(tickets)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tickets, counts, nil
+}
+
+// commentCounts counts the comments of a batch of tickets in one query
+func (tc *TicketController) commentCounts(tickets []models.Ticket) (map[uuid.UUID]int64, error) {
+	ids := make([]uuid.UUID, len(tickets))
+	for i, t := range tickets {
+		ids[i] = t.ID
+	}
+
+	var rows []struct {
+		TicketID uuid.UUID
+		Count    int64
+	}
+	if err := tc.db.Model(&models.Comment{}).
+		Select("ticket_id, COUNT(*) AS count").
+		Where("ticket_id IN ?", ids).
+		Group("ticket_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TicketID] = row.Count
+	}
+	return counts, nil
+}
+
+// ticketBundle is a single ticket with its comment thread
+type ticketBundle struct {
+	Ticket     models.Ticket    `json:"ticket"`
+	Comments   []models.Comment `json:"comments"`
+	ExportedAt time.Time        `json:"exported_at"`
+}
+
+// ExportTicket exports one ticket and its comment thread as a printable
+// HTML page (default) or a JSON bundle. Requesters do not see internal
+// comments.
+func (tc *TicketController) ExportTicket(w http.ResponseWriter, r *http.Request) {
+	ticketID := utils.GetURLParam(r, "id")
+	viewer := viewerFromRequest(r)
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "json" {
+		utils.WriteError(w, http.StatusBadRequest, "format must be html or json")
+		return
+	}
+
+	var bundle ticketBundle
+	if err := tc.db.Preload("CreatedBy").
+		Preload("AssignedTo").
+		Preload("Category").
+		Preload("Tags").
+		Preload("Attachments").
+		First(&bundle.Ticket, "id = ?", ticketID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+		return
+	}
+
+	if viewer.Role == models.RoleUser && bundle.Ticket.CreatedByID != viewer.UserID {
+		utils.WriteError(w, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	comments := tc.db.Preload("User").Where("ticket_id = ?", bundle.Ticket.ID).Order("created_at")
+	if viewer.Role == models.RoleUser {
+		comments = comments.Where("is_internal = ?", false)
+	}
+	if err := comments.Find(&bundle.Comments).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+	bundle.ExportedAt = time.Now()
+
+	filename := fmt.Sprintf("ticket-%s.%s", bundle.Ticket.ID, format)
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		utils.WriteJSON(w, http.StatusOK, bundle)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+	if err := ticketExportTemplate.Execute(w, bundle); err != nil {
+		log.Printf("ticket export: %v", err)
+	}
+}
+
+func fullName(u *models.User) interface{} {
+	if u == nil {
+		return nil
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+var ticketExportTemplate = template.Must(template.New("ticket").Funcs(template.FuncMap{
+	"name": func(u models.User) string { return strings.TrimSpace(u.FirstName + " " + u.LastName) },
+	"date": func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Ticket.Subject}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+  h1 { font-size: 1.5em; margin-bottom: 0.25em; }
+  table.meta { border-collapse: collapse; margin: 1em 0; }
+  table.meta th { text-align: left; padding: 0.2em 1em 0.2em 0; color: #555; font-weight: normal; }
+  .description, .comment .body { white-space: pre-wrap; }
+  .comment { border-top: 1px solid #ddd; padding: 0.75em 0; page-break-inside: avoid; }
+  .comment .author { font-weight: bold; }
+  .comment .when, .footer { color: #777; font-size: 0.9em; }
+  .internal { background: #fff8e1; }
+  @media print { body { margin: 0; max-width: none; } }
+</style>
+</head>
+<body>
+<h1>{{.Ticket.Subject}}</h1>
+<table class="meta">
+  <tr><th>Ticket</th><td>{{.Ticket.ID}}</td></tr>
+  <tr><th>Status</th><td>{{.Ticket.Status}}</td></tr>
+  <tr><th>Priority</th><td>{{.Ticket.Priority}}</td></tr>
+  <tr><th>Category</th><td>{{.Ticket.Category.Name}}</td></tr>
+  <tr><th>Requester</th><td>{{name .Ticket.CreatedBy}} &lt;{{.Ticket.CreatedBy.Email}}&gt;</td></tr>
+  {{with .Ticket.AssignedTo}}<tr><th>Assignee</th><td>{{name .}}</td></tr>{{end}}
+  {{if .Ticket.Tags}}<tr><th>Tags</th><td>{{range $i, $t := .Ticket.Tags}}{{if $i}}, {{end}}{{$t.Name}}{{end}}</td></tr>{{end}}
+  <tr><th>Created</th><td>{{date .Ticket.CreatedAt}}</td></tr>
+  <tr><th>Updated</th><td>{{date .Ticket.UpdatedAt}}</td></tr>
+</table>
+<div class="description">{{.Ticket.Description}}</div>
+<h2>Comments ({{len .Comments}})</h2>
+{{range .Comments}}
+<div class="comment{{if .IsInternal}} internal{{end}}">
+  <div><span class="author">{{name .User}}</span> <span class="when">{{date .CreatedAt}}{{if .IsInternal}} &middot; internal{{end}}</span></div>
+  <div class="body">{{.Content}}</div>
+</div>
+{{else}}
+<p>No comments.</p>
+{{end}}
+<p class="footer">Exported {{date .ExportedAt}}</p>
+</body>
+</html>
+`))
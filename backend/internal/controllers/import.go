@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"quickdesk-backend/internal/importer"
+	"quickdesk-backend/internal/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// maxImportSize caps uploaded import files
+const maxImportSize = 64 << 20
+
+type ImportController struct {
+	db *gorm.DB
+}
+
+func NewImportController(db *gorm.DB) *ImportController {
+	return &ImportController{db: db}
+}
+
+// Import loads users, categories, tickets or comments from CSV or JSON.
+//
+// The data is either a multipart `file` upload or the raw request body.
+// `format` (csv or json) defaults from the file extension or Content-Type,
+// `mapping` is a JSON object of target field to source column, `source`
+// names the system external IDs belong to and `dry_run=true` validates
+// without saving.
+func (ic *ImportController) Import(w http.ResponseWriter, r *http.Request) {
+	entity := utils.GetURLParam(r, "entity")
+	if _, ok := importer.Fields[entity]; !ok {
+		utils.WriteError(w, http.StatusNotFound, "Unknown import entity")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var data io.Reader = r.Body
+	format := r.URL.Query().Get("format")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "file is required")
+			return
+		}
+		defer file.Close()
+		data = file
+
+		if format == "" {
+			format = r.FormValue("format")
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else if format == "" {
+		if strings.Contains(r.Header.Get("Content-Type"), "json") {
+			format = "json"
+		} else {
+			format = "csv"
+		}
+	}
+
+	opts := importer.Options{
+		Entity: entity,
+		Format: format,
+		Source: r.FormValue("source"),
+		DryRun: r.FormValue("dry_run") == "true",
+	}
+	if mapping := r.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "mapping must be a JSON object of field to column")
+			return
+		}
+	}
+
+	result, err := importer.Run(ic.db, data, opts)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if result.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	utils.WriteJSON(w, status, result)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"quickdesk-backend/internal/inbound"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// maxInboundEmail bounds a posted message, attachments included
+const maxInboundEmail = 25 << 20
+
+// InboundController receives raw emails from the mail server and lists
+// what became of them
+type InboundController struct {
+	db        *gorm.DB
+	processor *inbound.Processor
+	token     string
+}
+
+// NewInboundController needs a shared token for the receiving endpoint;
+// with an empty token the endpoint is disabled
+func NewInboundController(db *gorm.DB, processor *inbound.Processor, token string) *InboundController {
+	return &InboundController{db: db, processor: processor, token: token}
+}
+
+// ReceiveEmail processes a raw RFC 5322 message posted by a mail server's
+// pipe or webhook. The X-Inbound-Token header authorizes the call.
+func (ic *InboundController) ReceiveEmail(w http.ResponseWriter, r *http.Request) {
+	if ic.token == "" {
+		utils.WriteError(w, http.StatusNotFound, "Inbound email is not enabled")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Inbound-Token")), []byte(ic.token)) != 1 {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid inbound token")
+		return
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboundEmail))
+	if err != nil {
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, "Message is too large")
+		return
+	}
+
+	record, err := ic.processor.Process(raw)
+	switch {
+	case errors.Is(err, inbound.ErrDuplicate):
+		utils.WriteJSON(w, http.StatusOK, record)
+	case errors.Is(err, inbound.ErrMalformed):
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		// The mail server keeps the message and tries again
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to process message")
+	case record.Status == models.InboundRejected:
+		utils.WriteJSON(w, http.StatusUnprocessableEntity, record)
+	default:
+		utils.WriteJSON(w, http.StatusCreated, record)
+	}
+}
+
+// GetInboundEmails lists received emails newest first, optionally by
+// `status`
+func (ic *InboundController) GetInboundEmails(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	page, _ := strconv.Atoi(params.Get("page"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := ic.db.Model(&models.InboundEmail{})
+	if status := params.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch inbound emails")
+		return
+	}
+	var emails []models.InboundEmail
+	if err := query.Order("received_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&emails).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch inbound emails")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"emails": emails,
+		"total":  total,
+		"page":   page,
+		"limit":  limit,
+	})
+}
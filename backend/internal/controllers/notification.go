@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/realtime"
+	"quickdesk-backend/internal/utils"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NotificationController serves the caller's in-app notification center
+type NotificationController struct {
+	db  *gorm.DB
+	hub *realtime.Hub
+}
+
+func NewNotificationController(db *gorm.DB, hub *realtime.Hub) *NotificationController {
+	return &NotificationController{db: db, hub: hub}
+}
+
+// GetNotifications lists the caller's notifications newest first, only
+// unread ones with `unread=true`, with the unread counts in total and per
+// event
+func (nc *NotificationController) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+	params := r.URL.Query()
+	page, _ := strconv.Atoi(params.Get("page"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := nc.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if params.Get("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+	notifications := []models.Notification{}
+	if err := query.Preload("Ticket").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&notifications).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+	unread, byEvent, err := nc.unreadCounts(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"notifications":   notifications,
+		"unread":          unread,
+		"unread_by_event": byEvent,
+		"total":           total,
+		"page":            page,
+		"limit":           limit,
+	})
+}
+
+// MarkRead marks one of the caller's notifications read
+func (nc *NotificationController) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+	var notification models.Notification
+	if err := nc.db.First(&notification, "id = ? AND user_id = ?", chi.URLParam(r, "id"), userID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := nc.db.Model(&notification).UpdateColumn("read_at", now).Error; err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to update notification")
+			return
+		}
+		notification.ReadAt = &now
+		nc.publishRead(userID, &notification.ID)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, notification)
+}
+
+// MarkAllRead marks all of the caller's notifications read
+func (nc *NotificationController) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+	result := nc.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		UpdateColumn("read_at", time.Now())
+	if result.Error != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update notifications")
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		nc.publishRead(userID, nil)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"marked": result.RowsAffected})
+}
+
+// publishRead tells the user's other open clients to update their unread
+// counts; id is nil when all were marked read
+func (nc *NotificationController) publishRead(userID uuid.UUID, id *uuid.UUID) {
+	err := nc.hub.Publish(realtime.Message{Type: realtime.NotificationRead, UserID: &userID, NotificationID: id})
+	if err != nil {
+		log.Printf("notifications: publishing read for user %s: %v", userID, err)
+	}
+}
+
+// unreadCounts counts a user's unread notifications in total and per event
+func (nc *NotificationController) unreadCounts(userID uuid.UUID) (int64, map[models.NotificationEvent]int64, error) {
+	var rows []struct {
+		Event models.NotificationEvent
+		Count int64
+	}
+	if err := nc.db.Model(&models.Notification{}).
+		Select("event, COUNT(*) AS count").
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Group("event").
+		Scan(&rows).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var total int64
+	byEvent := make(map[models.NotificationEvent]int64, len(rows))
+	for _, row := range rows {
+		byEvent[row.Event] = row.Count
+		total += row.Count
+	}
+	return total, byEvent, nil
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/notify"
+	"quickdesk-backend/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// NotificationPreferenceController lets users choose how they are emailed
+// about each kind of ticket activity
+type NotificationPreferenceController struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceController(db *gorm.DB) *NotificationPreferenceController {
+	return &NotificationPreferenceController{db: db}
+}
+
+// GetPreferences returns the caller's mode for every event
+func (pc *NotificationPreferenceController) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+	modes, err := notify.Preferences(pc.db, userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch notification preferences")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, modes)
+}
+
+// UpdatePreferences sets the caller's modes for the events in the body,
+// e.g. {"commented": "daily", "sla_warning": "off"}, and returns all of
+// them
+func (pc *NotificationPreferenceController) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var req map[models.NotificationEvent]models.NotificationMode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for event, mode := range req {
+		if !event.IsValid() {
+			utils.WriteError(w, http.StatusBadRequest, "Unknown event: "+string(event))
+			return
+		}
+		if !mode.IsValid() {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid mode for "+string(event)+": use immediate, hourly, daily or off")
+			return
+		}
+	}
+
+	userID, _ := utils.GetUserIDFromContext(r)
+	if err := notify.SetPreferences(pc.db, userID, req); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to save notification preferences")
+		return
+	}
+
+	pc.GetPreferences(w, r)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/presence"
+	"quickdesk-backend/internal/utils"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+// PresenceController lets agents see who else has a ticket open and warns
+// them when someone comments while they are drafting
+type PresenceController struct {
+	db      *gorm.DB
+	tracker *presence.Tracker
+}
+
+func NewPresenceController(db *gorm.DB, tracker *presence.Tracker) *PresenceController {
+	return &PresenceController{db: db, tracker: tracker}
+}
+
+type PresenceRequest struct {
+	State models.PresenceState `json:"state"` // viewing or typing
+}
+
+// GetPresence lists the agents with the ticket open
+func (pc *PresenceController) GetPresence(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := pc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := pc.tracker.Present(ticket.ID, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch presence")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"presence": entries})
+}
+
+// Heartbeat records that the caller has the ticket open, viewing or typing.
+// The response lists who is on the ticket and, while the caller is typing,
+// the comments others added since they started their draft.
+func (pc *PresenceController) Heartbeat(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	req := PresenceRequest{State: models.PresenceViewing}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if !req.State.IsValid() {
+		utils.WriteError(w, http.StatusBadRequest, "State must be viewing or typing")
+		return
+	}
+
+	ticket, ok := pc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	p, err := pc.tracker.Heartbeat(ticket, userID, req.State, now)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to record presence")
+		return
+	}
+	entries, err := pc.tracker.Present(ticket.ID, now)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch presence")
+		return
+	}
+	comments, err := pc.tracker.CommentsSince(p)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"presence":     entries,
+		"typing_since": p.TypingSince,
+		"new_comments": comments,
+		"ttl_seconds":  int(presence.TTL.Seconds()),
+	})
+}
+
+// Leave removes the caller's presence on the ticket
+func (pc *PresenceController) Leave(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+	ticket, ok := pc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	if err := pc.tracker.Leave(ticket, userID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to remove presence")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Presence removed"})
+}
+
+func (pc *PresenceController) findTicket(w http.ResponseWriter, r *http.Request) (*models.Ticket, bool) {
+	var ticket models.Ticket
+	if err := pc.db.Select("id", "created_by_id").First(&ticket, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+		return nil, false
+	}
+	return &ticket, true
+}
@@ -0,0 +1,250 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/recurring"
+	"quickdesk-backend/internal/tickets"
+	"quickdesk-backend/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RecurringTicketController lets admins manage ticket templates that are
+// opened on a schedule
+type RecurringTicketController struct {
+	db     *gorm.DB
+	runner *recurring.Runner
+}
+
+func NewRecurringTicketController(db *gorm.DB, runner *recurring.Runner) *RecurringTicketController {
+	return &RecurringTicketController{db: db, runner: runner}
+}
+
+type RecurringTicketRequest struct {
+	Subject      *string                `json:"subject"`
+	Description  *string                `json:"description"`
+	Priority     *models.TicketPriority `json:"priority"`
+	CategoryID   *uuid.UUID             `json:"category_id"`
+	AssignedToID *uuid.UUID             `json:"assigned_to_id"` // uuid.Nil leaves new tickets unassigned
+	Tags         []string               `json:"tags"`
+	DueAfter     *int                   `json:"due_after"` // Minutes
+	Schedule     *string                `json:"schedule"`  // Cron expression, e.g. "0 9 1 * *"
+	Timezone     *string                `json:"timezone"`
+	IsActive     *bool                  `json:"is_active"`
+}
+
+// maxRecurringTickets bounds the created tickets listed for a template
+const maxRecurringTickets = 100
+
+func (rc *RecurringTicketController) GetRecurringTickets(w http.ResponseWriter, r *http.Request) {
+	var templates []models.RecurringTicket
+	if err := rc.db.Preload("Category").Preload("AssignedTo").Preload("CreatedBy").
+		Order("subject").Find(&templates).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch recurring tickets")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, templates)
+}
+
+func (rc *RecurringTicketController) CreateRecurringTicket(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req RecurringTicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template := models.RecurringTicket{
+		ID:          uuid.New(),
+		Priority:    models.PriorityMedium,
+		Timezone:    "UTC",
+		IsActive:    true,
+		CreatedByID: userID,
+	}
+	applyRecurringRequest(&template, &req)
+
+	if !rc.validateRecurring(w, &template, true) {
+		return
+	}
+
+	if err := rc.db.Create(&template).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create recurring ticket")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, template)
+}
+
+func (rc *RecurringTicketController) UpdateRecurringTicket(w http.ResponseWriter, r *http.Request) {
+	var req RecurringTicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var template models.RecurringTicket
+	if err := rc.db.First(&template, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Recurring ticket not found")
+		return
+	}
+	before := template
+	applyRecurringRequest(&template, &req)
+
+	// Other edits keep the next run, so an occurrence that is due but not
+	// yet picked up is not skipped
+	reschedule := template.Schedule != before.Schedule || template.Timezone != before.Timezone ||
+		template.IsActive != before.IsActive
+	if !rc.validateRecurring(w, &template, reschedule) {
+		return
+	}
+	template.LastError = ""
+
+	if err := rc.db.Model(&template).
+		Select("subject", "description", "priority", "category_id", "assigned_to_id", "tags", "due_after",
+			"schedule", "timezone", "is_active", "next_run_at", "last_error").
+		Updates(&template).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update recurring ticket")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, template)
+}
+
+func (rc *RecurringTicketController) DeleteRecurringTicket(w http.ResponseWriter, r *http.Request) {
+	result := rc.db.Delete(&models.RecurringTicket{}, "id = ?", chi.URLParam(r, "id"))
+	if result.Error != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete recurring ticket")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.WriteError(w, http.StatusNotFound, "Recurring ticket not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Recurring ticket deleted successfully"})
+}
+
+// RunRecurringTicket opens a template's ticket now without moving its
+// next run
+func (rc *RecurringTicketController) RunRecurringTicket(w http.ResponseWriter, r *http.Request) {
+	var template models.RecurringTicket
+	if err := rc.db.First(&template, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Recurring ticket not found")
+		return
+	}
+
+	ticket, err := rc.runner.RunNow(&template, time.Now())
+	if errors.Is(err, tickets.ErrInvalidCategory) || errors.Is(err, tickets.ErrInvalidAssignee) {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create ticket")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, ticket)
+}
+
+// GetRecurringTicketHistory lists the tickets a template opened, newest
+// first
+func (rc *RecurringTicketController) GetRecurringTicketHistory(w http.ResponseWriter, r *http.Request) {
+	var created []models.Ticket
+	if err := rc.db.Preload("AssignedTo").Where("recurrence_id = ?", chi.URLParam(r, "id")).
+		Order("recurrence_at DESC").Limit(maxRecurringTickets).Find(&created).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch tickets")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, created)
+}
+
+func applyRecurringRequest(template *models.RecurringTicket, req *RecurringTicketRequest) {
+	if req.Subject != nil {
+		template.Subject = strings.TrimSpace(*req.Subject)
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Priority != nil {
+		template.Priority = *req.Priority
+	}
+	if req.CategoryID != nil {
+		template.CategoryID = *req.CategoryID
+	}
+	if req.AssignedToID != nil {
+		template.AssignedToID = req.AssignedToID
+		if *req.AssignedToID == uuid.Nil {
+			template.AssignedToID = nil
+		}
+	}
+	if req.Tags != nil {
+		template.Tags = strings.Join(req.Tags, ",")
+	}
+	if req.DueAfter != nil {
+		template.DueAfter = *req.DueAfter
+	}
+	if req.Schedule != nil {
+		template.Schedule = *req.Schedule
+	}
+	if req.Timezone != nil {
+		template.Timezone = *req.Timezone
+	}
+	if req.IsActive != nil {
+		template.IsActive = *req.IsActive
+	}
+}
+
+// validateRecurring checks a template, writing a 400 response when it is
+// invalid, and with reschedule computes its next run from now
+func (rc *RecurringTicketController) validateRecurring(w http.ResponseWriter, template *models.RecurringTicket, reschedule bool) bool {
+	if template.Subject == "" || strings.TrimSpace(template.Description) == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Subject and description are required")
+		return false
+	}
+	if !template.Priority.IsValid() {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid priority")
+		return false
+	}
+	if template.DueAfter < 0 {
+		utils.WriteError(w, http.StatusBadRequest, "due_after must not be negative")
+		return false
+	}
+
+	var count int64
+	rc.db.Model(&models.Category{}).Where("id = ?", template.CategoryID).Count(&count)
+	if count == 0 {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid category")
+		return false
+	}
+	if template.AssignedToID != nil {
+		rc.db.Model(&models.User{}).
+			Where("id = ? AND role IN ?", *template.AssignedToID, []models.Role{models.RoleAgent, models.RoleAdmin}).
+			Count(&count)
+		if count == 0 {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid assignee")
+			return false
+		}
+	}
+
+	next, err := recurring.NextRun(template, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid schedule: "+err.Error())
+		return false
+	}
+	if reschedule {
+		template.NextRunAt = nil
+		if template.IsActive {
+			template.NextRunAt = &next
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/export"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReportController struct {
+	db        *gorm.DB
+	sla       reports.SLAPolicy
+	deliverer *reports.Deliverer
+}
+
+func NewReportController(db *gorm.DB, sla reports.SLAPolicy, deliverer *reports.Deliverer) *ReportController {
+	return &ReportController{db: db, sla: sla, deliverer: deliverer}
+}
+
+// GetAgentReport returns per-agent performance for tickets resolved in a
+// date range. `format=csv` (or xlsx) downloads it as a spreadsheet.
+func (rc *ReportController) GetAgentReport(w http.ResponseWriter, r *http.Request) {
+	filter, format, ok := rc.parseReportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := reports.AgentReport(rc.db, filter, rc.sla)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute agent report")
+		return
+	}
+
+	if format != "" {
+		writeReportTable(w, "agent-performance", format, reports.AgentTable(report))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"from":   filter.From.Format("2006-01-02"),
+		"to":     filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"agents": report,
+	})
+}
+
+// GetSLAReport returns SLA compliance per priority for tickets resolved in
+// a date range
+func (rc *ReportController) GetSLAReport(w http.ResponseWriter, r *http.Request) {
+	filter, format, ok := rc.parseReportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := reports.SLAReport(rc.db, filter, rc.sla)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute SLA report")
+		return
+	}
+
+	if format != "" {
+		writeReportTable(w, "sla-compliance", format, reports.SLATable(report))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"from":       filter.From.Format("2006-01-02"),
+		"to":         filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"priorities": report,
+	})
+}
+
+// GetCSATReport returns the satisfaction surveys answered in a date range
+// with their average rating
+func (rc *ReportController) GetCSATReport(w http.ResponseWriter, r *http.Request) {
+	filter, format, ok := rc.parseReportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := reports.CSATReport(rc.db, filter)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute CSAT report")
+		return
+	}
+
+	if format != "" {
+		writeReportTable(w, "csat", format, reports.CSATTable(summary))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      filter.From.Format("2006-01-02"),
+		"to":        filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"average":   summary.Average,
+		"responses": summary.Responses,
+	})
+}
+
+// GetTimeReport totals logged time per ticket, agent or category
+// (`group_by`, default agent) for entries started in a date range
+func (rc *ReportController) GetTimeReport(w http.ResponseWriter, r *http.Request) {
+	filter, format, ok := rc.parseReportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = reports.TimeByAgent
+	}
+	if !reports.ValidTimeGroup(groupBy) {
+		utils.WriteError(w, http.StatusBadRequest, "group_by must be ticket, agent or category")
+		return
+	}
+
+	report, err := reports.TimeReport(rc.db, filter, groupBy)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute time report")
+		return
+	}
+
+	if format != "" {
+		writeReportTable(w, "time-by-"+groupBy, format, reports.TimeTable(groupBy, report))
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     filter.From.Format("2006-01-02"),
+		"to":       filter.To.AddDate(0, 0, -1).Format("2006-01-02"),
+		"group_by": groupBy,
+		"totals":   report,
+	})
+}
+
+// parseReportRequest reads the report filter and the export format; an
+// empty format means a JSON response
+func (rc *ReportController) parseReportRequest(w http.ResponseWriter, r *http.Request) (reports.Filter, string, bool) {
+	filter, err := reports.ParseFilter(r.URL.Query(), time.Now().UTC())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return filter, "", false
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == export.FormatJSON {
+		format = ""
+	}
+	if format != "" && !export.Supported(format) {
+		utils.WriteError(w, http.StatusBadRequest, "format must be json, csv or xlsx")
+		return filter, "", false
+	}
+	return filter, format, true
+}
+
+// writeReportTable downloads a report table in an export format
+func writeReportTable(w http.ResponseWriter, name, format string, table reports.Table) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	writer, _ := export.NewWriter(format, w)
+	if err := writer.WriteHeader(table.Columns); err != nil {
+		log.Printf("%s report: %v", name, err)
+		return
+	}
+	for _, row := range table.Rows {
+		if err := writer.WriteRow(row); err != nil {
+			log.Printf("%s report: %v", name, err)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("%s report: %v", name, err)
+	}
+}
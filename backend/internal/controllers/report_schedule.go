@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/internal/scheduler"
+	"quickdesk-backend/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type ReportScheduleRequest struct {
+	Name       string     `json:"name"`
+	Report     string     `json:"report"` // agents, sla, csat, time or view
+	ViewID     *uuid.UUID `json:"view_id"`
+	Params     *string    `json:"params"`
+	Schedule   string     `json:"schedule"` // Cron expression, e.g. "0 8 * * mon"
+	Timezone   string     `json:"timezone"`
+	Recipients []string   `json:"recipients"`
+	IsActive   *bool      `json:"is_active"`
+}
+
+// maxReportRuns bounds the run history returned for a schedule
+const maxReportRuns = 100
+
+func (rc *ReportController) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	var schedules []models.ReportSchedule
+	if err := rc.db.Preload("View").Preload("Owner").Order("name").Find(&schedules).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch schedules")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, schedules)
+}
+
+func (rc *ReportController) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req ReportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	schedule := models.ReportSchedule{
+		ID:       uuid.New(),
+		Timezone: "UTC",
+		IsActive: true,
+		OwnerID:  userID,
+	}
+	rc.applyScheduleRequest(&schedule, &req)
+
+	if !rc.validateSchedule(w, &schedule) {
+		return
+	}
+
+	if err := rc.db.Create(&schedule).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create schedule")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, schedule)
+}
+
+func (rc *ReportController) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req ReportScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var schedule models.ReportSchedule
+	if err := rc.db.First(&schedule, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	rc.applyScheduleRequest(&schedule, &req)
+
+	if !rc.validateSchedule(w, &schedule) {
+		return
+	}
+
+	if err := rc.db.Model(&schedule).
+		Select("name", "report", "view_id", "params", "schedule", "timezone", "recipients", "is_active", "next_run_at").
+		Updates(&schedule).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, schedule)
+}
+
+func (rc *ReportController) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	result := rc.db.Delete(&models.ReportSchedule{}, "id = ?", chi.URLParam(r, "id"))
+	if result.Error != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.WriteError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Schedule deleted successfully"})
+}
+
+// RunSchedule delivers a schedule immediately without moving its next run
+func (rc *ReportController) RunSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule models.ReportSchedule
+	if err := rc.db.First(&schedule, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	run, err := rc.deliverer.Deliver(&schedule, time.Now())
+	if err != nil {
+		log.Printf("reports: schedule %s: %v", schedule.ID, err)
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to record the run")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, run)
+}
+
+// GetScheduleRuns lists a schedule's most recent runs, newest first
+func (rc *ReportController) GetScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	var runs []models.ReportRun
+	if err := rc.db.Where("schedule_id = ?", chi.URLParam(r, "id")).
+		Order("started_at DESC").Limit(maxReportRuns).Find(&runs).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch runs")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, runs)
+}
+
+func (rc *ReportController) applyScheduleRequest(schedule *models.ReportSchedule, req *ReportScheduleRequest) {
+	if req.Name != "" {
+		schedule.Name = req.Name
+	}
+	if req.Report != "" {
+		schedule.Report = req.Report
+	}
+	if req.ViewID != nil {
+		schedule.ViewID = req.ViewID
+	}
+	if req.Params != nil {
+		schedule.Params = *req.Params
+	}
+	if req.Schedule != "" {
+		schedule.Schedule = req.Schedule
+	}
+	if req.Timezone != "" {
+		schedule.Timezone = req.Timezone
+	}
+	if req.Recipients != nil {
+		schedule.Recipients = strings.Join(req.Recipients, ",")
+	}
+	if req.IsActive != nil {
+		schedule.IsActive = *req.IsActive
+	}
+	if schedule.Report != reports.ReportView {
+		schedule.ViewID = nil
+	}
+}
+
+// validateSchedule checks a schedule and computes its next run, writing a
+// 400 response when it is invalid
+func (rc *ReportController) validateSchedule(w http.ResponseWriter, schedule *models.ReportSchedule) bool {
+	if schedule.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Schedule name is required")
+		return false
+	}
+
+	switch schedule.Report {
+	case reports.ReportAgents, reports.ReportSLA, reports.ReportCSAT, reports.ReportTime:
+		params, err := url.ParseQuery(schedule.Params)
+		if err == nil {
+			_, err = reports.ParseFilter(params, time.Now())
+		}
+		if g := params.Get("group_by"); err == nil && schedule.Report == reports.ReportTime && g != "" && !reports.ValidTimeGroup(g) {
+			err = fmt.Errorf("group_by must be ticket, agent or category")
+		}
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid params: "+err.Error())
+			return false
+		}
+	case reports.ReportView:
+		// The owner must be able to open the view, or a schedule would mail
+		// out someone else's private view
+		var count int64
+		if schedule.ViewID != nil {
+			query.VisibleViews(rc.db, query.Viewer{UserID: schedule.OwnerID}).Where("id = ?", *schedule.ViewID).Count(&count)
+		}
+		if count == 0 {
+			utils.WriteError(w, http.StatusBadRequest, "A valid view_id is required")
+			return false
+		}
+	default:
+		utils.WriteError(w, http.StatusBadRequest, "report must be agents, sla, csat, time or view")
+		return false
+	}
+
+	if _, err := scheduler.ParseCron(schedule.Schedule); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid schedule: "+err.Error())
+		return false
+	}
+
+	recipients := reports.Recipients(schedule.Recipients)
+	if len(recipients) == 0 {
+		utils.WriteError(w, http.StatusBadRequest, "At least one recipient is required")
+		return false
+	}
+	for _, recipient := range recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Invalid recipient: "+recipient)
+			return false
+		}
+	}
+	schedule.Recipients = strings.Join(recipients, ",")
+
+	schedule.NextRunAt = nil
+	if schedule.IsActive {
+		next, err := reports.NextRun(schedule, time.Now())
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return false
+		}
+		schedule.NextRunAt = &next
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/internal/utils"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StatsController struct {
+	db *gorm.DB
+}
+
+func NewStatsController(db *gorm.DB) *StatsController {
+	return &StatsController{db: db}
+}
+
+// GetDashboard returns ticket statistics for the dashboard, scoped to the
+// tickets the caller can see. `days` sets the length of the per-day series.
+func (sc *StatsController) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	days := reports.DefaultDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > reports.MaxDays {
+			utils.WriteError(w, http.StatusBadRequest, "days must be between 1 and "+strconv.Itoa(reports.MaxDays))
+			return
+		}
+		days = n
+	}
+
+	dashboard, err := reports.BuildDashboard(sc.db, viewerFromRequest(r), days, time.Now())
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to compute statistics")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, dashboard)
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/realtime"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/auth"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// streamKeepAlive stops proxies from closing idle streams
+	streamKeepAlive = 25 * time.Second
+	// maxStreamTickets bounds the ticket:<id> topics of one stream
+	maxStreamTickets = 20
+)
+
+// StreamController pushes ticket activity and notifications to clients
+// as Server-Sent Events
+type StreamController struct {
+	db  *gorm.DB
+	hub *realtime.Hub
+}
+
+func NewStreamController(db *gorm.DB, hub *realtime.Hub) *StreamController {
+	return &StreamController{db: db, hub: hub}
+}
+
+// CreateToken returns a short-lived token for opening a stream with
+// ?token=, for clients like EventSource that cannot send the session token
+// in a header
+func (sc *StreamController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+	token, expiresAt, err := auth.GenerateStreamToken(viewer.UserID, viewer.Role)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create stream token")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
+
+// Stream subscribes the caller to `topics`, a comma-separated list of
+// `tickets` (changes to any ticket they can see), `ticket:<id>` (one
+// ticket's changes and comments) and `notifications` (their own). The
+// default is tickets and notifications.
+func (sc *StreamController) Stream(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+	sub := realtime.Subscription{Viewer: viewer}
+	topics := r.URL.Query().Get("topics")
+	if topics == "" {
+		topics = "tickets,notifications"
+	}
+	for _, topic := range strings.Split(topics, ",") {
+		topic = strings.TrimSpace(topic)
+		switch {
+		case topic == "tickets":
+			sub.Tickets = true
+		case topic == "notifications":
+			sub.Notifications = true
+		case strings.HasPrefix(topic, "ticket:"):
+			id, err := uuid.Parse(strings.TrimPrefix(topic, "ticket:"))
+			if err != nil {
+				utils.WriteError(w, http.StatusBadRequest, "Invalid ticket ID in topic "+topic)
+				return
+			}
+			var ticket models.Ticket
+			if err := sc.db.Select("id", "created_by_id").First(&ticket, "id = ?", id).Error; err != nil {
+				utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+				return
+			}
+			if !viewer.Sees(ticket.CreatedByID) {
+				utils.WriteError(w, http.StatusForbidden, "Access denied")
+				return
+			}
+			sub.TicketIDs = append(sub.TicketIDs, id)
+		case topic != "":
+			utils.WriteError(w, http.StatusBadRequest, "Unknown topic "+topic)
+			return
+		}
+	}
+	if len(sub.TicketIDs) > maxStreamTickets {
+		utils.WriteError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ticket topics", maxStreamTickets))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, "Streaming unsupported")
+		return
+	}
+
+	client := sc.hub.Subscribe(sub)
+	defer sc.hub.Unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Keeps nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "event: ready\ndata: {}\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.Done():
+			return // Fell behind; the client reconnects and reloads
+		case frame := <-client.Frames():
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", frame.Type, frame.Data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TeamController struct {
+	db *gorm.DB
+}
+
+func NewTeamController(db *gorm.DB) *TeamController {
+	return &TeamController{db: db}
+}
+
+type TeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type SetTeamMembersRequest struct {
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+// GetTeams lists the teams. Only agents and admins see the members, so
+// requesters cannot use it to look up staff.
+func (tc *TeamController) GetTeams(w http.ResponseWriter, r *http.Request) {
+	userRole, _ := utils.GetUserRoleFromContext(r)
+
+	query := tc.db.Order("name")
+	if userRole == models.RoleAgent || userRole == models.RoleAdmin {
+		query = query.Preload("Members")
+	}
+
+	var teams []models.Team
+	if err := query.Find(&teams).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch teams")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, teams)
+}
+
+func (tc *TeamController) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	var req TeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Team name is required")
+		return
+	}
+
+	var existingTeam models.Team
+	if err := tc.db.Where("name = ?", req.Name).First(&existingTeam).Error; err == nil {
+		utils.WriteError(w, http.StatusConflict, "Team name already exists")
+		return
+	}
+
+	team := models.Team{
+		ID:          uuid.New(),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := tc.db.Create(&team).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create team")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, team)
+}
+
+func (tc *TeamController) UpdateTeam(w http.ResponseWriter, r *http.Request) {
+	teamID := utils.GetURLParam(r, "id")
+
+	var req TeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var team models.Team
+	if err := tc.db.First(&team, "id = ?", teamID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Team not found")
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		var existingTeam models.Team
+		if err := tc.db.Where("name = ? AND id != ?", req.Name, teamID).First(&existingTeam).Error; err == nil {
+			utils.WriteError(w, http.StatusConflict, "Team name already exists")
+			return
+		}
+		updates["name"] = req.Name
+	}
+	if req.Description != "" {
+		updates["description"] = req.Description
+	}
+
+	if err := tc.db.Model(&team).Updates(updates).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update team")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, team)
+}
+
+func (tc *TeamController) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	teamID := utils.GetURLParam(r, "id")
+
+	var team models.Team
+	if err := tc.db.First(&team, "id = ?", teamID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Team not found")
+		return
+	}
+
+	if err := tc.db.Model(&team).Association("Members").Clear(); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete team")
+		return
+	}
+
+	// Views shared with the team fall back to their owner
+	tc.db.Model(&models.SavedView{}).Where("team_id = ?", team.ID).Updates(map[string]interface{}{
+		"visibility": models.VisibilityPrivate,
+		"team_id":    nil,
+	})
+
+	if err := tc.db.Delete(&team).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete team")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Team deleted successfully"})
+}
+
+// SetMembers replaces the team's member list
+func (tc *TeamController) SetMembers(w http.ResponseWriter, r *http.Request) {
+	teamID := utils.GetURLParam(r, "id")
+
+	var req SetTeamMembersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var team models.Team
+	if err := tc.db.First(&team, "id = ?", teamID).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Team not found")
+		return
+	}
+
+	var members []models.User
+	if len(req.UserIDs) > 0 {
+		if err := tc.db.Where("id IN ?", req.UserIDs).Find(&members).Error; err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch users")
+			return
+		}
+		if len(members) != len(req.UserIDs) {
+			utils.WriteError(w, http.StatusBadRequest, "Unknown user in user_ids")
+			return
+		}
+	}
+
+	if err := tc.db.Model(&team).Association("Members").Replace(members); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update team members")
+		return
+	}
+
+	team.Members = members
+	utils.WriteJSON(w, http.StatusOK, team)
+}
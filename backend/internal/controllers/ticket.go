@@ -2,9 +2,19 @@ package controllers
 
 import (
     "encoding/json"
+    "errors"
+    "fmt"
     "net/http"
+    "net/url"
+    "quickdesk-backend/internal/events"
     "quickdesk-backend/internal/models"
+    "quickdesk-backend/internal/query"
+    "quickdesk-backend/internal/tickets"
+    "quickdesk-backend/internal/utils"
+    "slices"
     "strconv"
+    "strings"
+    "time"
 
     "github.com/go-chi/chi/v5"
     "github.com/google/uuid"
@@ -12,18 +22,23 @@ import (
 )
 
 type TicketController struct {
-    db *gorm.DB
+    db     *gorm.DB
+    events *events.Bus
 }
 
-func NewTicketController(db *gorm.DB) *TicketController {
-    return &TicketController{db: db}
+func NewTicketController(db *gorm.DB, bus *events.Bus) *TicketController {
+    return &TicketController{db: db, events: bus}
 }
 
 type CreateTicketRequest struct {
-    Subject     string                `json:"subject" binding:"required"`
-    Description string                `json:"description" binding:"required"`
-    Priority    models.TicketPriority `json:"priority"`
-    CategoryID  uuid.UUID             `json:"category_id" binding:"required"`
+    Subject      string                `json:"subject" binding:"required"`
+    Description  string                `json:"description" binding:"required"`
+    Priority     models.TicketPriority `json:"priority"`
+    CategoryID   uuid.UUID             `json:"category_id" binding:"required"`
+    Tags         []string              `json:"tags,omitempty"`
+    DueAt        *string               `json:"due_at,omitempty"` // RFC 3339, like UpdateTicketRequest; ignored for users
+    TemplateID   *uuid.UUID            `json:"template_id,omitempty"`
+    CustomFields models.Fields         `json:"custom_fields,omitempty"`
 }
 
 type UpdateTicketRequest struct {
@@ -32,6 +47,9 @@ type UpdateTicketRequest struct {
     Status       models.TicketStatus   `json:"status,omitempty"`
     Priority     models.TicketPriority `json:"priority,omitempty"`
     AssignedToID *uuid.UUID            `json:"assigned_to_id,omitempty"`
+    Tags         []string              `json:"tags,omitempty"`          // Replaces all tags when present
+    DueAt        *string               `json:"due_at,omitempty"`        // RFC 3339; an empty string clears it
+    CustomFields models.Fields         `json:"custom_fields,omitempty"` // Replaces all custom fields when present
 }
 
 type AddCommentRequest struct {
@@ -44,81 +62,146 @@ type VoteRequest struct {
 }
 
 func (tc *TicketController) GetTickets(w http.ResponseWriter, r *http.Request) {
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
-    userRole, _ := r.Context().Value("user_role").(models.Role)
-
-    // Query parameters
-    status := r.URL.Query().Get("status")
-    category := r.URL.Query().Get("category")
-    assignedTo := r.URL.Query().Get("assigned_to")
-    createdBy := r.URL.Query().Get("created_by")
-    search := r.URL.Query().Get("search")
-    sortBy := r.URL.Query().Get("sort_by")
-    sortOrder := r.URL.Query().Get("sort_order")
-    page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-    limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-
-    query := tc.db.Model(&models.Ticket{}).
-        Preload("CreatedBy").
-        Preload("AssignedTo").
-        Preload("Category")
-
-    // Apply filters based on user role
-    if userRole == models.RoleUser {
-        // Users can only see their own tickets
-        query = query.Where("created_by_id = ?", userID)
+    query, err := tc.filteredTickets(r)
+    if err != nil {
+        writeQueryError(w, err)
+        return
     }
 
-    // Apply filters
-    if status != "" {
-        query = query.Where("status = ?", status)
-    }
-    if category != "" {
-        query = query.Where("category_id = ?", category)
-    }
-    if assignedTo != "" {
-        query = query.Where("assigned_to_id = ?", assignedTo)
-    }
-    if createdBy != "" {
-        query = query.Where("created_by_id = ?", createdBy)
-    }
-    if search != "" {
-        query = query.Where("subject ILIKE ? OR description ILIKE ?", "%"+search+"%", "%"+search+"%")
+    writeTicketList(w, r, query, r.URL.Query().Get("sort_by"), r.URL.Query().Get("sort_order"))
+}
+
+const (
+    defaultPageSize = 20
+    maxPageSize     = 100
+)
+
+// writeTicketList sorts and paginates a filtered tickets query using the
+// request's paging parameters and writes the list response.
+//
+// Pages are fetched with an opaque `cursor` (keyset on the sort key plus
+// ticket ID); `page` still selects an offset page for older clients.
+func writeTicketList(w http.ResponseWriter, r *http.Request, db *gorm.DB, sortBy, sortOrder string) {
+    params := r.URL.Query()
+    page, _ := strconv.Atoi(params.Get("page"))
+    limit, _ := strconv.Atoi(params.Get("limit"))
+    if limit <= 0 {
+        limit = defaultPageSize
+    }
+    if limit > maxPageSize {
+        limit = maxPageSize
+    }
+
+    sort, err := query.ParseSort(sortBy, sortOrder)
+    if err != nil {
+        utils.WriteError(w, http.StatusBadRequest, err.Error())
+        return
     }
 
-    // Apply sorting
-    orderClause := sortBy + " " + sortOrder
-    if sortBy == "most_replied" {
-        // This would need a subquery to count comments
-        orderClause = "created_at desc" // Fallback for now
+    facetNames, err := query.ParseFacets(params.Get("facets"))
+    if err != nil {
+        utils.WriteError(w, http.StatusBadRequest, err.Error())
+        return
     }
-    query = query.Order(orderClause)
 
-    // Count total
+    // Count total before the cursor narrows the query
+    db = db.Session(&gorm.Session{})
     var total int64
-    query.Count(&total)
+    if err := db.Count(&total).Error; err != nil {
+        http.Error(w, "Failed to fetch tickets", http.StatusInternalServerError)
+        return
+    }
+
+    // Facets count the whole filter set, not just this page
+    var facets map[string][]query.FacetCount
+    if len(facetNames) > 0 {
+        facets, err = query.Facets(db, facetNames)
+        if err != nil {
+            http.Error(w, "Failed to count facets", http.StatusInternalServerError)
+            return
+        }
+    }
 
-    // Apply pagination
-    offset := (page - 1) * limit
-    query = query.Offset(offset).Limit(limit)
+    if cursor := params.Get("cursor"); cursor != "" {
+        db, err = sort.After(db, cursor)
+        if err != nil {
+            utils.WriteError(w, http.StatusBadRequest, err.Error())
+            return
+        }
+    } else if page > 1 {
+        db = db.Offset((page - 1) * limit)
+    }
 
+    // Fetch one extra row to learn whether another page follows
     var tickets []models.Ticket
-    if err := query.Find(&tickets).Error; err != nil {
+    if err := sort.Order(db).
+        Preload("CreatedBy").
+        Preload("AssignedTo").
+        Preload("Category").
+        Preload("Tags").
+        Limit(limit + 1).
+        Find(&tickets).Error; err != nil {
         http.Error(w, "Failed to fetch tickets", http.StatusInternalServerError)
         return
     }
 
+    var nextCursor string
+    if len(tickets) > limit {
+        tickets = tickets[:limit]
+        nextCursor, err = sort.Cursor(db.Session(&gorm.Session{NewDB: true}), &tickets[limit-1])
+        if err != nil {
+            http.Error(w, "Failed to fetch tickets", http.StatusInternalServerError)
+            return
+        }
+    }
+
+    w.Header().Set("Link", paginationLinks(r, nextCursor))
+
+    response := map[string]interface{}{
+        "tickets":     tickets,
+        "total":       total,
+        "limit":       limit,
+        "next_cursor": nextCursor,
+    }
+    if page > 0 {
+        response["page"] = page
+    }
+    if facets != nil {
+        response["facets"] = facets
+    }
+
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]interface{}{
-        "tickets": tickets,
-        "total":   total,
-        "page":    page,
-        "limit":   limit,
-    })
+    json.NewEncoder(w).Encode(response)
+}
+
+// paginationLinks builds an RFC 5988 Link header with the first page and,
+// when there is one, the next page of the current request
+func paginationLinks(r *http.Request, nextCursor string) string {
+    scheme := "http"
+    if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+        scheme = "https"
+    }
+
+    link := func(cursor, rel string) string {
+        params := r.URL.Query()
+        params.Del("page")
+        params.Del("cursor")
+        if cursor != "" {
+            params.Set("cursor", cursor)
+        }
+        u := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: params.Encode()}
+        return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+    }
+
+    links := []string{link("", "first")}
+    if nextCursor != "" {
+        links = append(links, link(nextCursor, "next"))
+    }
+    return strings.Join(links, ", ")
 }
 
 func (tc *TicketController) CreateTicket(w http.ResponseWriter, r *http.Request) {
-    userID := r.Context().Value("user_id").(uuid.UUID)
+    userID, _ := utils.GetUserIDFromContext(r)
 
     var req CreateTicketRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -126,30 +209,45 @@ func (tc *TicketController) CreateTicket(w http.ResponseWriter, r *http.Request)
         return
     }
 
-    // Verify category exists
-    var category models.Category
-    if err := tc.db.First(&category, req.CategoryID).Error; err != nil {
-        http.Error(w, "Invalid category", http.StatusBadRequest)
-        return
+    newTicket := tickets.NewTicket{
+        Subject:      req.Subject,
+        Description:  req.Description,
+        Priority:     req.Priority,
+        CategoryID:   req.CategoryID,
+        CreatedByID:  userID,
+        Tags:         req.Tags,
+        CustomFields: req.CustomFields,
+    }
+    if userRole, _ := utils.GetUserRoleFromContext(r); userRole != models.RoleUser && req.DueAt != nil {
+        dueAt, err := parseDueAt(*req.DueAt)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        newTicket.DueAt = dueAt
     }
 
-    ticket := models.Ticket{
-        ID:          uuid.New(),
-        Subject:     req.Subject,
-        Description: req.Description,
-        Priority:    req.Priority,
-        Status:      models.StatusOpen,
-        CreatedByID: userID,
-        CategoryID:  req.CategoryID,
+    // Templates prefill what the request leaves out
+    if req.TemplateID != nil {
+        if err := tickets.ApplyTemplate(tc.db, &newTicket, *req.TemplateID); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
     }
 
-    if err := tc.db.Create(&ticket).Error; err != nil {
+    ticket, err := tickets.Create(tc.db, newTicket)
+    if errors.Is(err, tickets.ErrInvalidCategory) {
+        http.Error(w, "Invalid category", http.StatusBadRequest)
+        return
+    }
+    if err != nil {
         http.Error(w, "Failed to create ticket", http.StatusInternalServerError)
         return
     }
+    tc.events.Publish(events.Event{Type: events.TicketCreated, TicketID: ticket.ID, ActorID: userID})
 
     // Load relationships
-    tc.db.Preload("CreatedBy").Preload("Category").First(&ticket, ticket.ID)
+    tc.db.Preload("CreatedBy").Preload("Category").Preload("Tags").First(ticket, ticket.ID)
 
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusCreated)
@@ -158,16 +256,22 @@ func (tc *TicketController) CreateTicket(w http.ResponseWriter, r *http.Request)
 
 func (tc *TicketController) GetTicket(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
-    userRole, _ := r.Context().Value("user_role").(models.Role)
+    viewer := viewerFromRequest(r)
+
+    // Requesters never see internal notes
+    comments := []interface{}{}
+    if viewer.Role == models.RoleUser {
+        comments = append(comments, "is_internal = ?", false)
+    }
 
     var ticket models.Ticket
     query := tc.db.Preload("CreatedBy").
         Preload("AssignedTo").
         Preload("Category").
-        Preload("Comments").
+        Preload("Comments", comments...).
         Preload("Comments.User").
-        Preload("Attachments")
+        Preload("Attachments").
+        Preload("Tags")
 
     if err := query.First(&ticket, "id = ?", ticketID).Error; err != nil {
         http.Error(w, "Ticket not found", http.StatusNotFound)
@@ -175,7 +279,7 @@ func (tc *TicketController) GetTicket(w http.ResponseWriter, r *http.Request) {
     }
 
     // Check permissions
-    if userRole == models.RoleUser && ticket.CreatedByID != userID {
+    if !viewer.Sees(ticket.CreatedByID) {
         http.Error(w, "Access denied", http.StatusForbidden)
         return
     }
@@ -189,8 +293,8 @@ func (tc *TicketController) GetTicket(w http.ResponseWriter, r *http.Request) {
 
 func (tc *TicketController) UpdateTicket(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
-    userRole, _ := r.Context().Value("user_role").(models.Role)
+    userID, _ := utils.GetUserIDFromContext(r)
+    userRole, _ := utils.GetUserRoleFromContext(r)
 
     var req UpdateTicketRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -210,34 +314,87 @@ func (tc *TicketController) UpdateTicket(w http.ResponseWriter, r *http.Request)
         return
     }
 
-    // Users can only update subject and description, agents/admins can update everything
+    // Users can only update subject and description, agents/admins can update everything.
+    // changes lists the public fields that changed; updates may also touch
+    // bookkeeping columns that clients never see in events.
     updates := make(map[string]interface{})
-    if req.Subject != "" {
-        updates["subject"] = req.Subject
+    var changes []string
+    set := func(field string, value interface{}) {
+        updates[field] = value
+        changes = append(changes, field)
     }
-    if req.Description != "" {
-        updates["description"] = req.Description
+    if req.Subject != "" && req.Subject != ticket.Subject {
+        set("subject", req.Subject)
+    }
+    if req.Description != "" && req.Description != ticket.Description {
+        set("description", req.Description)
+    }
+    if req.CustomFields != nil {
+        set("custom_fields", req.CustomFields)
     }
 
     if userRole != models.RoleUser {
-        if req.Status != "" {
-            updates["status"] = req.Status
+        if req.Status != "" && req.Status != ticket.Status {
+            set("status", req.Status)
+
+            // Track resolution time; reopening starts the clock again
+            if req.Status.IsResolved() && ticket.ResolvedAt == nil {
+                updates["resolved_at"] = time.Now()
+            } else if !req.Status.IsResolved() {
+                updates["resolved_at"] = nil
+                if ticket.Status.IsResolved() {
+                    updates["reopen_count"] = gorm.Expr("reopen_count + 1")
+                }
+            }
+        }
+        if req.Priority != "" && req.Priority != ticket.Priority {
+            set("priority", req.Priority)
         }
-        if req.Priority != "" {
-            updates["priority"] = req.Priority
+        if req.AssignedToID != nil && !sameUser(ticket.AssignedToID, req.AssignedToID) {
+            set("assigned_to_id", req.AssignedToID)
         }
-        if req.AssignedToID != nil {
-            updates["assigned_to_id"] = req.AssignedToID
+        if req.DueAt != nil {
+            dueAt, err := parseDueAt(*req.DueAt)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            set("due_at", dueAt)
+            updates["due_reminded_at"] = nil // A new due date gets a new reminder
         }
     }
 
-    if err := tc.db.Model(&ticket).Updates(updates).Error; err != nil {
-        http.Error(w, "Failed to update ticket", http.StatusInternalServerError)
-        return
+    previousAssignee := ticket.AssignedToID
+    if len(updates) > 0 {
+        if err := tc.db.Model(&ticket).Updates(updates).Error; err != nil {
+            http.Error(w, "Failed to update ticket", http.StatusInternalServerError)
+            return
+        }
+    }
+
+    if req.Tags != nil {
+        tags, err := tickets.ResolveTags(tc.db, req.Tags)
+        if err != nil {
+            http.Error(w, "Failed to save tags", http.StatusInternalServerError)
+            return
+        }
+        if err := tc.db.Model(&ticket).Association("Tags").Replace(tags); err != nil {
+            http.Error(w, "Failed to save tags", http.StatusInternalServerError)
+            return
+        }
+        changes = append(changes, "tags")
+    }
+
+    if len(changes) > 0 {
+        slices.Sort(changes)
+        tc.events.Publish(events.Event{Type: events.TicketUpdated, TicketID: ticket.ID, ActorID: userID, Changes: changes})
+    }
+    if req.AssignedToID != nil && userRole != models.RoleUser && !sameUser(previousAssignee, req.AssignedToID) {
+        tc.events.Publish(events.Event{Type: events.TicketAssigned, TicketID: ticket.ID, ActorID: userID})
     }
 
     // Reload ticket with relationships
-    tc.db.Preload("CreatedBy").Preload("AssignedTo").Preload("Category").First(&ticket, ticket.ID)
+    tc.db.Preload("CreatedBy").Preload("AssignedTo").Preload("Category").Preload("Tags").First(&ticket, ticket.ID)
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(ticket)
@@ -245,8 +402,8 @@ func (tc *TicketController) UpdateTicket(w http.ResponseWriter, r *http.Request)
 
 func (tc *TicketController) DeleteTicket(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
-    userRole, _ := r.Context().Value("user_role").(models.Role)
+    userID, _ := utils.GetUserIDFromContext(r)
+    userRole, _ := utils.GetUserRoleFromContext(r)
 
     var ticket models.Ticket
     if err := tc.db.First(&ticket, "id = ?", ticketID).Error; err != nil {
@@ -264,6 +421,7 @@ func (tc *TicketController) DeleteTicket(w http.ResponseWriter, r *http.Request)
         http.Error(w, "Failed to delete ticket", http.StatusInternalServerError)
         return
     }
+    tc.events.Publish(events.Event{Type: events.TicketDeleted, TicketID: ticket.ID, ActorID: userID})
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{"message": "Ticket deleted successfully"})
@@ -271,8 +429,8 @@ func (tc *TicketController) DeleteTicket(w http.ResponseWriter, r *http.Request)
 
 func (tc *TicketController) AddComment(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
-    userRole, _ := r.Context().Value("user_role").(models.Role)
+    userID, _ := utils.GetUserIDFromContext(r)
+    userRole, _ := utils.GetUserRoleFromContext(r)
 
     var req AddCommentRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -292,21 +450,16 @@ func (tc *TicketController) AddComment(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    comment := models.Comment{
-        ID:         uuid.New(),
-        Content:    req.Content,
-        TicketID:   uuid.MustParse(ticketID),
-        UserID:     userID,
-        IsInternal: req.IsInternal && userRole != models.RoleUser, // Only agents/admins can make internal comments
-    }
-
-    if err := tc.db.Create(&comment).Error; err != nil {
+    author := models.User{ID: userID, Role: userRole}
+    comment, err := tickets.AddComment(tc.db, &ticket, &author, req.Content, req.IsInternal)
+    if err != nil {
         http.Error(w, "Failed to add comment", http.StatusInternalServerError)
         return
     }
+    tc.events.Publish(events.Event{Type: events.CommentAdded, TicketID: ticket.ID, ActorID: userID, CommentID: &comment.ID})
 
     // Load user relationship
-    tc.db.Preload("User").First(&comment, comment.ID)
+    tc.db.Preload("User").First(comment, comment.ID)
 
     w.Header().Set("Content-Type", "application/json")
     w.WriteHeader(http.StatusCreated)
@@ -315,7 +468,12 @@ func (tc *TicketController) AddComment(w http.ResponseWriter, r *http.Request) {
 
 func (tc *TicketController) VoteTicket(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userID, _ := r.Context().Value("user_id").(uuid.UUID)
+    userID, _ := utils.GetUserIDFromContext(r)
+    id, err := uuid.Parse(ticketID)
+    if err != nil {
+        http.Error(w, "Invalid ticket ID", http.StatusBadRequest)
+        return
+    }
 
     var req VoteRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -325,20 +483,22 @@ func (tc *TicketController) VoteTicket(w http.ResponseWriter, r *http.Request) {
 
     // Check if user already voted
     var existingVote models.Vote
-    err := tc.db.Where("ticket_id = ? AND user_id = ?", ticketID, userID).First(&existingVote).Error
+    err = tc.db.Where("ticket_id = ? AND user_id = ?", ticketID, userID).First(&existingVote).Error
 
+    changed := false
     if err == nil {
         // Update existing vote
         if existingVote.Type != req.Type {
             tc.db.Model(&existingVote).Update("type", req.Type)
             tc.updateVoteCounts(ticketID)
+            changed = true
         }
     } else {
         // Create new vote
         vote := models.Vote{
             ID:       uuid.New(),
             Type:     req.Type,
-            TicketID: uuid.MustParse(ticketID),
+            TicketID: id,
             UserID:   userID,
         }
 
@@ -348,6 +508,10 @@ func (tc *TicketController) VoteTicket(w http.ResponseWriter, r *http.Request) {
         }
 
         tc.updateVoteCounts(ticketID)
+        changed = true
+    }
+    if changed {
+        tc.events.Publish(events.Event{Type: events.TicketVoted, TicketID: id, ActorID: userID, Changes: []string{"down_votes", "up_votes"}})
     }
 
     w.Header().Set("Content-Type", "application/json")
@@ -356,7 +520,8 @@ func (tc *TicketController) VoteTicket(w http.ResponseWriter, r *http.Request) {
 
 func (tc *TicketController) AssignTicket(w http.ResponseWriter, r *http.Request) {
     ticketID := chi.URLParam(r, "id")
-    userRole, _ := r.Context().Value("user_role").(models.Role)
+    userID, _ := utils.GetUserIDFromContext(r)
+    userRole, _ := utils.GetUserRoleFromContext(r)
 
     if userRole == models.RoleUser {
         http.Error(w, "Access denied", http.StatusForbidden)
@@ -380,14 +545,27 @@ func (tc *TicketController) AssignTicket(w http.ResponseWriter, r *http.Request)
         return
     }
 
-    if err := tc.db.Model(&models.Ticket{}).Where("id = ?", ticketID).
-        Updates(map[string]interface{}{
-            "assigned_to_id": req.AssignedToID,
-            "status":         models.StatusInProgress,
-        }).Error; err != nil {
+    var ticket models.Ticket
+    if err := tc.db.First(&ticket, "id = ?", ticketID).Error; err != nil {
+        http.Error(w, "Ticket not found", http.StatusNotFound)
+        return
+    }
+
+    updates := map[string]interface{}{
+        "assigned_to_id": req.AssignedToID,
+        "status":         models.StatusInProgress,
+    }
+    // Assigning a resolved ticket reopens it
+    if ticket.Status.IsResolved() {
+        updates["resolved_at"] = nil
+        updates["reopen_count"] = gorm.Expr("reopen_count + 1")
+    }
+
+    if err := tc.db.Model(&ticket).Updates(updates).Error; err != nil {
         http.Error(w, "Failed to assign ticket", http.StatusInternalServerError)
         return
     }
+    tc.events.Publish(events.Event{Type: events.TicketAssigned, TicketID: ticket.ID, ActorID: userID})
 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{"message": "Ticket assigned successfully"})
@@ -403,4 +581,85 @@ func (tc *TicketController) updateVoteCounts(ticketID string) {
         "up_votes":   upVotes,
         "down_votes": downVotes,
     })
-}
\ No newline at end of file
+}
+
+// filteredTickets builds the tickets query shared by the list endpoints:
+// role visibility, the `q` query language and its shortcut parameters.
+func (tc *TicketController) filteredTickets(r *http.Request) (*gorm.DB, error) {
+    viewer := viewerFromRequest(r)
+    params := r.URL.Query()
+
+    q, err := query.Parse(params.Get("q"))
+    if err != nil {
+        return nil, err
+    }
+
+    // Shortcut parameters behave like the equivalent query terms. They are
+    // checked here since a typo would otherwise match nothing, or everything.
+    shortcuts := []struct {
+        param, field string
+        op           query.Op
+    }{
+        {"status", query.FieldStatus, query.OpEq},
+        {"priority", query.FieldPriority, query.OpEq},
+        {"category", query.FieldCategory, query.OpEq},
+        {"assigned_to", query.FieldAssignee, query.OpEq},
+        {"created_by", query.FieldCreator, query.OpEq},
+        {"search", query.FieldText, query.OpEq},
+        {"due_before", query.FieldDue, query.OpLt},
+        {"overdue", query.FieldOverdue, query.OpEq},
+    }
+    for _, s := range shortcuts {
+        if value := params.Get(s.param); value != "" {
+            if err := query.Validate(s.field, s.op, value); err != nil {
+                return nil, fmt.Errorf("%s: %w", s.param, err)
+            }
+            q.Add(s.field, s.op, value)
+        }
+    }
+
+    db := query.Visible(tc.db.Model(&models.Ticket{}), viewer)
+    return q.Apply(db, viewer), nil
+}
+
+// parseDueAt reads a due_at request field: an RFC 3339 time, or an empty
+// string for no due date
+func parseDueAt(s string) (*time.Time, error) {
+    if s == "" {
+        return nil, nil
+    }
+    t, err := time.Parse(time.RFC3339, s)
+    if err != nil {
+        return nil, errors.New("due_at must be an RFC 3339 time")
+    }
+    return &t, nil
+}
+
+// sameUser reports whether two optional user IDs are equal
+func sameUser(a, b *uuid.UUID) bool {
+    if a == nil || b == nil {
+        return a == b
+    }
+    return *a == *b
+}
+
+// viewerFromRequest returns the authenticated user queries run for
+func viewerFromRequest(r *http.Request) query.Viewer {
+    userID, _ := utils.GetUserIDFromContext(r)
+    userRole, _ := utils.GetUserRoleFromContext(r)
+    return query.Viewer{UserID: userID, Role: userRole}
+}
+
+// writeQueryError reports an invalid `q` parameter, including the column
+// of syntax errors so clients can highlight it
+func writeQueryError(w http.ResponseWriter, err error) {
+    body := map[string]interface{}{"error": err.Error()}
+    var syntaxErr *query.SyntaxError
+    if errors.As(err, &syntaxErr) {
+        body["position"] = syntaxErr.Pos
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusBadRequest)
+    json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TicketTemplateController serves the per-category templates of the
+// create form; admins maintain them
+type TicketTemplateController struct {
+	db *gorm.DB
+}
+
+func NewTicketTemplateController(db *gorm.DB) *TicketTemplateController {
+	return &TicketTemplateController{db: db}
+}
+
+type TicketTemplateRequest struct {
+	Name          *string                `json:"name"`
+	Subject       *string                `json:"subject"`
+	Description   *string                `json:"description"`
+	Priority      *models.TicketPriority `json:"priority"` // Empty keeps the ticket default
+	FieldDefaults models.Fields          `json:"field_defaults"`
+	Position      *int                   `json:"position"`
+	IsActive      *bool                  `json:"is_active"`
+}
+
+// GetTemplates lists a category's templates in form order. Only admins
+// see inactive ones.
+func (tc *TicketTemplateController) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	query := tc.db.Where("category_id = ?", chi.URLParam(r, "id"))
+	if userRole, _ := utils.GetUserRoleFromContext(r); userRole != models.RoleAdmin {
+		query = query.Where("is_active = ?", true)
+	}
+
+	var templates []models.TicketTemplate
+	if err := query.Order("position, name").Find(&templates).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch templates")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, templates)
+}
+
+func (tc *TicketTemplateController) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req TicketTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var category models.Category
+	if err := tc.db.First(&category, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	template := models.TicketTemplate{
+		ID:         uuid.New(),
+		CategoryID: category.ID,
+		IsActive:   true,
+	}
+	applyTemplateRequest(&template, &req)
+	if !validateTemplate(w, &template) {
+		return
+	}
+
+	if err := tc.db.Create(&template).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create template")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, template)
+}
+
+func (tc *TicketTemplateController) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req TicketTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, ok := tc.findTemplate(w, r)
+	if !ok {
+		return
+	}
+	applyTemplateRequest(template, &req)
+	if !validateTemplate(w, template) {
+		return
+	}
+
+	if err := tc.db.Model(template).
+		Select("name", "subject", "description", "priority", "field_defaults", "position", "is_active").
+		Updates(template).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update template")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, template)
+}
+
+func (tc *TicketTemplateController) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := tc.findTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := tc.db.Delete(template).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete template")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Template deleted successfully"})
+}
+
+// findTemplate loads the template in the URL, which must belong to the
+// category in the URL
+func (tc *TicketTemplateController) findTemplate(w http.ResponseWriter, r *http.Request) (*models.TicketTemplate, bool) {
+	var template models.TicketTemplate
+	if err := tc.db.First(&template, "id = ? AND category_id = ?",
+		chi.URLParam(r, "templateID"), chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Template not found")
+		return nil, false
+	}
+	return &template, true
+}
+
+func applyTemplateRequest(template *models.TicketTemplate, req *TicketTemplateRequest) {
+	if req.Name != nil {
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Subject != nil {
+		template.Subject = *req.Subject
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Priority != nil {
+		template.Priority = *req.Priority
+	}
+	if req.FieldDefaults != nil {
+		template.FieldDefaults = req.FieldDefaults
+	}
+	if req.Position != nil {
+		template.Position = *req.Position
+	}
+	if req.IsActive != nil {
+		template.IsActive = *req.IsActive
+	}
+}
+
+// validateTemplate writes a 400 response when a template is invalid
+func validateTemplate(w http.ResponseWriter, template *models.TicketTemplate) bool {
+	if template.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Template name is required")
+		return false
+	}
+	if template.Priority != "" && !template.Priority.IsValid() {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid priority")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TimeController lets agents log time on tickets, either as finished
+// entries or with a start/stop timer
+type TimeController struct {
+	db *gorm.DB
+}
+
+func NewTimeController(db *gorm.DB) *TimeController {
+	return &TimeController{db: db}
+}
+
+type TimeEntryRequest struct {
+	Minutes   *int       `json:"minutes"`
+	StartedAt *time.Time `json:"started_at"` // Defaults to the duration before now
+	Note      *string    `json:"note"`
+	Billable  *bool      `json:"billable"`
+}
+
+// TimeTotals sums durations in seconds. Running timers are not counted.
+type TimeTotals struct {
+	TotalSeconds    int `json:"total_seconds"`
+	BillableSeconds int `json:"billable_seconds"`
+}
+
+// maxEntryMinutes rejects obviously mistyped entries (more than a day)
+const maxEntryMinutes = 24 * 60
+
+// GetTicketTime lists a ticket's time entries with totals
+func (tc *TimeController) GetTicketTime(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := tc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	var entries []models.TimeEntry
+	if err := tc.db.Preload("User").Where("ticket_id = ?", ticket.ID).
+		Order("started_at DESC").Find(&entries).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch time entries")
+		return
+	}
+
+	var totals TimeTotals
+	for _, e := range entries {
+		if e.EndedAt == nil {
+			continue
+		}
+		totals.TotalSeconds += e.DurationSeconds
+		if e.Billable {
+			totals.BillableSeconds += e.DurationSeconds
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"totals":  totals,
+	})
+}
+
+// LogTime records a finished entry
+func (tc *TimeController) LogTime(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req TimeEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Minutes == nil || *req.Minutes <= 0 || *req.Minutes > maxEntryMinutes {
+		utils.WriteError(w, http.StatusBadRequest, "minutes must be between 1 and 1440")
+		return
+	}
+
+	ticket, ok := tc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	duration := time.Duration(*req.Minutes) * time.Minute
+	ended := time.Now()
+	started := ended.Add(-duration)
+	if req.StartedAt != nil {
+		started = *req.StartedAt
+		ended = started.Add(duration)
+	}
+
+	entry := models.TimeEntry{
+		ID:              uuid.New(),
+		TicketID:        ticket.ID,
+		UserID:          userID,
+		StartedAt:       started,
+		EndedAt:         &ended,
+		DurationSeconds: int(duration.Seconds()),
+	}
+	if req.Note != nil {
+		entry.Note = *req.Note
+	}
+	if req.Billable != nil {
+		entry.Billable = *req.Billable
+	}
+
+	if err := tc.db.Create(&entry).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to log time")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, entry)
+}
+
+// StartTimer starts the caller's timer on a ticket. Only one timer can
+// run per agent.
+func (tc *TimeController) StartTimer(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req TimeEntryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	ticket, ok := tc.findTicket(w, r)
+	if !ok {
+		return
+	}
+
+	var running models.TimeEntry
+	if err := tc.db.Where("user_id = ? AND ended_at IS NULL", userID).First(&running).Error; err == nil {
+		utils.WriteJSON(w, http.StatusConflict, map[string]interface{}{
+			"error": "A timer is already running",
+			"entry": running,
+		})
+		return
+	}
+
+	entry := models.TimeEntry{
+		ID:        uuid.New(),
+		TicketID:  ticket.ID,
+		UserID:    userID,
+		StartedAt: time.Now(),
+	}
+	if req.Note != nil {
+		entry.Note = *req.Note
+	}
+	if req.Billable != nil {
+		entry.Billable = *req.Billable
+	}
+
+	// The unique index on running timers catches a concurrent start
+	if err := tc.db.Create(&entry).Error; err != nil {
+		utils.WriteError(w, http.StatusConflict, "A timer is already running")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, entry)
+}
+
+// StopTimer stops the caller's running timer on a ticket
+func (tc *TimeController) StopTimer(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req TimeEntryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var entry models.TimeEntry
+	if err := tc.db.Where("ticket_id = ? AND user_id = ? AND ended_at IS NULL", chi.URLParam(r, "id"), userID).
+		First(&entry).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "No timer is running on this ticket")
+		return
+	}
+
+	ended := time.Now()
+	updates := map[string]interface{}{
+		"ended_at":         ended,
+		"duration_seconds": int(ended.Sub(entry.StartedAt).Seconds()),
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.Billable != nil {
+		updates["billable"] = *req.Billable
+	}
+
+	if err := tc.db.Model(&entry).Updates(updates).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to stop timer")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, entry)
+}
+
+// GetRunningTimer returns the caller's running timer, or null
+func (tc *TimeController) GetRunningTimer(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var entry models.TimeEntry
+	if err := tc.db.Where("user_id = ? AND ended_at IS NULL", userID).First(&entry).Error; err != nil {
+		utils.WriteJSON(w, http.StatusOK, nil)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, entry)
+}
+
+// UpdateTimeEntry edits a finished entry's duration, note or billable
+// flag. Agents can edit their own entries, admins anyone's.
+func (tc *TimeController) UpdateTimeEntry(w http.ResponseWriter, r *http.Request) {
+	var req TimeEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, ok := tc.editableEntry(w, r)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Minutes != nil {
+		if entry.EndedAt == nil {
+			utils.WriteError(w, http.StatusBadRequest, "Stop the timer before changing its duration")
+			return
+		}
+		if *req.Minutes <= 0 || *req.Minutes > maxEntryMinutes {
+			utils.WriteError(w, http.StatusBadRequest, "minutes must be between 1 and 1440")
+			return
+		}
+		duration := time.Duration(*req.Minutes) * time.Minute
+		updates["duration_seconds"] = int(duration.Seconds())
+		updates["ended_at"] = entry.StartedAt.Add(duration)
+	}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.Billable != nil {
+		updates["billable"] = *req.Billable
+	}
+
+	if err := tc.db.Model(entry).Updates(updates).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update time entry")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, entry)
+}
+
+func (tc *TimeController) DeleteTimeEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := tc.editableEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := tc.db.Delete(entry).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete time entry")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Time entry deleted successfully"})
+}
+
+func (tc *TimeController) findTicket(w http.ResponseWriter, r *http.Request) (*models.Ticket, bool) {
+	var ticket models.Ticket
+	if err := tc.db.First(&ticket, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+		return nil, false
+	}
+	return &ticket, true
+}
+
+// editableEntry loads the entry in the URL if the caller may change it
+func (tc *TimeController) editableEntry(w http.ResponseWriter, r *http.Request) (*models.TimeEntry, bool) {
+	viewer := viewerFromRequest(r)
+
+	var entry models.TimeEntry
+	if err := tc.db.First(&entry, "id = ?", chi.URLParam(r, "entryID")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Time entry not found")
+		return nil, false
+	}
+	if entry.UserID != viewer.UserID && viewer.Role != models.RoleAdmin {
+		utils.WriteError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+	return &entry, true
+}
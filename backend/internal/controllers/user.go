@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"quickdesk-backend/internal/models"
 	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/pkg/email"
 
 	"gorm.io/gorm"
 )
@@ -22,6 +23,7 @@ type UpdateUserRequest struct {
 	LastName  string      `json:"last_name,omitempty"`
 	Role      models.Role `json:"role,omitempty"`
 	IsActive  *bool       `json:"is_active,omitempty"`
+	Language  *string     `json:"language,omitempty"` // Empty for the default
 }
 
 func (uc *UserController) GetUsers(w http.ResponseWriter, r *http.Request) {
@@ -109,6 +111,19 @@ func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	if req.LastName != "" {
 		updates["last_name"] = req.LastName
 	}
+	if req.Language != nil {
+		language := ""
+		if *req.Language != "" {
+			var err error
+			if language, err = email.CanonicalLocale(*req.Language); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "Invalid language"})
+				return
+			}
+		}
+		updates["language"] = language
+	}
 
 	// Only admins can change role and active status
 	if userRole == models.RoleAdmin {
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+	"quickdesk-backend/internal/utils"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ViewController struct {
+	db *gorm.DB
+}
+
+func NewViewController(db *gorm.DB) *ViewController {
+	return &ViewController{db: db}
+}
+
+type ViewRequest struct {
+	Name       string                `json:"name"`
+	Query      *string               `json:"query"`
+	SortBy     *string               `json:"sort_by"`
+	SortOrder  *string               `json:"sort_order"`
+	Visibility models.ViewVisibility `json:"visibility"`
+	TeamID     *uuid.UUID            `json:"team_id"`
+}
+
+// GetViews lists the views the user owns or that are shared with them.
+// With `counts=true` each view carries its live ticket count.
+func (vc *ViewController) GetViews(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+
+	var views []models.SavedView
+	if err := vc.visibleViews(viewer).Preload("Team").Order("name").Find(&views).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch views")
+		return
+	}
+
+	if r.URL.Query().Get("counts") == "true" {
+		for i := range views {
+			count, err := vc.countTickets(&views[i], viewer)
+			if err != nil {
+				continue // A view saved before a syntax change shows no count
+			}
+			views[i].Count = &count
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, views)
+}
+
+func (vc *ViewController) CreateView(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+
+	var req ViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "View name is required")
+		return
+	}
+
+	view := models.SavedView{
+		ID:         uuid.New(),
+		Name:       req.Name,
+		OwnerID:    viewer.UserID,
+		Visibility: models.VisibilityPrivate,
+	}
+	if req.Query != nil {
+		view.Query = *req.Query
+	}
+	if req.SortBy != nil {
+		view.SortBy = *req.SortBy
+	}
+	if req.SortOrder != nil {
+		view.SortOrder = *req.SortOrder
+	}
+	if req.Visibility != "" {
+		view.Visibility = req.Visibility
+		view.TeamID = req.TeamID
+	}
+
+	if !vc.validate(w, &view, viewer) {
+		return
+	}
+
+	if err := vc.db.Create(&view).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create view")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, view)
+}
+
+func (vc *ViewController) UpdateView(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+
+	var req ViewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	view, ok := vc.editableView(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	if req.Name != "" {
+		view.Name = req.Name
+	}
+	if req.Query != nil {
+		view.Query = *req.Query
+	}
+	if req.SortBy != nil {
+		view.SortBy = *req.SortBy
+	}
+	if req.SortOrder != nil {
+		view.SortOrder = *req.SortOrder
+	}
+	if req.Visibility != "" {
+		view.Visibility = req.Visibility
+		view.TeamID = req.TeamID
+	}
+
+	if !vc.validate(w, view, viewer) {
+		return
+	}
+
+	if err := vc.db.Model(view).Select("name", "query", "sort_by", "sort_order", "visibility", "team_id").
+		Updates(view).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update view")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, view)
+}
+
+func (vc *ViewController) DeleteView(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+
+	view, ok := vc.editableView(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	if err := vc.db.Delete(view).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete view")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "View deleted successfully"})
+}
+
+// GetViewTickets runs a view for the current user. Paging parameters work
+// as on GET /api/tickets, and `sort_by`/`sort_order` override the view's.
+func (vc *ViewController) GetViewTickets(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerFromRequest(r)
+
+	var view models.SavedView
+	if err := vc.visibleViews(viewer).First(&view, "id = ?", utils.GetURLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "View not found")
+		return
+	}
+
+	q, err := query.Parse(view.Query)
+	if err != nil {
+		writeQueryError(w, err)
+		return
+	}
+
+	sortBy, sortOrder := view.SortBy, view.SortOrder
+	if r.URL.Query().Get("sort_by") != "" {
+		sortBy, sortOrder = r.URL.Query().Get("sort_by"), r.URL.Query().Get("sort_order")
+	}
+
+	tickets := q.Apply(query.Visible(vc.db.Model(&models.Ticket{}), viewer), viewer)
+	writeTicketList(w, r, tickets, sortBy, sortOrder)
+}
+
+// visibleViews scopes saved views to those the viewer owns, those shared
+// with everyone and those shared with one of the viewer's teams
+func (vc *ViewController) visibleViews(viewer query.Viewer) *gorm.DB {
+	return query.VisibleViews(vc.db, viewer)
+}
+
+// editableView loads the view named in the URL if the viewer may change it:
+// owners can edit their views and admins can edit any shared view
+func (vc *ViewController) editableView(w http.ResponseWriter, r *http.Request, viewer query.Viewer) (*models.SavedView, bool) {
+	var view models.SavedView
+	if err := vc.visibleViews(viewer).First(&view, "id = ?", utils.GetURLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "View not found")
+		return nil, false
+	}
+
+	if view.OwnerID != viewer.UserID && viewer.Role != models.RoleAdmin {
+		utils.WriteError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+
+	return &view, true
+}
+
+// validate checks a view before it is saved and writes the error response
+// when it is invalid
+func (vc *ViewController) validate(w http.ResponseWriter, view *models.SavedView, viewer query.Viewer) bool {
+	if _, err := query.Parse(view.Query); err != nil {
+		writeQueryError(w, err)
+		return false
+	}
+
+	if _, err := query.ParseSort(view.SortBy, view.SortOrder); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	switch view.Visibility {
+	case models.VisibilityPrivate, models.VisibilityEveryone:
+		view.TeamID = nil
+	case models.VisibilityTeam:
+		if view.TeamID == nil {
+			utils.WriteError(w, http.StatusBadRequest, "team_id is required for team views")
+			return false
+		}
+
+		var team models.Team
+		if err := vc.db.First(&team, "id = ?", *view.TeamID).Error; err != nil {
+			utils.WriteError(w, http.StatusBadRequest, "Team not found")
+			return false
+		}
+
+		// Only members can share with a team; admins can share with any
+		if viewer.Role != models.RoleAdmin {
+			var members int64
+			vc.db.Table("team_members").Where("team_id = ? AND user_id = ?", team.ID, viewer.UserID).Count(&members)
+			if members == 0 {
+				utils.WriteError(w, http.StatusForbidden, "You are not a member of this team")
+				return false
+			}
+		}
+	default:
+		utils.WriteError(w, http.StatusBadRequest, "visibility must be private, team or everyone")
+		return false
+	}
+
+	return true
+}
+
+func (vc *ViewController) countTickets(view *models.SavedView, viewer query.Viewer) (int64, error) {
+	q, err := query.Parse(view.Query)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	err = q.Apply(query.Visible(vc.db.Model(&models.Ticket{}), viewer), viewer).Count(&count).Error
+	return count, err
+}
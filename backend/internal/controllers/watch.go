@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WatchController lets users follow the comments on tickets they can see
+// but did not open and are not assigned
+type WatchController struct {
+	db *gorm.DB
+}
+
+func NewWatchController(db *gorm.DB) *WatchController {
+	return &WatchController{db: db}
+}
+
+// GetWatch reports whether the caller watches the ticket
+func (wc *WatchController) GetWatch(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := wc.findTicket(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var watchers int64
+	if err := wc.db.Model(&models.TicketWatcher{}).
+		Where("ticket_id = ? AND user_id = ?", ticket.ID, userID).Count(&watchers).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch watch")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]bool{"watching": watchers > 0})
+}
+
+// Watch subscribes the caller to the ticket's comments. Watching twice is
+// not an error.
+func (wc *WatchController) Watch(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := wc.findTicket(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	watcher := models.TicketWatcher{ID: uuid.New(), TicketID: ticket.ID, UserID: userID}
+	if err := wc.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "ticket_id"}, {Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&watcher).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to watch ticket")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]bool{"watching": true})
+}
+
+// Unwatch stops the caller's watch on the ticket
+func (wc *WatchController) Unwatch(w http.ResponseWriter, r *http.Request) {
+	ticket, ok := wc.findTicket(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	if err := wc.db.Where("ticket_id = ? AND user_id = ?", ticket.ID, userID).
+		Delete(&models.TicketWatcher{}).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to unwatch ticket")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]bool{"watching": false})
+}
+
+// findTicket loads the ticket in the URL if the caller can see it
+func (wc *WatchController) findTicket(w http.ResponseWriter, r *http.Request) (*models.Ticket, bool) {
+	var ticket models.Ticket
+	if err := wc.db.Select("id", "created_by_id").First(&ticket, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Ticket not found")
+		return nil, false
+	}
+	if !viewerFromRequest(r).Sees(ticket.CreatedByID) {
+		utils.WriteError(w, http.StatusForbidden, "Access denied")
+		return nil, false
+	}
+	return &ticket, true
+}
@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/utils"
+	"quickdesk-backend/internal/webhooks"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebhookController lets admins register webhooks and browse and replay
+// their deliveries
+type WebhookController struct {
+	db         *gorm.DB
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookController(db *gorm.DB, dispatcher *webhooks.Dispatcher) *WebhookController {
+	return &WebhookController{db: db, dispatcher: dispatcher}
+}
+
+type WebhookRequest struct {
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events"` // e.g. ["ticket.created", "comment.added"]
+	Secret   *string  `json:"secret"` // Generated when empty on create
+	IsActive *bool    `json:"is_active"`
+}
+
+// webhookWithSecret shows a webhook's secret. It is only returned when the
+// secret is created, so it does not leak through later reads.
+type webhookWithSecret struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (wc *WebhookController) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	var hooks []models.Webhook
+	if err := wc.db.Order("name").Find(&hooks).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hooks)
+}
+
+func (wc *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hook)
+}
+
+func (wc *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, _ := utils.GetUserIDFromContext(r)
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hook := models.Webhook{
+		ID:          uuid.New(),
+		IsActive:    true,
+		CreatedByID: userID,
+	}
+	wc.applyWebhookRequest(&hook, &req)
+	if hook.Secret == "" {
+		secret, err := webhooks.NewSecret()
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Failed to generate secret")
+			return
+		}
+		hook.Secret = secret
+	}
+
+	if !wc.validateWebhook(w, r, &hook) {
+		return
+	}
+
+	if err := wc.db.Create(&hook).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, webhookWithSecret{Webhook: &hook, Secret: hook.Secret})
+}
+
+// UpdateWebhook changes a webhook. Enabling a disabled webhook clears its
+// failure count, and its waiting deliveries are sent again.
+func (wc *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+	wasActive := hook.IsActive
+	wc.applyWebhookRequest(hook, &req)
+	if hook.IsActive && !wasActive {
+		hook.ConsecutiveFailures = 0
+		hook.DisabledAt = nil
+		hook.DisabledReason = ""
+	}
+
+	if !wc.validateWebhook(w, r, hook) {
+		return
+	}
+
+	if err := wc.db.Model(hook).
+		Select("name", "url", "events", "secret", "is_active", "consecutive_failures", "disabled_at", "disabled_reason").
+		Updates(hook).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, hook)
+}
+
+// RotateSecret replaces a webhook's secret with a generated one and returns
+// it. Deliveries are signed with the new secret from now on.
+func (wc *WebhookController) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to generate secret")
+		return
+	}
+	if err := wc.db.Model(hook).Update("secret", secret).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to rotate secret")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, webhookWithSecret{Webhook: hook, Secret: secret})
+}
+
+// DeleteWebhook removes a webhook with its delivery history
+func (wc *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := wc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "Webhook deleted successfully"})
+}
+
+// TestWebhook sends a ping to the webhook's URL and returns the delivery
+// with the receiver's response
+func (wc *WebhookController) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := wc.dispatcher.Test(hook)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to send ping")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, delivery)
+}
+
+// GetDeliveries lists a webhook's deliveries newest first, filtered by
+// `status` and `event`
+func (wc *WebhookController) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := wc.findWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	params := r.URL.Query()
+	page, _ := strconv.Atoi(params.Get("page"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	query := wc.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := params.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := params.Get("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := query.Omit("payload").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&deliveries).Error; err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+	})
+}
+
+// GetDelivery returns one delivery with the payload that was sent
+func (wc *WebhookController) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	var delivery models.WebhookDelivery
+	if err := wc.db.First(&delivery, "id = ? AND webhook_id = ?", chi.URLParam(r, "deliveryID"), chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"delivery": delivery,
+		"payload":  json.RawMessage(delivery.Payload),
+	})
+}
+
+// RedeliverDelivery queues a copy of a delivery with the same payload
+func (wc *WebhookController) RedeliverDelivery(w http.ResponseWriter, r *http.Request) {
+	var delivery models.WebhookDelivery
+	if err := wc.db.Select("id").First(&delivery, "id = ? AND webhook_id = ?", chi.URLParam(r, "deliveryID"), chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+
+	copied, err := wc.dispatcher.Redeliver(delivery.ID)
+	if errors.Is(err, webhooks.ErrNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to queue delivery")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, copied)
+}
+
+func (wc *WebhookController) findWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	var hook models.Webhook
+	if err := wc.db.First(&hook, "id = ?", chi.URLParam(r, "id")).Error; err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Webhook not found")
+		return nil, false
+	}
+	return &hook, true
+}
+
+func (wc *WebhookController) applyWebhookRequest(hook *models.Webhook, req *WebhookRequest) {
+	if req.Name != "" {
+		hook.Name = strings.TrimSpace(req.Name)
+	}
+	if req.URL != "" {
+		hook.URL = strings.TrimSpace(req.URL)
+	}
+	if req.Events != nil {
+		events := make([]string, 0, len(req.Events))
+		for _, event := range req.Events {
+			events = append(events, strings.TrimSpace(event))
+		}
+		hook.Events = strings.Join(events, ",")
+	}
+	if req.Secret != nil && *req.Secret != "" {
+		hook.Secret = *req.Secret
+	}
+	if req.IsActive != nil {
+		hook.IsActive = *req.IsActive
+	}
+}
+
+// validateWebhook checks a webhook, writing a 400 response when it is
+// invalid. URLs reaching private addresses are refused; deliveries check
+// again when they connect.
+func (wc *WebhookController) validateWebhook(w http.ResponseWriter, r *http.Request, hook *models.Webhook) bool {
+	if hook.Name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Webhook name is required")
+		return false
+	}
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		utils.WriteError(w, http.StatusBadRequest, "A valid http or https url is required")
+		return false
+	}
+	if err := wc.dispatcher.CheckURL(r.Context(), hook.URL); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid webhook url: "+err.Error())
+		return false
+	}
+	if hook.Events == "" {
+		utils.WriteError(w, http.StatusBadRequest, "At least one event is required")
+		return false
+	}
+	for _, event := range strings.Split(hook.Events, ",") {
+		if !webhooks.ValidEvent(event) {
+			utils.WriteError(w, http.StatusBadRequest, "Unknown event "+event+"; use "+strings.Join(webhooks.EventTypes, ", "))
+			return false
+		}
+	}
+	if len(hook.Secret) < 16 {
+		utils.WriteError(w, http.StatusBadRequest, "Secret must be at least 16 characters")
+		return false
+	}
+	return true
+}
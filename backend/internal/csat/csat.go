@@ -0,0 +1,141 @@
+// Package csat sends customer satisfaction surveys when tickets are
+// resolved and records the answers.
+package csat
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/pkg/auth"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SurveyTTL is how long a survey link stays valid
+const SurveyTTL = 7 * 24 * time.Hour
+
+var (
+	ErrExpired       = errors.New("survey link has expired")
+	ErrInvalidRating = errors.New("rating must be 1 to 5, good or bad")
+)
+
+// Service sends surveys and records responses
+type Service struct {
+	db          *gorm.DB
+	email       *email.EmailService
+	frontendURL string
+}
+
+func NewService(db *gorm.DB, emailService *email.EmailService, frontendURL string) *Service {
+	return &Service{db: db, email: emailService, frontendURL: strings.TrimRight(frontendURL, "/")}
+}
+
+// Handle sends a survey when a ticket's status changes to resolved. It is
+// meant to be subscribed to the ticket event bus.
+func (s *Service) Handle(e events.Event) {
+	if e.Type != events.TicketUpdated || !slices.Contains(e.Changes, "status") {
+		return
+	}
+	go func() {
+		if err := s.Send(e.TicketID); err != nil {
+			log.Printf("csat: survey for ticket %s: %v", e.TicketID, err)
+		}
+	}()
+}
+
+// Send emails a survey for a resolved ticket unless one already went out
+// for this resolution
+func (s *Service) Send(ticketID uuid.UUID) error {
+	var ticket models.Ticket
+	if err := s.db.Preload("CreatedBy").First(&ticket, "id = ?", ticketID).Error; err != nil {
+		return err
+	}
+	if ticket.Status != models.StatusResolved || ticket.ResolvedAt == nil {
+		return nil
+	}
+
+	var sent int64
+	if err := s.db.Model(&models.SatisfactionSurvey{}).
+		Where("ticket_id = ? AND sent_at >= ?", ticket.ID, *ticket.ResolvedAt).
+		Count(&sent).Error; err != nil {
+		return err
+	}
+	if sent > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	survey := models.SatisfactionSurvey{
+		ID:          uuid.New(),
+		TicketID:    ticket.ID,
+		RequesterID: ticket.CreatedByID,
+		AgentID:     ticket.AssignedToID,
+		SentAt:      now,
+		ExpiresAt:   now.Add(SurveyTTL),
+	}
+	token, err := auth.GenerateSurveyToken(survey.ID, survey.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Create(&survey).Error; err != nil {
+		return err
+	}
+
+	return s.email.SendSatisfactionSurveyEmail(email.RecipientOf(&ticket.CreatedBy), &ticket, s.frontendURL+"/csat/"+token)
+}
+
+// ParseRating accepts 1 to 5, or good (5) and bad (1)
+func ParseRating(v string) (int, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	switch v {
+	case "good":
+		return 5, nil
+	case "bad":
+		return 1, nil
+	case "1", "2", "3", "4", "5":
+		return int(v[0] - '0'), nil
+	}
+	return 0, ErrInvalidRating
+}
+
+// Find loads the survey a link token belongs to
+func Find(db *gorm.DB, token string) (*models.SatisfactionSurvey, error) {
+	surveyID, err := auth.ValidateSurveyToken(token)
+	if err != nil {
+		if errors.Is(err, auth.ErrTokenExpired) {
+			return nil, ErrExpired
+		}
+		return nil, err
+	}
+	var survey models.SatisfactionSurvey
+	if err := db.Preload("Ticket").First(&survey, "id = ?", surveyID).Error; err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// Respond records a rating and optional comment. Answering again before
+// the link expires replaces the earlier answer; an empty comment keeps
+// the previous one, so the one-click rating can be followed by a comment.
+func Respond(db *gorm.DB, survey *models.SatisfactionSurvey, rating int, comment string) error {
+	now := time.Now()
+	if now.After(survey.ExpiresAt) {
+		return ErrExpired
+	}
+	if rating < 1 || rating > 5 {
+		return ErrInvalidRating
+	}
+
+	updates := map[string]interface{}{"rating": rating, "responded_at": now}
+	if comment = strings.TrimSpace(comment); comment != "" {
+		updates["comment"] = comment
+	}
+	return db.Model(survey).Updates(updates).Error
+}
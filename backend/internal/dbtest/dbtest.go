@@ -0,0 +1,65 @@
+// Package dbtest opens GORM on a mocked database for package tests
+package dbtest
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// New returns a Postgres GORM handle on a sqlmock connection that is
+// closed when the test ends
+func New(t testing.TB) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, mock
+}
+
+// Args matches arguments of any value and records them, for checking what
+// was written without pinning down column order
+type Args struct {
+	Values []driver.Value
+}
+
+// Match returns n matchers for WithArgs that record into a
+func (a *Args) Match(n int) []driver.Value {
+	matchers := make([]driver.Value, n)
+	for i := range matchers {
+		matchers[i] = recorder{a}
+	}
+	return matchers
+}
+
+// Contains reports whether any recorded argument equals want
+func (a *Args) Contains(want interface{}) bool {
+	for _, v := range a.Values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+type recorder struct {
+	args *Args
+}
+
+func (r recorder) Match(v driver.Value) bool {
+	r.args.Values = append(r.args.Values, v)
+	return true
+}
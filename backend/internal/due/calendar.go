@@ -0,0 +1,100 @@
+package due
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// eventLength is how long a due date blocks in calendar clients
+	eventLength = 30 * time.Minute
+	// icsTime is the UTC date-time format of RFC 5545
+	icsTime = "20060102T150405Z"
+)
+
+// AgentTickets loads the unresolved tickets with a due date assigned to
+// an agent, soonest first
+func AgentTickets(db *gorm.DB, agentID uuid.UUID) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := db.Preload("Category").
+		Where("assigned_to_id = ? AND due_at IS NOT NULL AND status NOT IN ?", agentID, resolved).
+		Order("due_at").
+		Find(&tickets).Error
+	return tickets, err
+}
+
+// Calendar renders tickets as an iCalendar (RFC 5545) feed with one event
+// at each due date. Event UIDs are stable, so clients update moved dates
+// in place.
+func Calendar(name string, tickets []models.Ticket, frontendURL string, now time.Time) []byte {
+	frontendURL = strings.TrimRight(frontendURL, "/")
+
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(fold(fmt.Sprintf(format, args...)))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//QuickDesk//Due tickets//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:%s", escapeText(name))
+	for _, t := range tickets {
+		if t.DueAt == nil {
+			continue
+		}
+		summary := t.Subject
+		if t.IsOverdue(now) {
+			summary = "Overdue: " + summary
+		}
+		url := frontendURL + "/tickets/" + t.ID.String()
+
+		line("BEGIN:VEVENT")
+		line("UID:%s@quickdesk", t.ID)
+		line("DTSTAMP:%s", t.UpdatedAt.UTC().Format(icsTime))
+		line("DTSTART:%s", t.DueAt.UTC().Format(icsTime))
+		line("DTEND:%s", t.DueAt.Add(eventLength).UTC().Format(icsTime))
+		line("SUMMARY:%s", escapeText(summary))
+		line("DESCRIPTION:%s", escapeText(fmt.Sprintf("Priority: %s\nStatus: %s\nCategory: %s\n%s", t.Priority, t.Status, t.Category.Name, url)))
+		line("URL:%s", url)
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// escapeText escapes a TEXT property value
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// fold splits a content line into lines of at most 75 octets, never
+// inside a UTF-8 sequence
+func fold(s string) string {
+	const limit = 75
+	if len(s) <= limit {
+		return s
+	}
+	var b strings.Builder
+	width := limit
+	for len(s) > width {
+		cut := width
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		width = limit - 1 // Continuation lines start with a space
+	}
+	b.WriteString(s)
+	return b.String()
+}
@@ -0,0 +1,69 @@
+// Package due sends reminders for tickets approaching their due date and
+// publishes each agent's due tickets as a calendar feed.
+package due
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/pkg/email"
+
+	"gorm.io/gorm"
+)
+
+// resolved lists the statuses that no longer need a reminder
+var resolved = []models.TicketStatus{models.StatusResolved, models.StatusClosed}
+
+// Reminder emails assignees before their tickets fall due
+type Reminder struct {
+	db    *gorm.DB
+	email *email.EmailService
+	lead  time.Duration
+}
+
+// NewReminder returns a Reminder that writes lead before a ticket's due date
+func NewReminder(db *gorm.DB, emailService *email.EmailService, lead time.Duration) *Reminder {
+	return &Reminder{db: db, email: emailService, lead: lead}
+}
+
+// RunDue reminds the assignee of every unresolved ticket due within the
+// lead time, once per due date. Each ticket is claimed by stamping it
+// before sending, so with several server instances only one of them
+// writes; a failed send releases the claim to retry on the next run.
+func (r *Reminder) RunDue(ctx context.Context, now time.Time) error {
+	var tickets []models.Ticket
+	if err := r.db.WithContext(ctx).Preload("AssignedTo").
+		Where("due_at IS NOT NULL AND due_at <= ? AND due_reminded_at IS NULL", now.Add(r.lead)).
+		Where("assigned_to_id IS NOT NULL AND status NOT IN ?", resolved).
+		Find(&tickets).Error; err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		ticket := &tickets[i]
+		if ticket.AssignedTo == nil {
+			continue // The assignee was deleted
+		}
+
+		claim := r.db.Model(&models.Ticket{}).
+			Where("id = ? AND due_reminded_at IS NULL", ticket.ID).
+			UpdateColumn("due_reminded_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue // Another instance took it
+		}
+
+		err := r.email.SendDueReminderEmail(email.RecipientOf(ticket.AssignedTo), ticket)
+		if err != nil {
+			log.Printf("due: reminder for ticket %s: %v", ticket.ID, err)
+			r.db.Model(&models.Ticket{}).
+				Where("id = ? AND due_reminded_at = ?", ticket.ID, now).
+				UpdateColumn("due_reminded_at", nil)
+		}
+	}
+	return nil
+}
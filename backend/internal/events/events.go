@@ -0,0 +1,74 @@
+// Package events carries ticket activity from the HTTP handlers to the
+// parts of the backend that react to it, such as caches and notifications.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	TicketCreated  Type = "ticket.created"
+	TicketUpdated  Type = "ticket.updated"
+	TicketDeleted  Type = "ticket.deleted"
+	TicketAssigned Type = "ticket.assigned"
+	CommentAdded   Type = "comment.added"
+	// TicketVoted changes only the vote counts, so unlike ticket.updated
+	// it goes to open pages but not to notifications or webhooks
+	TicketVoted Type = "ticket.voted"
+)
+
+// Event describes one change to a ticket. Handlers load whatever else
+// they need from the database.
+type Event struct {
+	Type      Type       `json:"type"`
+	TicketID  uuid.UUID  `json:"ticket_id"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"`
+	Changes   []string   `json:"changes,omitempty"` // Fields changed by a ticket.updated
+	Time      time.Time  `json:"time"`
+}
+
+// Handler reacts to an event. Handlers run on the publishing goroutine, so
+// slow work belongs on a goroutine or queue of its own.
+type Handler func(Event)
+
+// Bus delivers events to every subscribed handler in-process. A nil Bus
+// drops events.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for all events
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish hands an event to every handler, stamping its time when unset
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(e)
+	}
+}
@@ -0,0 +1,474 @@
+package importer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/tickets"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type importer struct {
+	tx     *gorm.DB
+	source string
+}
+
+// importRow creates or updates the record for one row and reports whether
+// it was created
+func (imp *importer) importRow(entity string, row map[string]string) (bool, error) {
+	externalID := row["external_id"]
+	if externalID == "" {
+		return false, invalid("external_id", "is required")
+	}
+
+	switch entity {
+	case EntityUsers:
+		return imp.importUser(externalID, row)
+	case EntityCategories:
+		return imp.importCategory(externalID, row)
+	case EntityTickets:
+		return imp.importTicket(externalID, row)
+	default:
+		return imp.importComment(externalID, row)
+	}
+}
+
+// lookup returns the local ID an external record was imported as
+func (imp *importer) lookup(entity, externalID string) (uuid.UUID, bool) {
+	var record models.ImportRecord
+	err := imp.tx.Where("source = ? AND entity = ? AND external_id = ?", imp.source, entity, externalID).
+		First(&record).Error
+	return record.LocalID, err == nil
+}
+
+// remember records which local row an external record was imported as
+func (imp *importer) remember(entity, externalID string, localID uuid.UUID) error {
+	record := models.ImportRecord{
+		ID:         uuid.New(),
+		Source:     imp.source,
+		Entity:     entity,
+		ExternalID: externalID,
+		LocalID:    localID,
+	}
+	return imp.tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source"}, {Name: "entity"}, {Name: "external_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"local_id", "updated_at"}),
+	}).Create(&record).Error
+}
+
+// find loads the row an external record was imported as. Records whose
+// local row has since been deleted are imported again.
+func (imp *importer) find(entity, externalID string, dest interface{}) bool {
+	id, ok := imp.lookup(entity, externalID)
+	if !ok {
+		return false
+	}
+	return imp.tx.First(dest, "id = ?", id).Error == nil
+}
+
+// timestamps reads the original created_at and updated_at. Missing values
+// are left zero so GORM fills them in.
+func timestamps(row map[string]string) (time.Time, time.Time, error) {
+	var created, updated time.Time
+	var err error
+	if v := row["created_at"]; v != "" {
+		if created, err = parseTime("created_at", v); err != nil {
+			return created, updated, err
+		}
+	}
+	if v := row["updated_at"]; v != "" {
+		if updated, err = parseTime("updated_at", v); err != nil {
+			return created, updated, err
+		}
+	} else {
+		updated = created
+	}
+	return created, updated, nil
+}
+
+// optionalTime reads a timestamp that may be left empty
+func optionalTime(row map[string]string, field string) (*time.Time, error) {
+	if row[field] == "" {
+		return nil, nil
+	}
+	t, err := parseTime(field, row[field])
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// setTimestamps adds the original timestamps to an update
+func setTimestamps(updates map[string]interface{}, created, updated time.Time) {
+	if !created.IsZero() {
+		updates["created_at"] = created
+	}
+	if !updated.IsZero() {
+		updates["updated_at"] = updated
+	}
+}
+
+func (imp *importer) importUser(externalID string, row map[string]string) (bool, error) {
+	email := strings.ToLower(row["email"])
+	if email == "" || !strings.Contains(email, "@") {
+		return false, invalid("email", "a valid email is required")
+	}
+
+	role := models.RoleUser
+	if v := row["role"]; v != "" {
+		role = models.Role(strings.ToLower(v))
+		if !role.IsValid() {
+			return false, invalid("role", "invalid role %q", v)
+		}
+	}
+
+	isActive := true
+	if v := row["is_active"]; v != "" {
+		var err error
+		if isActive, err = parseBool("is_active", v); err != nil {
+			return false, err
+		}
+	}
+
+	created, updated, err := timestamps(row)
+	if err != nil {
+		return false, err
+	}
+
+	// Link to an existing account with the same email instead of failing
+	// on the unique constraint
+	var user models.User
+	found := imp.find(EntityUsers, externalID, &user) ||
+		imp.tx.Where("email = ?", email).First(&user).Error == nil
+
+	if found {
+		// Blank columns keep what the account has, so linking to an agent
+		// or a deactivated account does not demote or reactivate it
+		updates := map[string]interface{}{"email": email}
+		if row["role"] != "" {
+			updates["role"] = role
+		}
+		if row["is_active"] != "" {
+			updates["is_active"] = isActive
+		}
+		if v := row["first_name"]; v != "" {
+			updates["first_name"] = v
+		}
+		if v := row["last_name"]; v != "" {
+			updates["last_name"] = v
+		}
+		setTimestamps(updates, created, updated)
+		if err := imp.tx.Model(&user).Updates(updates).Error; err != nil {
+			return false, err
+		}
+		return false, imp.remember(EntityUsers, externalID, user.ID)
+	}
+
+	if row["first_name"] == "" {
+		return false, invalid("first_name", "is required")
+	}
+
+	// Imported users keep a bcrypt hash from the old system, or get an
+	// unguessable password they have to reset
+	password := row["password_hash"]
+	if !strings.HasPrefix(password, "$2") {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return false, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+		if err != nil {
+			return false, err
+		}
+		password = string(hash)
+	}
+
+	user = models.User{
+		ID:        uuid.New(),
+		Email:     email,
+		Password:  password,
+		FirstName: row["first_name"],
+		LastName:  row["last_name"],
+		Role:      role,
+		IsActive:  isActive,
+		CreatedAt: created,
+		UpdatedAt: updated,
+	}
+	if err := imp.tx.Create(&user).Error; err != nil {
+		return false, err
+	}
+	return true, imp.remember(EntityUsers, externalID, user.ID)
+}
+
+func (imp *importer) importCategory(externalID string, row map[string]string) (bool, error) {
+	name := row["name"]
+	if name == "" {
+		return false, invalid("name", "is required")
+	}
+
+	isActive := true
+	if v := row["is_active"]; v != "" {
+		var err error
+		if isActive, err = parseBool("is_active", v); err != nil {
+			return false, err
+		}
+	}
+
+	created, updated, err := timestamps(row)
+	if err != nil {
+		return false, err
+	}
+
+	var category models.Category
+	found := imp.find(EntityCategories, externalID, &category) ||
+		imp.tx.Where("name = ?", name).First(&category).Error == nil
+
+	if found {
+		// A blank is_active keeps the category's state, so a re-import
+		// does not reactivate a category that was retired since
+		updates := map[string]interface{}{"name": name}
+		if row["is_active"] != "" {
+			updates["is_active"] = isActive
+		}
+		if v := row["description"]; v != "" {
+			updates["description"] = v
+		}
+		if v := row["color"]; v != "" {
+			updates["color"] = v
+		}
+		setTimestamps(updates, created, updated)
+		if err := imp.tx.Model(&category).Updates(updates).Error; err != nil {
+			return false, err
+		}
+		return false, imp.remember(EntityCategories, externalID, category.ID)
+	}
+
+	color := row["color"]
+	if color == "" {
+		color = "#007bff" // Default color
+	}
+
+	category = models.Category{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: row["description"],
+		Color:       color,
+		IsActive:    isActive,
+		CreatedAt:   created,
+		UpdatedAt:   updated,
+	}
+	if err := imp.tx.Create(&category).Error; err != nil {
+		return false, err
+	}
+	return true, imp.remember(EntityCategories, externalID, category.ID)
+}
+
+// resolveUser finds a user by external ID from this source or by email
+func (imp *importer) resolveUser(field, value string) (uuid.UUID, error) {
+	if id, ok := imp.lookup(EntityUsers, value); ok {
+		return id, nil
+	}
+	var user models.User
+	if err := imp.tx.Where("email = ?", strings.ToLower(value)).First(&user).Error; err == nil {
+		return user.ID, nil
+	}
+	return uuid.Nil, invalid(field, "unknown user %q", value)
+}
+
+// resolveCategory finds a category by external ID from this source or by name
+func (imp *importer) resolveCategory(value string) (uuid.UUID, error) {
+	if id, ok := imp.lookup(EntityCategories, value); ok {
+		return id, nil
+	}
+	var category models.Category
+	if err := imp.tx.Where("name = ?", value).First(&category).Error; err == nil {
+		return category.ID, nil
+	}
+	return uuid.Nil, invalid("category", "unknown category %q", value)
+}
+
+func (imp *importer) importTicket(externalID string, row map[string]string) (bool, error) {
+	if row["subject"] == "" {
+		return false, invalid("subject", "is required")
+	}
+
+	status := models.StatusOpen
+	if v := row["status"]; v != "" {
+		status = models.TicketStatus(strings.ToLower(v))
+		if !status.IsValid() {
+			return false, invalid("status", "invalid status %q", v)
+		}
+	}
+
+	priority := models.PriorityMedium
+	if v := row["priority"]; v != "" {
+		priority = models.TicketPriority(strings.ToLower(v))
+		if !priority.IsValid() {
+			return false, invalid("priority", "invalid priority %q", v)
+		}
+	}
+
+	if row["category"] == "" {
+		return false, invalid("category", "is required")
+	}
+	categoryID, err := imp.resolveCategory(row["category"])
+	if err != nil {
+		return false, err
+	}
+
+	if row["created_by"] == "" {
+		return false, invalid("created_by", "is required")
+	}
+	createdByID, err := imp.resolveUser("created_by", row["created_by"])
+	if err != nil {
+		return false, err
+	}
+
+	var assignedToID *uuid.UUID
+	if v := row["assigned_to"]; v != "" {
+		id, err := imp.resolveUser("assigned_to", v)
+		if err != nil {
+			return false, err
+		}
+		assignedToID = &id
+	}
+
+	created, updated, err := timestamps(row)
+	if err != nil {
+		return false, err
+	}
+
+	firstResponseAt, err := optionalTime(row, "first_response_at")
+	if err != nil {
+		return false, err
+	}
+	resolvedAt, err := optionalTime(row, "resolved_at")
+	if err != nil {
+		return false, err
+	}
+	dueAt, err := optionalTime(row, "due_at")
+	if err != nil {
+		return false, err
+	}
+	// Resolved tickets without a resolution time count as resolved when last updated
+	if resolvedAt == nil && status.IsResolved() && !updated.IsZero() {
+		resolvedAt = &updated
+	}
+
+	tags, err := tickets.ResolveTags(imp.tx, strings.Split(row["tags"], ","))
+	if err != nil {
+		return false, err
+	}
+
+	var ticket models.Ticket
+	if imp.find(EntityTickets, externalID, &ticket) {
+		updates := map[string]interface{}{
+			"subject":           row["subject"],
+			"description":       row["description"],
+			"status":            status,
+			"priority":          priority,
+			"category_id":       categoryID,
+			"created_by_id":     createdByID,
+			"assigned_to_id":    assignedToID,
+			"first_response_at": firstResponseAt,
+			"resolved_at":       resolvedAt,
+			"due_at":            dueAt,
+		}
+		setTimestamps(updates, created, updated)
+		if err := imp.tx.Model(&ticket).Updates(updates).Error; err != nil {
+			return false, err
+		}
+		if err := imp.tx.Model(&ticket).Association("Tags").Replace(tags); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	ticket = models.Ticket{
+		ID:              uuid.New(),
+		Subject:         row["subject"],
+		Description:     row["description"],
+		Status:          status,
+		Priority:        priority,
+		CreatedByID:     createdByID,
+		AssignedToID:    assignedToID,
+		CategoryID:      categoryID,
+		Tags:            tags,
+		FirstResponseAt: firstResponseAt,
+		ResolvedAt:      resolvedAt,
+		DueAt:           dueAt,
+		CreatedAt:       created,
+		UpdatedAt:       updated,
+	}
+	if err := imp.tx.Create(&ticket).Error; err != nil {
+		return false, err
+	}
+	return true, imp.remember(EntityTickets, externalID, ticket.ID)
+}
+
+func (imp *importer) importComment(externalID string, row map[string]string) (bool, error) {
+	if row["content"] == "" {
+		return false, invalid("content", "is required")
+	}
+
+	if row["ticket"] == "" {
+		return false, invalid("ticket", "is required")
+	}
+	ticketID, ok := imp.lookup(EntityTickets, row["ticket"])
+	if !ok {
+		return false, invalid("ticket", "unknown ticket %q, import tickets first", row["ticket"])
+	}
+
+	if row["user"] == "" {
+		return false, invalid("user", "is required")
+	}
+	userID, err := imp.resolveUser("user", row["user"])
+	if err != nil {
+		return false, err
+	}
+
+	isInternal := false
+	if v := row["is_internal"]; v != "" {
+		if isInternal, err = parseBool("is_internal", v); err != nil {
+			return false, err
+		}
+	}
+
+	created, updated, err := timestamps(row)
+	if err != nil {
+		return false, err
+	}
+
+	var comment models.Comment
+	if imp.find(EntityComments, externalID, &comment) {
+		updates := map[string]interface{}{
+			"content":     row["content"],
+			"ticket_id":   ticketID,
+			"user_id":     userID,
+			"is_internal": isInternal,
+		}
+		setTimestamps(updates, created, updated)
+		return false, imp.tx.Model(&comment).Updates(updates).Error
+	}
+
+	comment = models.Comment{
+		ID:         uuid.New(),
+		Content:    row["content"],
+		TicketID:   ticketID,
+		UserID:     userID,
+		IsInternal: isInternal,
+		CreatedAt:  created,
+		UpdatedAt:  updated,
+	}
+	if err := imp.tx.Create(&comment).Error; err != nil {
+		return false, err
+	}
+	return true, imp.remember(EntityComments, externalID, comment.ID)
+}
@@ -0,0 +1,271 @@
+// Package importer loads users, categories, tickets and comments exported
+// from another helpdesk. Imports are keyed on the source system's IDs so
+// re-running one updates the rows it created instead of duplicating them.
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	EntityUsers      = "users"
+	EntityCategories = "categories"
+	EntityTickets    = "tickets"
+	EntityComments   = "comments"
+)
+
+// DefaultSource names the external system when none is given
+const DefaultSource = "default"
+
+// Options describe one import run
+type Options struct {
+	Entity string
+	Format string // csv or json
+	// Source names the external system; external IDs are unique per source
+	Source string
+	// Mapping maps target fields to source columns. Fields without an
+	// entry are read from the column of the same name.
+	Mapping map[string]string
+	// DryRun validates every row and rolls back instead of committing
+	DryRun bool
+}
+
+// RowError is a validation or write failure for one input record.
+// Rows are numbered from 1 in input order, not counting a CSV header.
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Result summarises an import run
+type Result struct {
+	Entity  string     `json:"entity"`
+	Source  string     `json:"source"`
+	DryRun  bool       `json:"dry_run"`
+	Total   int        `json:"total"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors"`
+}
+
+// Fields lists the target fields of each entity. `external_id` is
+// required everywhere; references to other records accept their external
+// ID from the same source or a natural key (email, category name).
+var Fields = map[string][]string{
+	EntityUsers:      {"external_id", "email", "first_name", "last_name", "role", "is_active", "password_hash", "created_at", "updated_at"},
+	EntityCategories: {"external_id", "name", "description", "color", "is_active", "created_at", "updated_at"},
+	EntityTickets:    {"external_id", "subject", "description", "status", "priority", "category", "created_by", "assigned_to", "tags", "first_response_at", "resolved_at", "due_at", "created_at", "updated_at"},
+	EntityComments:   {"external_id", "ticket", "user", "content", "is_internal", "created_at", "updated_at"},
+}
+
+// fieldError is returned by row importers for invalid values
+type fieldError struct {
+	field string
+	msg   string
+}
+
+func (e *fieldError) Error() string {
+	return e.field + ": " + e.msg
+}
+
+func invalid(field, format string, args ...interface{}) error {
+	return &fieldError{field: field, msg: fmt.Sprintf(format, args...)}
+}
+
+// Run imports every record read from r. Row failures are collected in the
+// result and do not stop the run; the returned error is reserved for
+// unreadable input and database failures.
+func Run(db *gorm.DB, r io.Reader, opts Options) (*Result, error) {
+	fields, ok := Fields[opts.Entity]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity %q, expected users, categories, tickets or comments", opts.Entity)
+	}
+	for field := range opts.Mapping {
+		if !contains(fields, field) {
+			return nil, fmt.Errorf("mapping names unknown %s field %q", opts.Entity, field)
+		}
+	}
+	if opts.Source == "" {
+		opts.Source = DefaultSource
+	}
+
+	records, err := readRecords(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Entity: opts.Entity, Source: opts.Source, DryRun: opts.DryRun, Total: len(records), Errors: []RowError{}}
+	errRollback := errors.New("dry run")
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		imp := &importer{tx: tx, source: opts.Source}
+		for i, raw := range records {
+			row := mapRow(raw, fields, opts.Mapping)
+
+			// Each row gets a savepoint so one failure does not abort the rest
+			savepoint := fmt.Sprintf("import_row_%d", i)
+			if err := tx.SavePoint(savepoint).Error; err != nil {
+				return err
+			}
+
+			created, err := imp.importRow(opts.Entity, row)
+			if err != nil {
+				if rbErr := tx.RollbackTo(savepoint).Error; rbErr != nil {
+					return rbErr
+				}
+				rowErr := RowError{Row: i + 1, Message: err.Error()}
+				var fe *fieldError
+				if errors.As(err, &fe) {
+					rowErr.Field, rowErr.Message = fe.field, fe.msg
+				}
+				result.Errors = append(result.Errors, rowErr)
+				result.Failed++
+				continue
+			}
+
+			if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+
+		if opts.DryRun {
+			return errRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readRecords parses CSV with a header row, or a JSON array of objects
+func readRecords(r io.Reader, format string) ([]map[string]string, error) {
+	switch format {
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading CSV header: %w", err)
+		}
+		for i := range header {
+			header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+		}
+
+		var records []map[string]string
+		for {
+			values, err := reader.Read()
+			if err == io.EOF {
+				return records, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading CSV: %w", err)
+			}
+			record := make(map[string]string, len(header))
+			for i, column := range header {
+				if i < len(values) {
+					record[column] = values[i]
+				}
+			}
+			records = append(records, record)
+		}
+	case "json":
+		var raw []map[string]interface{}
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("reading JSON: %w", err)
+		}
+
+		records := make([]map[string]string, len(raw))
+		for i, object := range raw {
+			records[i] = make(map[string]string, len(object))
+			for key, value := range object {
+				records[i][key] = stringify(value)
+			}
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("unsupported import format %q, expected csv or json", format)
+}
+
+func stringify(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		// Lists such as tags become comma-separated values
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = stringify(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// mapRow renames source columns to target fields
+func mapRow(raw map[string]string, fields []string, mapping map[string]string) map[string]string {
+	row := make(map[string]string, len(fields))
+	for _, field := range fields {
+		column := field
+		if mapped, ok := mapping[field]; ok {
+			column = mapped
+		}
+		if value, ok := raw[column]; ok {
+			row[field] = strings.TrimSpace(value)
+		}
+	}
+	return row
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime accepts RFC 3339, "YYYY-MM-DD HH:MM:SS" and plain dates, all
+// read as UTC when no offset is given
+func parseTime(field, value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, invalid(field, "invalid timestamp %q", value)
+}
+
+func parseBool(field, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "t", "yes", "y", "1":
+		return true, nil
+	case "false", "f", "no", "n", "0":
+		return false, nil
+	}
+	return false, invalid(field, "invalid boolean %q", value)
+}
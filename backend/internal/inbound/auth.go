@@ -0,0 +1,57 @@
+package inbound
+
+import (
+	"regexp"
+	"strings"
+)
+
+var authCommentPattern = regexp.MustCompile(`\([^()]*\)`)
+
+// authenticated reports whether the newest Authentication-Results header
+// written by authservID (RFC 8601) shows the sender owns the From
+// domain: DMARC passed, or DKIM or SPF passed for that domain or a parent
+// of it. A DMARC failure always fails. The mail server must drop
+// Authentication-Results headers carrying its own authserv-id from
+// incoming mail, or senders could write their own.
+func authenticated(results []string, authservID, from string) bool {
+	domain := strings.ToLower(from[strings.LastIndex(from, "@")+1:])
+	for _, header := range results {
+		parts := strings.Split(authCommentPattern.ReplaceAllString(header, ""), ";")
+		if id := strings.Fields(parts[0]); len(id) == 0 || !strings.EqualFold(id[0], authservID) {
+			continue
+		}
+
+		pass := false
+		for _, part := range parts[1:] {
+			fields := strings.Fields(part)
+			if len(fields) == 0 {
+				continue
+			}
+			method, result, _ := strings.Cut(strings.ToLower(fields[0]), "=")
+			properties := map[string]string{}
+			for _, field := range fields[1:] {
+				key, value, _ := strings.Cut(field, "=")
+				properties[strings.ToLower(key)] = strings.ToLower(strings.Trim(value, `"`))
+			}
+			switch {
+			case method == "dmarc" && result == "fail":
+				return false
+			case method == "dmarc" && result == "pass":
+				pass = true
+			case method == "dkim" && result == "pass" && aligned(properties["header.d"], domain):
+				pass = true
+			case method == "spf" && result == "pass" && aligned(properties["smtp.mailfrom"], domain):
+				pass = true
+			}
+		}
+		return pass
+	}
+	return false
+}
+
+// aligned reports whether an authenticated domain, or the domain of an
+// authenticated address, covers the From domain
+func aligned(authenticated, domain string) bool {
+	authenticated = authenticated[strings.LastIndex(authenticated, "@")+1:]
+	return authenticated != "" && (authenticated == domain || strings.HasSuffix(domain, "."+authenticated))
+}
@@ -0,0 +1,37 @@
+package inbound
+
+import "testing"
+
+func TestAuthenticated(t *testing.T) {
+	const from = "alex@example.com"
+	tests := []struct {
+		name    string
+		results []string
+		want    bool
+	}{
+		{"dmarc pass", []string{"mx.local; dmarc=pass header.from=example.com"}, true},
+		{"aligned dkim", []string{"mx.local; dkim=pass (2048-bit key) header.d=example.com header.s=sel"}, true},
+		{"aligned spf", []string{"mx.local 1; spf=pass smtp.mailfrom=bounce@example.com"}, true},
+		{"quoted values", []string{`mx.local; spf=pass smtp.mailfrom="alex@example.com"`}, true},
+		{"dkim of another domain", []string{"mx.local; dkim=pass header.d=mailer.net"}, false},
+		{"spf of another domain", []string{"mx.local; spf=pass smtp.mailfrom=bounce@mailer.net"}, false},
+		{"lookalike domain", []string{"mx.local; dkim=pass header.d=ample.com"}, false},
+		{"everything failed", []string{"mx.local; spf=fail smtp.mailfrom=example.com; dkim=fail header.d=example.com"}, false},
+		{"dmarc fail beats dkim", []string{"mx.local; dkim=pass header.d=example.com; dmarc=fail header.from=example.com"}, false},
+		{"no results", []string{"mx.local; none"}, false},
+		{"no header", nil, false},
+		{"another server", []string{"mx.attacker.net; dmarc=pass header.from=example.com"}, false},
+		// Only the newest header from our server counts
+		{"older pass", []string{"mx.local; dkim=fail header.d=example.com", "mx.local; dkim=pass header.d=example.com"}, false},
+		{"forged header below ours", []string{"mx.attacker.net; dmarc=pass", "MX.LOCAL; dkim=pass header.d=example.com"}, true},
+	}
+	for _, tt := range tests {
+		if got := authenticated(tt.results, "mx.local", from); got != tt.want {
+			t.Errorf("%s: authenticated = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if !authenticated([]string{"mx.local; dkim=pass header.d=example.com"}, "mx.local", "alex@help.example.com") {
+		t.Error("DKIM of a parent domain does not cover a subdomain sender")
+	}
+}
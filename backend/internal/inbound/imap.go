@@ -0,0 +1,254 @@
+package inbound
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+)
+
+// IMAPConfig is the mailbox a Poller reads
+type IMAPConfig struct {
+	Host     string
+	Port     string
+	TLS      bool // Implicit TLS; false is for local test servers
+	User     string
+	Password string
+	Mailbox  string
+}
+
+// Poller fetches unseen messages from an IMAP mailbox and hands them to
+// a Processor. Messages are flagged seen once processed, rejected or found
+// malformed, so a bad message is logged once instead of on every poll.
+// Other failures, such as the database being down, leave the message
+// unseen and end the poll, so the next poll tries it again.
+type Poller struct {
+	imap      IMAPConfig
+	processor *Processor
+}
+
+func NewPoller(cfg IMAPConfig, processor *Processor) *Poller {
+	return &Poller{imap: cfg, processor: processor}
+}
+
+// Poll processes the mailbox's unseen messages. It fits scheduler.Job.
+func (p *Poller) Poll(ctx context.Context, now time.Time) error {
+	c, err := dialIMAP(ctx, p.imap)
+	if err != nil {
+		return err
+	}
+	defer c.logout()
+
+	if err := c.login(p.imap.User, p.imap.Password); err != nil {
+		return err
+	}
+	if _, err := c.command("SELECT " + quote(p.imap.Mailbox)); err != nil {
+		return err
+	}
+
+	uids, err := c.searchUnseen()
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		raw, err := c.fetch(uid)
+		if err != nil {
+			return err
+		}
+		record, err := p.processor.Process(raw)
+		switch {
+		case errors.Is(err, ErrMalformed):
+			log.Printf("inbound: message %d in %s: %v", uid, p.imap.Mailbox, err)
+		case err != nil && !errors.Is(err, ErrDuplicate):
+			return fmt.Errorf("message %d in %s: %w", uid, p.imap.Mailbox, err)
+		case err == nil && record.Status == models.InboundRejected:
+			log.Printf("inbound: rejected %s from %s: %s", record.MessageID, record.From, record.Error)
+		}
+		if _, err := c.command(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imapConn speaks the few IMAP4rev1 commands polling needs
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+const imapTimeout = 30 * time.Second
+
+func dialIMAP(ctx context.Context, cfg IMAPConfig) (*imapConn, error) {
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	dialer := &net.Dialer{Timeout: imapTimeout}
+
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: cfg.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	greeting, err := c.r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(greeting, "* OK") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting %q: %v", strings.TrimSpace(greeting), err)
+	}
+	return c, nil
+}
+
+func (c *imapConn) login(user, password string) error {
+	_, err := c.command("LOGIN " + quote(user) + " " + quote(password))
+	if err != nil {
+		return errors.New("IMAP login failed")
+	}
+	return nil
+}
+
+func (c *imapConn) logout() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
+
+var searchPattern = regexp.MustCompile(`^\* SEARCH\b(.*)$`)
+
+func (c *imapConn) searchUnseen() ([]uint64, error) {
+	lines, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint64
+	for _, line := range lines {
+		m := searchPattern.FindStringSubmatch(string(line))
+		if m == nil {
+			continue
+		}
+		for _, field := range strings.Fields(m[1]) {
+			if uid, err := strconv.ParseUint(field, 10, 32); err == nil {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	return uids, nil
+}
+
+// fetch reads a message without flagging it seen
+func (c *imapConn) fetch(uid uint64) ([]byte, error) {
+	lines, err := c.command(fmt.Sprintf("UID FETCH %d BODY.PEEK[]", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		if len(line) > 0 && line[0] == '*' && strings.Contains(string(line), "FETCH") {
+			if i := strings.Index(string(line), "\r\n"); i >= 0 && strings.HasSuffix(string(line[:i]), "}") {
+				return literalOf(line, i)
+			}
+		}
+	}
+	return nil, fmt.Errorf("message %d not found", uid)
+}
+
+var literalPattern = regexp.MustCompile(`\{(\d+)\}$`)
+
+// literalOf cuts the literal announced at the end of a response's first
+// line out of the response
+func literalOf(response []byte, lineEnd int) ([]byte, error) {
+	m := literalPattern.FindSubmatch(response[:lineEnd])
+	if m == nil {
+		return nil, errors.New("malformed FETCH response")
+	}
+	size, _ := strconv.Atoi(string(m[1]))
+	start := lineEnd + 2
+	if start+size > len(response) {
+		return nil, errors.New("truncated FETCH response")
+	}
+	return response[start : start+size], nil
+}
+
+// maxLiteral bounds a single message fetched from the server
+const maxLiteral = 50 << 20
+
+// command sends a tagged command and returns its untagged responses, each
+// with any literals inlined. A response other than OK is an error.
+func (c *imapConn) command(cmd string) ([][]byte, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(imapTimeout))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+
+	var responses [][]byte
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		line := string(response)
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimSpace(strings.TrimPrefix(line, tag+" "))
+			if !strings.HasPrefix(status, "OK") {
+				verb := strings.Fields(cmd)[0]
+				return nil, fmt.Errorf("IMAP %s: %s", verb, status)
+			}
+			return responses, nil
+		}
+		responses = append(responses, response)
+	}
+}
+
+// readResponse reads one response line, following any literals it ends
+// with. Line endings inside the response are kept as CRLF.
+func (c *imapConn) readResponse() ([]byte, error) {
+	var response []byte
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		response = append(response, line...)
+
+		m := literalPattern.FindStringSubmatch(line)
+		if m == nil {
+			return response, nil
+		}
+		size, err := strconv.Atoi(m[1])
+		if err != nil || size > maxLiteral {
+			return nil, fmt.Errorf("IMAP literal too large: %s", m[1])
+		}
+		c.conn.SetDeadline(time.Now().Add(imapTimeout + time.Duration(size/(64<<10))*time.Second))
+		response = append(response, "\r\n"...)
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return nil, err
+		}
+		response = append(response, literal...)
+	}
+}
+
+// quote writes an IMAP quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,346 @@
+// Package inbound turns emails sent to the support mailbox into tickets
+// and threads replies onto them as comments. Messages arrive from an
+// IMAP mailbox or as raw RFC 5322 posted by a mail server.
+package inbound
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/tickets"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrDuplicate is returned for a message that was already processed
+var ErrDuplicate = errors.New("message was already processed")
+
+// ErrMalformed is returned for a message that cannot be parsed. Other
+// errors, such as the database being down, are worth retrying.
+var ErrMalformed = errors.New("malformed message")
+
+// Options configure how emails become tickets
+type Options struct {
+	// AutoProvision creates requester accounts for unknown senders;
+	// otherwise their mail is rejected
+	AutoProvision bool
+	// Category is the ID or name of the category new tickets go to. Empty
+	// picks the oldest active category.
+	Category string
+	// AttachmentDir is where attachment files are stored
+	AttachmentDir string
+	// Threads checks the signed ticket tags replies carry. Only a reply
+	// with one may comment on a ticket its sender did not open.
+	Threads email.Threads
+	// AuthServID is the authserv-id our mail server writes into
+	// Authentication-Results. When set, only mail that passed its SPF,
+	// DKIM or DMARC checks for the From domain is accepted.
+	AuthServID string
+}
+
+// Processor turns parsed messages into tickets and comments
+type Processor struct {
+	db     *gorm.DB
+	events *events.Bus
+	opts   Options
+}
+
+func NewProcessor(db *gorm.DB, bus *events.Bus, opts Options) *Processor {
+	return &Processor{db: db, events: bus, opts: opts}
+}
+
+// Process stores a raw message as a new ticket or a comment on the ticket
+// it replies to and logs it. Rejected mail is logged with the reason and
+// returned without an error; a message seen before returns its earlier
+// log entry with ErrDuplicate, and one that cannot be parsed ErrMalformed.
+func (p *Processor) Process(raw []byte) (*models.InboundEmail, error) {
+	msg, err := Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	var previous models.InboundEmail
+	if err := p.db.Where("message_id = ?", msg.MessageID).First(&previous).Error; err == nil {
+		return &previous, ErrDuplicate
+	}
+
+	record := &models.InboundEmail{
+		ID:         uuid.New(),
+		MessageID:  msg.MessageID,
+		From:       msg.From.Address,
+		Subject:    msg.Subject,
+		ReceivedAt: time.Now(),
+	}
+
+	if msg.Automatic {
+		return p.reject(record, "automatic reply or bulk mail")
+	}
+	if p.opts.AuthServID != "" && !authenticated(msg.AuthResults, p.opts.AuthServID, msg.From.Address) {
+		return p.reject(record, "sender authentication failed")
+	}
+
+	user, err := p.sender(msg)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return p.reject(record, "unknown sender")
+	}
+	if !user.IsActive {
+		return p.reject(record, "sender account is inactive")
+	}
+	record.UserID = &user.ID
+
+	ticket, err := p.thread(msg, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var event events.Event
+	var written []string
+	err = p.db.Transaction(func(tx *gorm.DB) error {
+		var commentID *uuid.UUID
+		if ticket != nil {
+			comment, err := tickets.AddComment(tx, ticket, user, orPlaceholder(StripQuoted(msg.Text)), false)
+			if err != nil {
+				return err
+			}
+			commentID = &comment.ID
+			record.Status, record.TicketID, record.CommentID = models.InboundComment, &ticket.ID, &comment.ID
+			event = events.Event{Type: events.CommentAdded, TicketID: ticket.ID, ActorID: user.ID, CommentID: &comment.ID}
+		} else {
+			categoryID, err := p.category(tx)
+			if err != nil {
+				return err
+			}
+			subject := msg.Subject
+			if subject == "" {
+				subject = "(no subject)"
+			}
+			ticket, err = tickets.Create(tx, tickets.NewTicket{
+				Subject:     subject,
+				Description: orPlaceholder(msg.Text),
+				CategoryID:  categoryID,
+				CreatedByID: user.ID,
+			})
+			if err != nil {
+				return err
+			}
+			record.Status, record.TicketID = models.InboundTicket, &ticket.ID
+			event = events.Event{Type: events.TicketCreated, TicketID: ticket.ID, ActorID: user.ID}
+		}
+
+		for _, a := range msg.Attachments {
+			path, err := p.store(tx, ticket.ID, commentID, user.ID, a)
+			if path != "" {
+				written = append(written, path)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		for _, path := range written {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+
+	p.events.Publish(event)
+	return record, nil
+}
+
+// reject logs a message that is not turned into a ticket
+func (p *Processor) reject(record *models.InboundEmail, reason string) (*models.InboundEmail, error) {
+	record.Status = models.InboundRejected
+	record.Error = reason
+	if err := p.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// sender finds the user a message is from, creating a requester account
+// when auto-provisioning is on. A nil user means the sender is unknown.
+func (p *Processor) sender(msg *Message) (*models.User, error) {
+	address := strings.ToLower(msg.From.Address)
+
+	var user models.User
+	err := p.db.Where("LOWER(email) = ?", address).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !p.opts.AutoProvision {
+		return nil, nil
+	}
+
+	// Provisioned requesters get an unguessable password they have to reset
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := splitName(msg.From.Name)
+	if first == "" {
+		first = address[:strings.Index(address, "@")]
+	}
+	user = models.User{
+		ID:        uuid.New(),
+		Email:     address,
+		Password:  string(hash),
+		FirstName: first,
+		LastName:  last,
+		Role:      models.RoleUser,
+		IsActive:  true,
+	}
+	if err := p.db.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// thread finds the ticket a message replies to: by the subject token, the
+// ticket's reply address, or a referenced notification or earlier message.
+// The From header is easy to forge, so a sender can only reply to a ticket
+// they did not open when the reply carries a signed tag from mail we sent,
+// and requesters only ever to their own. Anything else opens a new ticket.
+func (p *Processor) thread(msg *Message, user *models.User) (*models.Ticket, error) {
+	var ticketID *uuid.UUID
+	verified := false
+	match := func(id uuid.UUID, signed bool) {
+		if ticketID == nil || signed && !verified {
+			ticketID, verified = &id, signed
+		}
+	}
+	if id, signed, ok := p.opts.Threads.FromSubject(msg.Subject); ok {
+		match(id, signed)
+	}
+	for _, address := range msg.Recipients {
+		if id, signed, ok := p.opts.Threads.FromAddress(address); ok {
+			match(id, signed)
+		}
+	}
+	references := append(append([]string{}, msg.InReplyTo...), msg.References...)
+	for _, reference := range references {
+		if id, signed, ok := p.opts.Threads.FromMessageID(reference); ok {
+			match(id, signed)
+		}
+	}
+	if ticketID == nil {
+		if len(references) > 0 {
+			var earlier models.InboundEmail
+			err := p.db.Where("message_id IN ? AND ticket_id IS NOT NULL", references).
+				Order("received_at DESC").First(&earlier).Error
+			if err == nil {
+				ticketID = earlier.TicketID
+			}
+		}
+	}
+	if ticketID == nil {
+		return nil, nil
+	}
+
+	var ticket models.Ticket
+	if err := p.db.First(&ticket, "id = ?", *ticketID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if (!verified || user.Role == models.RoleUser) && ticket.CreatedByID != user.ID {
+		return nil, nil
+	}
+	return &ticket, nil
+}
+
+// category resolves the configured category for new tickets
+func (p *Processor) category(db *gorm.DB) (uuid.UUID, error) {
+	var category models.Category
+	query := db.Where("is_active = ?", true)
+	if p.opts.Category != "" {
+		if id, err := uuid.Parse(p.opts.Category); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("name ILIKE ?", p.opts.Category)
+		}
+	}
+	if err := query.Order("created_at").First(&category).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("no category for inbound email: %w", err)
+	}
+	return category.ID, nil
+}
+
+// store writes an attachment below the attachment directory and records
+// it. The returned path is set once the file exists.
+func (p *Processor) store(db *gorm.DB, ticketID uuid.UUID, commentID *uuid.UUID, userID uuid.UUID, a Attachment) (string, error) {
+	attachment := models.Attachment{
+		ID:        uuid.New(),
+		FileName:  a.FileName,
+		FileSize:  int64(len(a.Data)),
+		MimeType:  a.ContentType,
+		TicketID:  ticketID,
+		CommentID: commentID,
+		UserID:    userID,
+	}
+
+	dir := filepath.Join(p.opts.AttachmentDir, ticketID.String())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	attachment.FilePath = filepath.Join(dir, attachment.ID.String()+"-"+safeFileName(a.FileName))
+	if err := os.WriteFile(attachment.FilePath, a.Data, 0o644); err != nil {
+		return "", err
+	}
+	return attachment.FilePath, db.Create(&attachment).Error
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeFileName keeps a stored file name readable without letting it
+// leave its directory
+func safeFileName(name string) string {
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(filepath.Base(name), "_"), "._")
+	if len(name) > 100 {
+		name = name[len(name)-100:]
+	}
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+func splitName(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, " "); i > 0 {
+		return strings.TrimSpace(name[:i]), name[i+1:]
+	}
+	return name, ""
+}
+
+func orPlaceholder(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return "(no content)"
+	}
+	return text
+}
@@ -0,0 +1,127 @@
+package inbound
+
+import (
+	"strings"
+	"testing"
+
+	"quickdesk-backend/internal/dbtest"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// newTestProcessor returns a Processor on a mock database. Its Threads
+// match the tags in mail sent by email.NewWithTransport.
+func newTestProcessor(t *testing.T) (*Processor, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := dbtest.New(t)
+	return NewProcessor(db, nil, Options{Threads: email.NewThreads("")}), mock
+}
+
+// notification sends a ticket email the way notifications are sent and
+// returns its Message-ID, Reply-To and subject
+func notification(t *testing.T, ticketID uuid.UUID) (string, string, string) {
+	t.Helper()
+	transport := email.NewMemoryTransport()
+	es := email.NewWithTransport(nil, transport, "noreply@example.com", "support@example.com", "")
+	ticket := &models.Ticket{ID: ticketID, Subject: "Printer jam", Status: models.StatusOpen, Priority: models.PriorityLow}
+	if err := es.SendTicketUpdatedEmail(email.Recipient{Email: "someone@example.com"}, ticket); err != nil {
+		t.Fatal(err)
+	}
+	sent := transport.Messages()[0]
+	return strings.Trim(sent.Header.Get("Message-ID"), "<>"), sent.Header.Get("Reply-To"), sent.Subject
+}
+
+func expectTicket(mock sqlmock.Sqlmock, id, createdBy uuid.UUID) {
+	mock.ExpectQuery(`SELECT \* FROM "tickets"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_by_id"}).AddRow(id, createdBy))
+}
+
+func TestThread(t *testing.T) {
+	requester := &models.User{ID: uuid.New(), Role: models.RoleUser}
+	otherRequester := &models.User{ID: uuid.New(), Role: models.RoleUser}
+	agent := &models.User{ID: uuid.New(), Role: models.RoleAgent}
+	ticketID := uuid.New()
+	messageID, replyTo, subject := notification(t, ticketID)
+	unsigned := "ticket." + ticketID.String() + "@example.com"
+	unsignedSubject := "Re: [QD#" + ticketID.String() + "] Printer jam"
+
+	tests := []struct {
+		name   string
+		msg    *Message
+		sender *models.User
+		want   bool
+	}{
+		{"requester replying", &Message{InReplyTo: []string{messageID}}, requester, true},
+		{"requester by reply address", &Message{Recipients: []string{replyTo}}, requester, true},
+		{"requester with an unsigned reference", &Message{References: []string{unsigned}}, requester, true},
+		{"agent replying", &Message{InReplyTo: []string{messageID}}, agent, true},
+		{"agent by reply address", &Message{Recipients: []string{"support@example.com", replyTo}}, agent, true},
+		{"requester by subject token", &Message{Subject: "Re: " + subject}, requester, true},
+		{"agent by subject token", &Message{Subject: "AW: " + subject}, agent, true},
+		{"requester with an unsigned subject token", &Message{Subject: unsignedSubject}, requester, true},
+		{"signed tag wins over unsigned", &Message{InReplyTo: []string{unsigned}, References: []string{messageID}}, agent, true},
+		// From is easy to forge; without a signed tag only the requester may reply
+		{"agent with an unsigned reference", &Message{InReplyTo: []string{unsigned}}, agent, false},
+		{"agent with an unsigned subject token", &Message{Subject: unsignedSubject}, agent, false},
+		{"another requester by subject token", &Message{Subject: "Re: " + subject}, otherRequester, false},
+		{"agent with an unsigned reply address", &Message{Recipients: []string{"support+" + ticketID.String() + "@example.com"}}, agent, false},
+		{"another requester replying", &Message{InReplyTo: []string{messageID}}, otherRequester, false},
+	}
+	for _, tt := range tests {
+		p, mock := newTestProcessor(t)
+		expectTicket(mock, ticketID, requester.ID)
+
+		ticket, err := p.thread(tt.msg, tt.sender)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := ticket != nil && ticket.ID == ticketID; got != tt.want {
+			t.Errorf("%s: threaded onto the ticket = %v, want %v", tt.name, got, tt.want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestThreadByEarlierMessage(t *testing.T) {
+	requester := &models.User{ID: uuid.New(), Role: models.RoleUser}
+	agent := &models.User{ID: uuid.New(), Role: models.RoleAgent}
+	ticketID := uuid.New()
+	msg := &Message{References: []string{"first@mail.example.com"}}
+
+	for _, sender := range []*models.User{requester, agent} {
+		p, mock := newTestProcessor(t)
+		mock.ExpectQuery(`SELECT \* FROM "inbound_emails" WHERE message_id IN \(\$1\) AND ticket_id IS NOT NULL`).
+			WithArgs("first@mail.example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "ticket_id"}).AddRow(uuid.New(), ticketID))
+		expectTicket(mock, ticketID, requester.ID)
+
+		ticket, err := p.thread(msg, sender)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Our earlier messages are no proof the sender took part
+		if want := sender == requester; (ticket != nil) != want {
+			t.Errorf("%s: threaded = %v, want %v", sender.Role, ticket != nil, want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestThreadWithoutReference(t *testing.T) {
+	p, mock := newTestProcessor(t)
+	ticket, err := p.thread(&Message{Recipients: []string{"support@example.com"}}, &models.User{ID: uuid.New(), Role: models.RoleUser})
+	if err != nil || ticket != nil {
+		t.Errorf("thread = %v, %v; want a new ticket", ticket, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,292 @@
+package inbound
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// maxParts bounds the MIME parts read from one message
+const maxParts = 100
+
+// Message is the part of an email the ticket channel uses
+type Message struct {
+	MessageID   string // Without angle brackets
+	InReplyTo   []string
+	References  []string
+	Automatic   bool // Auto-replies, bounces and bulk mail, which must not be answered
+	From        *mail.Address
+	Recipients  []string // Addresses from To, Cc, Delivered-To and X-Original-To
+	AuthResults []string // Authentication-Results headers, newest first
+	Subject     string
+	Text        string // Plain text body, converted from HTML if needed
+	Attachments []Attachment
+}
+
+// Attachment is a file sent with a message
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// Parse reads a raw RFC 5322 message. Messages without a Message-ID get
+// one derived from their content, so re-delivery is still detected.
+func Parse(raw []byte) (*Message, error) {
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	msg := &Message{
+		MessageID:   messageID(m.Header.Get("Message-ID")),
+		InReplyTo:   messageIDs(m.Header.Get("In-Reply-To")),
+		References:  messageIDs(m.Header.Get("References")),
+		AuthResults: m.Header["Authentication-Results"],
+	}
+	if msg.MessageID == "" {
+		sum := sha256.Sum256(raw)
+		msg.MessageID = hex.EncodeToString(sum[:16]) + "@generated.quickdesk"
+	}
+
+	msg.Subject, err = wordDecoder.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		msg.Subject = m.Header.Get("Subject")
+	}
+	msg.Subject = strings.TrimSpace(msg.Subject)
+
+	auto := strings.ToLower(m.Header.Get("Auto-Submitted"))
+	precedence := strings.ToLower(m.Header.Get("Precedence"))
+	msg.Automatic = (auto != "" && auto != "no") || precedence == "bulk" || precedence == "junk" ||
+		precedence == "list" || precedence == "auto_reply" || m.Header.Get("X-Autoreply") != ""
+
+	// The sender is matched on From; Reply-To is as easy to forge and
+	// often points at a list
+	parser := mail.AddressParser{WordDecoder: wordDecoder}
+	from := m.Header.Get("From")
+	msg.From, err = parser.Parse(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender %q: %w", from, err)
+	}
+
+	for _, header := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		for _, v := range m.Header[header] {
+			if list, err := parser.ParseList(v); err == nil {
+				for _, a := range list {
+					msg.Recipients = append(msg.Recipients, a.Address)
+				}
+			}
+		}
+	}
+
+	var plain, htmlText string
+	parts := 0
+	err = walk(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Header.Get("Content-Disposition"), m.Body,
+		func(contentType, fileName string, body []byte) error {
+			if parts++; parts > maxParts {
+				return errors.New("too many MIME parts")
+			}
+			switch {
+			case fileName != "":
+				msg.Attachments = append(msg.Attachments, Attachment{FileName: fileName, ContentType: contentType, Data: body})
+			case contentType == "text/plain" && plain == "":
+				plain = string(body)
+			case contentType == "text/html" && htmlText == "":
+				htmlText = string(body)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	msg.Text = plain
+	if strings.TrimSpace(msg.Text) == "" {
+		msg.Text = htmlToText(htmlText)
+	}
+	msg.Text = strings.TrimSpace(strings.ReplaceAll(msg.Text, "\r\n", "\n"))
+	return msg, nil
+}
+
+// walk decodes a MIME entity and calls fn for each leaf part with its
+// media type, attachment file name (empty for inline text) and decoded,
+// UTF-8 body
+func walk(contentType, encoding, disposition string, body io.Reader, fn func(contentType, fileName string, body []byte) error) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid multipart body: %w", err)
+			}
+			err = walk(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(encoding, body))
+	if err != nil {
+		return fmt.Errorf("invalid %s body: %w", mediaType, err)
+	}
+
+	fileName := ""
+	if d, dparams, err := mime.ParseMediaType(disposition); err == nil {
+		fileName = dparams["filename"]
+		if fileName == "" && d == "attachment" {
+			fileName = "attachment"
+		}
+	}
+	if fileName == "" {
+		fileName = params["name"]
+	}
+	if fileName != "" {
+		if decoded, err := wordDecoder.DecodeHeader(fileName); err == nil {
+			fileName = decoded
+		}
+		return fn(mediaType, fileName, data)
+	}
+	if mediaType == "message/rfc822" {
+		return fn(mediaType, "message.eml", data)
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return fn(mediaType, "attachment", data)
+	}
+
+	if charset := params["charset"]; charset != "" {
+		if r, err := charsetReader(charset, bytes.NewReader(data)); err == nil {
+			if utf8, err := io.ReadAll(r); err == nil {
+				data = utf8
+			}
+		}
+	}
+	return fn(mediaType, "", data)
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &stripSpace{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// stripSpace drops the line breaks inside base64 bodies
+type stripSpace struct {
+	r io.Reader
+}
+
+func (s *stripSpace) Read(p []byte) (int, error) {
+	for {
+		n, err := s.r.Read(p)
+		kept := 0
+		for _, c := range p[:n] {
+			if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+				p[kept] = c
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "us-ascii", "ascii":
+		return input, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+func messageID(v string) string {
+	ids := messageIDs(v)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+var messageIDPattern = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// messageIDs extracts the IDs in angle brackets from a header
+func messageIDs(v string) []string {
+	var ids []string
+	for _, m := range messageIDPattern.FindAllStringSubmatch(v, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+var (
+	htmlBlocks = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	htmlBreaks = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/tr|/h[1-6])[^>]*>`)
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText reduces an HTML body to readable plain text
+func htmlToText(s string) string {
+	s = htmlBlocks.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+}
+
+var replyHeader = regexp.MustCompile(`(?m)^(On .+ wrote:|-----\s*Original Message\s*-----|From: .+)\s*$`)
+
+// StripQuoted removes the quoted earlier conversation from a reply, so a
+// comment holds only what was written. Text that is only a quote is kept.
+func StripQuoted(text string) string {
+	if loc := replyHeader.FindStringIndex(text); loc != nil && strings.TrimSpace(text[:loc[0]]) != "" {
+		text = text[:loc[0]]
+	}
+
+	var kept []string
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), ">") {
+			kept = append(kept, line)
+		}
+	}
+	stripped := strings.TrimSpace(strings.Join(kept, "\n"))
+	if stripped == "" {
+		return strings.TrimSpace(text)
+	}
+	return stripped
+}
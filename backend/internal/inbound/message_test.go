@@ -0,0 +1,169 @@
+package inbound
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// crlf turns a message written with \n line ends into RFC 5322's \r\n
+func crlf(s string) []byte {
+	return []byte(strings.ReplaceAll(s, "\n", "\r\n"))
+}
+
+func TestParseMultipart(t *testing.T) {
+	raw := crlf(`From: =?UTF-8?Q?J=C3=BCrgen_M=C3=BCller?= <Juergen@Example.com>
+To: Support <support+abc@example.com>, other@example.com
+Cc: boss@example.com
+Subject: =?UTF-8?B?RHJ1Y2tlciBrYXB1dHQ=?=
+Message-ID: <reply-1@mail.example.com>
+In-Reply-To: <ticket.1234@example.com>
+References: <root@example.com> <ticket.1234@example.com>
+Authentication-Results: mx.example.com; dkim=pass header.d=example.com
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+Der Drucker im 2. Stock ist kaputt. Gr=FC=DFe
+
+--inner
+Content-Type: text/html; charset=utf-8
+
+<p>Der Drucker im 2. Stock ist kaputt.</p>
+--inner--
+
+--outer
+Content-Type: image/png
+Content-Disposition: attachment; filename="screen.png"
+Content-Transfer-Encoding: base64
+
+iVBORw0K
+GgoAAAAN
+--outer--
+`)
+
+	msg, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.MessageID != "reply-1@mail.example.com" {
+		t.Errorf("MessageID = %q", msg.MessageID)
+	}
+	if !reflect.DeepEqual(msg.InReplyTo, []string{"ticket.1234@example.com"}) ||
+		!reflect.DeepEqual(msg.References, []string{"root@example.com", "ticket.1234@example.com"}) {
+		t.Errorf("InReplyTo = %v, References = %v", msg.InReplyTo, msg.References)
+	}
+	if msg.From.Name != "Jürgen Müller" || msg.From.Address != "Juergen@Example.com" {
+		t.Errorf("From = %+v", msg.From)
+	}
+	if want := []string{"support+abc@example.com", "other@example.com", "boss@example.com"}; !reflect.DeepEqual(msg.Recipients, want) {
+		t.Errorf("Recipients = %v, want %v", msg.Recipients, want)
+	}
+	if msg.Subject != "Drucker kaputt" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if msg.Text != "Der Drucker im 2. Stock ist kaputt. Grüße" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+	if len(msg.AuthResults) != 1 || msg.AuthResults[0] != "mx.example.com; dkim=pass header.d=example.com" {
+		t.Errorf("AuthResults = %q", msg.AuthResults)
+	}
+	if msg.Automatic {
+		t.Error("a personal message was taken for an automatic one")
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("%d attachments, want 1", len(msg.Attachments))
+	}
+	a := msg.Attachments[0]
+	if a.FileName != "screen.png" || a.ContentType != "image/png" || string(a.Data[1:4]) != "PNG" || len(a.Data) != 12 {
+		t.Errorf("attachment %q %s with %d bytes %q", a.FileName, a.ContentType, len(a.Data), a.Data)
+	}
+}
+
+func TestParseHTMLOnly(t *testing.T) {
+	msg, err := Parse(crlf(`From: user@example.com
+Subject: Help
+Content-Type: text/html; charset=utf-8
+
+<html><style>p { color: red }</style><body><p>Line one</p><div>Line &amp; two</div></body></html>
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "Line one\nLine & two" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+	// Without a Message-ID one is derived from the content
+	if !strings.HasSuffix(msg.MessageID, "@generated.quickdesk") {
+		t.Errorf("MessageID = %q", msg.MessageID)
+	}
+	again, _ := Parse(crlf(`From: user@example.com
+Subject: Help
+Content-Type: text/html; charset=utf-8
+
+<html><style>p { color: red }</style><body><p>Line one</p><div>Line &amp; two</div></body></html>
+`))
+	if again.MessageID != msg.MessageID {
+		t.Error("the same message got a different generated Message-ID")
+	}
+}
+
+func TestParseAutomatic(t *testing.T) {
+	for _, header := range []string{
+		"Auto-Submitted: auto-replied",
+		"Precedence: bulk",
+		"Precedence: list",
+		"X-Autoreply: yes",
+	} {
+		msg, err := Parse(crlf("From: user@example.com\n" + header + "\nSubject: Out of office\n\nAway.\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Automatic {
+			t.Errorf("%s: not taken for an automatic message", header)
+		}
+	}
+
+	msg, err := Parse(crlf("From: user@example.com\nAuto-Submitted: no\n\nHi\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Automatic {
+		t.Error("Auto-Submitted: no taken for an automatic message")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, raw := range map[string]string{
+		"no header":      "just some text",
+		"invalid sender": "From: not an address\n\nHi\n",
+		"missing sender": "Subject: Hi\n\nHi\n",
+	} {
+		if _, err := Parse(crlf(raw)); err == nil {
+			t.Errorf("%s: Parse succeeded", name)
+		}
+	}
+}
+
+func TestStripQuoted(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Thanks, that fixed it.\n\nOn Mon, 2 Mar 2026 at 10:00, Support <support@example.com> wrote:\n> Please restart.", "Thanks, that fixed it."},
+		{"Still broken.\n-----Original Message-----\nFrom: Support\nPlease restart.", "Still broken."},
+		{"> quoted\nmy answer\n> more quote", "my answer"},
+		{"> only a quote", "> only a quote"},
+		{"No quote at all", "No quote at all"},
+	}
+	for _, tt := range tests {
+		if got := StripQuoted(tt.text); got != tt.want {
+			t.Errorf("StripQuoted(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -68,3 +68,31 @@ func AgentOrAdminMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// StreamAuthMiddleware authenticates like AuthMiddleware, or by a stream
+// token in ?token= when there is no Authorization header, for clients that
+// cannot set headers such as the browser's EventSource. Session tokens are
+// not accepted there, as URLs end up in access logs.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	withHeader := AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" || r.Header.Get("Authorization") != "" {
+			withHeader.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := auth.ValidateStreamToken(token)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid stream token"})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, UserRoleKey, claims.Role)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
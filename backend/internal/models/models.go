@@ -1,6 +1,10 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +19,10 @@ const (
 	RoleAdmin Role = "admin"
 )
 
+func (r Role) IsValid() bool {
+	return r == RoleUser || r == RoleAgent || r == RoleAdmin
+}
+
 type User struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Email     string         `json:"email" gorm:"unique;not null"`
@@ -22,11 +30,15 @@ type User struct {
 	FirstName string         `json:"first_name" gorm:"not null"`
 	LastName  string         `json:"last_name" gorm:"not null"`
 	Role      Role           `json:"role" gorm:"default:user"`
-	IsActive  bool           `json:"is_active" gorm:"default:true"`
+	IsActive  bool           `json:"is_active"`
+	Language  string         `json:"language"` // BCP 47 tag for emails, e.g. "de" or "pt-BR"; empty for the default
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
+	// CalendarVersion is signed into calendar feed URLs; raising it revokes them
+	CalendarVersion int `json:"-" gorm:"not null;default:0"`
+
 	// Relations
 	CreatedTickets  []Ticket  `json:"created_tickets,omitempty" gorm:"foreignKey:CreatedByID"`
 	AssignedTickets []Ticket  `json:"assigned_tickets,omitempty" gorm:"foreignKey:AssignedToID"`
@@ -43,6 +55,15 @@ const (
 	StatusClosed     TicketStatus = "closed"
 )
 
+func (s TicketStatus) IsValid() bool {
+	return s == StatusOpen || s == StatusInProgress || s == StatusResolved || s == StatusClosed
+}
+
+// IsResolved reports whether the status ends the ticket's lifecycle
+func (s TicketStatus) IsResolved() bool {
+	return s == StatusResolved || s == StatusClosed
+}
+
 type TicketPriority string
 
 const (
@@ -52,12 +73,16 @@ const (
 	PriorityUrgent TicketPriority = "urgent"
 )
 
+func (p TicketPriority) IsValid() bool {
+	return p == PriorityLow || p == PriorityMedium || p == PriorityHigh || p == PriorityUrgent
+}
+
 type Category struct {
 	ID          uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
 	Name        string         `json:"name" gorm:"unique;not null"`
 	Description string         `json:"description"`
 	Color       string         `json:"color" gorm:"default:#007bff"`
-	IsActive    bool           `json:"is_active" gorm:"default:true"`
+	IsActive    bool           `json:"is_active"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
@@ -67,20 +92,31 @@ type Category struct {
 }
 
 type Ticket struct {
-	ID           uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Subject      string         `json:"subject" gorm:"not null"`
-	Description  string         `json:"description" gorm:"not null"`
-	Status       TicketStatus   `json:"status" gorm:"default:open"`
-	Priority     TicketPriority `json:"priority" gorm:"default:medium"`
-	CreatedByID  uuid.UUID      `json:"created_by_id" gorm:"not null"`
-	AssignedToID *uuid.UUID     `json:"assigned_to_id"`
-	CategoryID   uuid.UUID      `json:"category_id" gorm:"not null"`
-	UpVotes      int            `json:"up_votes" gorm:"default:0"`
-	DownVotes    int            `json:"down_votes" gorm:"default:0"`
-	ViewCount    int            `json:"view_count" gorm:"default:0"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID              uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Subject         string         `json:"subject" gorm:"not null"`
+	Description     string         `json:"description" gorm:"not null"`
+	Status          TicketStatus   `json:"status" gorm:"default:open"`
+	Priority        TicketPriority `json:"priority" gorm:"default:medium"`
+	CreatedByID     uuid.UUID      `json:"created_by_id" gorm:"not null"`
+	AssignedToID    *uuid.UUID     `json:"assigned_to_id"`
+	CategoryID      uuid.UUID      `json:"category_id" gorm:"not null"`
+	UpVotes         int            `json:"up_votes" gorm:"default:0"`
+	DownVotes       int            `json:"down_votes" gorm:"default:0"`
+	ViewCount       int            `json:"view_count" gorm:"default:0"`
+	FirstResponseAt *time.Time     `json:"first_response_at"` // First public reply by an agent
+	ResolvedAt      *time.Time     `json:"resolved_at"`       // Cleared when the ticket is reopened
+	ReopenCount     int            `json:"reopen_count" gorm:"default:0"`
+	DueAt           *time.Time     `json:"due_at" gorm:"index"` // Set by agents, independent of the SLA
+	DueRemindedAt   *time.Time     `json:"-"`                   // Cleared when the due date changes
+	SLAWarnedFor    *time.Time     `json:"-"`                   // SLA deadline the assignee was last warned about
+	Overdue         bool           `json:"overdue" gorm:"-"`    // Past due and not resolved, computed on load
+	TemplateID      *uuid.UUID     `json:"template_id,omitempty"`
+	CustomFields    Fields         `json:"custom_fields" gorm:"type:jsonb;default:'{}'"`
+	RecurrenceID    *uuid.UUID     `json:"recurrence_id,omitempty" gorm:"uniqueIndex:idx_tickets_recurrence"`
+	RecurrenceAt    *time.Time     `json:"recurrence_at,omitempty" gorm:"uniqueIndex:idx_tickets_recurrence"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 
 	// Relations
 	CreatedBy   User         `json:"created_by" gorm:"foreignKey:CreatedByID"`
@@ -89,6 +125,52 @@ type Ticket struct {
 	Comments    []Comment    `json:"comments,omitempty"`
 	Attachments []Attachment `json:"attachments,omitempty"`
 	Votes       []Vote       `json:"votes,omitempty"`
+	Tags        []Tag        `json:"tags,omitempty" gorm:"many2many:ticket_tags"`
+}
+
+// Fields holds free-form custom field values by name, stored as a JSON
+// object
+type Fields map[string]string
+
+func (f Fields) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
+func (f *Fields) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Fields", value)
+	}
+	return json.Unmarshal(b, f)
+}
+
+// AfterFind flags tickets that are past their due date
+func (t *Ticket) AfterFind(tx *gorm.DB) error {
+	t.Overdue = t.IsOverdue(time.Now())
+	return nil
+}
+
+// IsOverdue reports whether an unresolved ticket's due date is before now
+func (t *Ticket) IsOverdue(now time.Time) bool {
+	return t.DueAt != nil && !t.Status.IsResolved() && t.DueAt.Before(now)
+}
+
+type Tag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name      string    `json:"name" gorm:"unique;not null"` // Stored lower-case
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type Comment struct {
@@ -126,20 +208,462 @@ type Vote struct {
 }
 
 type Attachment struct {
-	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	FileName  string    `json:"file_name" gorm:"not null"`
-	FilePath  string    `json:"file_path" gorm:"not null"`
-	FileSize  int64     `json:"file_size"`
-	MimeType  string    `json:"mime_type"`
-	TicketID  uuid.UUID `json:"ticket_id" gorm:"not null"`
-	UserID    uuid.UUID `json:"user_id" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	FileName  string     `json:"file_name" gorm:"not null"`
+	FilePath  string     `json:"file_path" gorm:"not null"`
+	FileSize  int64      `json:"file_size"`
+	MimeType  string     `json:"mime_type"`
+	TicketID  uuid.UUID  `json:"ticket_id" gorm:"not null"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty"` // Set for files attached to an emailed reply
+	UserID    uuid.UUID  `json:"user_id" gorm:"not null"`
+	CreatedAt time.Time  `json:"created_at"`
 
 	// Relations
 	Ticket Ticket `json:"ticket" gorm:"foreignKey:TicketID"`
 	User   User   `json:"user" gorm:"foreignKey:UserID"`
 }
 
+type Team struct {
+	ID          uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name        string         `json:"name" gorm:"unique;not null"`
+	Description string         `json:"description"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relations
+	Members []User `json:"members,omitempty" gorm:"many2many:team_members"`
+}
+
+type ViewVisibility string
+
+const (
+	VisibilityPrivate  ViewVisibility = "private"
+	VisibilityTeam     ViewVisibility = "team"
+	VisibilityEveryone ViewVisibility = "everyone"
+)
+
+// SavedView is a named ticket query with a sort order. `me` in the query
+// resolves to whoever runs the view, so shared views stay personal.
+type SavedView struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name       string         `json:"name" gorm:"not null"`
+	Query      string         `json:"query"`
+	SortBy     string         `json:"sort_by"`
+	SortOrder  string         `json:"sort_order"`
+	OwnerID    uuid.UUID      `json:"owner_id" gorm:"not null;index"`
+	Visibility ViewVisibility `json:"visibility" gorm:"default:private"`
+	TeamID     *uuid.UUID     `json:"team_id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Live ticket count, filled in when requested
+	Count *int64 `json:"count,omitempty" gorm:"-"`
+
+	// Relations
+	Owner User  `json:"owner" gorm:"foreignKey:OwnerID"`
+	Team  *Team `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+}
+
+// ImportRecord maps a record from an external system to the row it was
+// imported as, so re-running an import updates instead of duplicating
+type ImportRecord struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Source     string    `json:"source" gorm:"not null;uniqueIndex:idx_import_records_key"`
+	Entity     string    `json:"entity" gorm:"not null;uniqueIndex:idx_import_records_key"`
+	ExternalID string    `json:"external_id" gorm:"not null;uniqueIndex:idx_import_records_key"`
+	LocalID    uuid.UUID `json:"local_id" gorm:"type:uuid;not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ReportSchedule emails a report or saved view to a list of recipients on
+// a cron schedule evaluated in Timezone
+type ReportSchedule struct {
+	ID         uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name       string         `json:"name" gorm:"not null"`
+	Report     string         `json:"report" gorm:"not null"` // agents, sla, csat, time or view
+	ViewID     *uuid.UUID     `json:"view_id"`
+	Params     string         `json:"params"` // Report filter as a query string, e.g. "days=7&team_id=..."
+	Schedule   string         `json:"schedule" gorm:"not null"`
+	Timezone   string         `json:"timezone" gorm:"default:UTC"`
+	Recipients string         `json:"recipients" gorm:"not null"` // Comma-separated email addresses
+	IsActive   bool           `json:"is_active"`
+	OwnerID    uuid.UUID      `json:"owner_id" gorm:"not null"`
+	NextRunAt  *time.Time     `json:"next_run_at" gorm:"index"`
+	LastRunAt  *time.Time     `json:"last_run_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relations
+	View  *SavedView `json:"view,omitempty" gorm:"foreignKey:ViewID"`
+	Owner User       `json:"owner" gorm:"foreignKey:OwnerID"`
+}
+
+type ReportRunStatus string
+
+const (
+	RunSucceeded ReportRunStatus = "succeeded"
+	RunFailed    ReportRunStatus = "failed"
+)
+
+// ReportRun logs one delivery of a scheduled report
+type ReportRun struct {
+	ID         uuid.UUID       `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	ScheduleID uuid.UUID       `json:"schedule_id" gorm:"type:uuid;not null;index"`
+	Status     ReportRunStatus `json:"status" gorm:"not null"`
+	Error      string          `json:"error,omitempty"`
+	Rows       int             `json:"rows"`
+	Recipients string          `json:"recipients"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+}
+
+// SatisfactionSurvey is a CSAT survey sent to a ticket's requester when it
+// is resolved. Rating is 1 (bad) to 5 (good) once answered.
+type SatisfactionSurvey struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID    uuid.UUID  `json:"ticket_id" gorm:"type:uuid;not null;index"`
+	RequesterID uuid.UUID  `json:"requester_id" gorm:"type:uuid;not null"`
+	AgentID     *uuid.UUID `json:"agent_id" gorm:"type:uuid;index"` // Assignee when the ticket was resolved
+	Rating      *int       `json:"rating"`
+	Comment     string     `json:"comment"`
+	SentAt      time.Time  `json:"sent_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at" gorm:"index"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relations
+	Ticket Ticket `json:"ticket" gorm:"foreignKey:TicketID"`
+	Agent  *User  `json:"agent,omitempty" gorm:"foreignKey:AgentID"`
+}
+
+// TimeEntry is time an agent spent on a ticket. A running timer has no
+// EndedAt yet; each agent can run one timer at a time.
+type TimeEntry struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID        uuid.UUID  `json:"ticket_id" gorm:"type:uuid;not null;index"`
+	UserID          uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_time_entries_running,where:ended_at IS NULL"`
+	StartedAt       time.Time  `json:"started_at" gorm:"not null;index"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int        `json:"duration_seconds"` // Set when the entry is logged or the timer stops
+	Note            string     `json:"note"`
+	Billable        bool       `json:"billable" gorm:"default:false"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	// Relations
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+type InboundStatus string
+
+const (
+	InboundTicket   InboundStatus = "ticket"   // Opened a new ticket
+	InboundComment  InboundStatus = "comment"  // Added to an existing ticket
+	InboundRejected InboundStatus = "rejected" // Not turned into anything, see Error
+)
+
+// InboundEmail logs every email received by the support mailbox. The
+// unique MessageID makes processing idempotent and lets replies be
+// threaded by their In-Reply-To and References headers.
+type InboundEmail struct {
+	ID         uuid.UUID     `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	MessageID  string        `json:"message_id" gorm:"not null;uniqueIndex"`
+	From       string        `json:"from"`
+	Subject    string        `json:"subject"`
+	Status     InboundStatus `json:"status" gorm:"not null"`
+	Error      string        `json:"error,omitempty"`
+	TicketID   *uuid.UUID    `json:"ticket_id" gorm:"type:uuid;index"`
+	CommentID  *uuid.UUID    `json:"comment_id" gorm:"type:uuid"`
+	UserID     *uuid.UUID    `json:"user_id" gorm:"type:uuid"`
+	ReceivedAt time.Time     `json:"received_at" gorm:"index"`
+}
+
+type OutboundStatus string
+
+const (
+	OutboundPending OutboundStatus = "pending" // Waiting for its first or next attempt
+	OutboundSending OutboundStatus = "sending" // Claimed by a worker until NextAttemptAt
+	OutboundSent    OutboundStatus = "sent"
+	OutboundDead    OutboundStatus = "dead" // Gave up; an admin can re-send it
+)
+
+// OutboundEmail is a message in the outbox. Workers deliver pending
+// messages with exponential backoff between attempts, and the rows stay
+// behind as the delivery log.
+type OutboundEmail struct {
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Recipients    string         `json:"recipients" gorm:"not null"` // Comma separated
+	Subject       string         `json:"subject"`
+	TicketID      *uuid.UUID     `json:"ticket_id" gorm:"type:uuid;index"`
+	Message       []byte         `json:"-" gorm:"not null"` // Rendered RFC 5322 message
+	Status        OutboundStatus `json:"status" gorm:"not null;index:idx_outbound_emails_due,priority:1"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at" gorm:"index:idx_outbound_emails_due,priority:2"`
+	LastError     string         `json:"last_error,omitempty"`
+	SentAt        *time.Time     `json:"sent_at"`
+	ResentFromID  *uuid.UUID     `json:"resent_from_id,omitempty" gorm:"type:uuid"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"index"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// EmailTemplate overrides a built-in email template for one locale.
+// Subject and TextBody are text/template, HTMLBody is html/template; all
+// three are rendered with the variables documented on email.TemplateData.
+type EmailTemplate struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name        string     `json:"name" gorm:"not null;uniqueIndex:idx_email_templates_name_locale"`
+	Locale      string     `json:"locale" gorm:"not null;uniqueIndex:idx_email_templates_name_locale"`
+	Subject     string     `json:"subject" gorm:"not null"`
+	HTMLBody    string     `json:"html_body" gorm:"not null"`
+	TextBody    string     `json:"text_body" gorm:"not null"`
+	UpdatedByID *uuid.UUID `json:"updated_by_id" gorm:"type:uuid"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// NotificationEvent is a kind of ticket activity users can be emailed about
+type NotificationEvent string
+
+const (
+	NotifyCreated       NotificationEvent = "created"        // Confirmation of a ticket you opened
+	NotifyStatusChanged NotificationEvent = "status_changed" // Your ticket's status changed
+	NotifyCommented     NotificationEvent = "commented"      // A comment on a ticket you opened, are assigned or watch
+	NotifyAssigned      NotificationEvent = "assigned"       // A ticket was assigned to you
+	NotifyMentioned     NotificationEvent = "mentioned"      // You were @mentioned in a comment
+	NotifySLAWarning    NotificationEvent = "sla_warning"    // A ticket is about to miss its SLA
+)
+
+// NotificationEvents lists the events in a stable order
+var NotificationEvents = []NotificationEvent{NotifyCreated, NotifyStatusChanged, NotifyCommented,
+	NotifyAssigned, NotifyMentioned, NotifySLAWarning}
+
+func (e NotificationEvent) IsValid() bool {
+	for _, event := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationMode is how a user receives an event's emails
+type NotificationMode string
+
+const (
+	ModeImmediate NotificationMode = "immediate"
+	ModeHourly    NotificationMode = "hourly" // Batched into a digest at most once an hour
+	ModeDaily     NotificationMode = "daily"  // Batched into a digest at most once a day
+	ModeOff       NotificationMode = "off"
+)
+
+func (m NotificationMode) IsValid() bool {
+	return m == ModeImmediate || m == ModeHourly || m == ModeDaily || m == ModeOff
+}
+
+// IsDigest reports whether the mode batches emails
+func (m NotificationMode) IsDigest() bool {
+	return m == ModeHourly || m == ModeDaily
+}
+
+// NotificationPreference is how a user wants to hear about one event.
+// Events without a row are sent immediately.
+type NotificationPreference struct {
+	ID        uuid.UUID         `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID         `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_notification_preferences_user_event"`
+	Event     NotificationEvent `json:"event" gorm:"not null;uniqueIndex:idx_notification_preferences_user_event"`
+	Mode      NotificationMode  `json:"mode" gorm:"not null"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// DigestItem is a notification waiting for its user's next digest email.
+// A digest worker claims a user's items by setting ClaimedAt and deletes
+// them once the digest is queued.
+type DigestItem struct {
+	ID        uuid.UUID         `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID         `json:"user_id" gorm:"type:uuid;not null;index"`
+	Event     NotificationEvent `json:"event" gorm:"not null"`
+	Mode      NotificationMode  `json:"mode" gorm:"not null"` // hourly or daily
+	TicketID  uuid.UUID         `json:"ticket_id" gorm:"type:uuid;not null"`
+	Actor     string            `json:"actor"`      // Who did it, e.g. the comment's author
+	Detail    string            `json:"detail"`     // The comment, new status or missed SLA target
+	Deadline  *time.Time        `json:"deadline"`   // sla_warning: when the target is missed
+	ClaimedAt *time.Time        `json:"claimed_at"` // Set while a digest is being sent
+	CreatedAt time.Time         `json:"created_at" gorm:"index"`
+}
+
+// Notification is an entry in a user's in-app notification center. It is
+// created for the same activity as notification emails, whatever the
+// user's email preferences.
+type Notification struct {
+	ID        uuid.UUID         `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID         `json:"user_id" gorm:"type:uuid;not null;index:idx_notifications_user,priority:1"`
+	Event     NotificationEvent `json:"event" gorm:"not null"`
+	TicketID  uuid.UUID         `json:"ticket_id" gorm:"type:uuid;not null"`
+	Actor     string            `json:"actor"`    // Who did it, e.g. the comment's author
+	Detail    string            `json:"detail"`   // The comment, new status or missed SLA target
+	Deadline  *time.Time        `json:"deadline"` // sla_warning: when the target is missed
+	ReadAt    *time.Time        `json:"read_at"`
+	CreatedAt time.Time         `json:"created_at" gorm:"index;index:idx_notifications_user,priority:2"`
+
+	// Relations
+	Ticket Ticket `json:"ticket" gorm:"foreignKey:TicketID"`
+}
+
+// PresenceState is what an agent is doing on a ticket they have open
+type PresenceState string
+
+const (
+	PresenceViewing PresenceState = "viewing"
+	PresenceTyping  PresenceState = "typing" // Drafting a comment
+)
+
+func (s PresenceState) IsValid() bool {
+	return s == PresenceViewing || s == PresenceTyping
+}
+
+// TicketPresence is an agent who has a ticket open. Clients renew it with
+// heartbeats; once they stop, the row expires.
+type TicketPresence struct {
+	ID          uuid.UUID     `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID    uuid.UUID     `json:"ticket_id" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_presences_ticket_user"`
+	UserID      uuid.UUID     `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_presences_ticket_user"`
+	State       PresenceState `json:"state" gorm:"not null"`
+	TypingSince *time.Time    `json:"typing_since"` // When the current draft was started
+	LastSeenAt  time.Time     `json:"last_seen_at" gorm:"not null;index"`
+	CreatedAt   time.Time     `json:"created_at"`
+
+	// Relations
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// TicketWatcher follows a ticket's comments without being its requester or
+// assignee
+type TicketWatcher struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	TicketID  uuid.UUID `json:"ticket_id" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_watchers_ticket_user"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_ticket_watchers_ticket_user;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+// Webhook posts signed JSON payloads about ticket activity to an external
+// endpoint. It is disabled after too many failed deliveries in a row.
+type Webhook struct {
+	ID                  uuid.UUID  `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Name                string     `json:"name" gorm:"not null"`
+	URL                 string     `json:"url" gorm:"not null"`
+	Events              string     `json:"events" gorm:"not null"` // Comma-separated event types, e.g. "ticket.created,comment.added"
+	Secret              string     `json:"-" gorm:"not null"`      // Key for the HMAC-SHA256 payload signature; shown once
+	IsActive            bool       `json:"is_active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"` // Failed deliveries since the last success
+	DisabledAt          *time.Time `json:"disabled_at"`          // Set when failures disabled the webhook
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+	CreatedByID         uuid.UUID  `json:"created_by_id" gorm:"type:uuid;not null"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook wants events of a type
+func (w *Webhook) Subscribes(event string) bool {
+	for _, e := range strings.Split(w.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookPending   WebhookDeliveryStatus = "pending" // Waiting for its first or next attempt
+	WebhookSending   WebhookDeliveryStatus = "sending" // Claimed by a worker until NextAttemptAt
+	WebhookDelivered WebhookDeliveryStatus = "delivered"
+	WebhookFailed    WebhookDeliveryStatus = "failed" // Gave up; an admin can redeliver it
+)
+
+// WebhookDelivery is one event queued for one webhook. Workers post
+// pending deliveries with exponential backoff between attempts, and the
+// rows stay behind as the delivery history.
+type WebhookDelivery struct {
+	ID                uuid.UUID             `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	WebhookID         uuid.UUID             `json:"webhook_id" gorm:"type:uuid;not null;index:idx_webhook_deliveries_webhook,priority:1"`
+	EventID           uuid.UUID             `json:"event_id" gorm:"type:uuid;not null"` // Same for every delivery and redelivery of an event
+	Event             string                `json:"event" gorm:"not null"`
+	TicketID          *uuid.UUID            `json:"ticket_id" gorm:"type:uuid;index"`
+	Payload           []byte                `json:"-" gorm:"not null"`
+	Status            WebhookDeliveryStatus `json:"status" gorm:"not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts          int                   `json:"attempts"`
+	NextAttemptAt     time.Time             `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	ResponseStatus    int                   `json:"response_status,omitempty"` // HTTP status of the last attempt
+	ResponseBody      string                `json:"response_body,omitempty"`   // Start of the last response body
+	LastError         string                `json:"last_error,omitempty"`
+	DeliveredAt       *time.Time            `json:"delivered_at"`
+	RedeliveredFromID *uuid.UUID            `json:"redelivered_from_id,omitempty" gorm:"type:uuid"`
+	CreatedAt         time.Time             `json:"created_at" gorm:"index:idx_webhook_deliveries_webhook,priority:2"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+}
+
+// TicketTemplate prefills the create form for a category so requesters
+// describe their problem in a structure agents can work with
+type TicketTemplate struct {
+	ID            uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	CategoryID    uuid.UUID      `json:"category_id" gorm:"type:uuid;not null;index"`
+	Name          string         `json:"name" gorm:"not null"`
+	Subject       string         `json:"subject"`     // Skeleton, e.g. "Access request: "
+	Description   string         `json:"description"` // Skeleton with headings to fill in
+	Priority      TicketPriority `json:"priority"`    // Empty keeps the ticket default
+	FieldDefaults Fields         `json:"field_defaults" gorm:"type:jsonb;default:'{}'"`
+	Position      int            `json:"position" gorm:"default:0"` // Order in the create form
+	IsActive      bool           `json:"is_active"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relations
+	Category Category `json:"category" gorm:"foreignKey:CategoryID"`
+}
+
+// RecurringTicket is a template an admin sets up to open the same ticket on
+// a cron schedule evaluated in Timezone, e.g. monthly backup checks.
+// Tickets it creates are opened by CreatedByID and carry RecurrenceID and
+// RecurrenceAt, which are unique together so each occurrence opens one
+// ticket.
+type RecurringTicket struct {
+	ID           uuid.UUID      `json:"id" gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Subject      string         `json:"subject" gorm:"not null"`
+	Description  string         `json:"description" gorm:"not null"`
+	Priority     TicketPriority `json:"priority" gorm:"default:medium"`
+	CategoryID   uuid.UUID      `json:"category_id" gorm:"type:uuid;not null"`
+	AssignedToID *uuid.UUID     `json:"assigned_to_id" gorm:"type:uuid"`
+	Tags         string         `json:"tags"`      // Comma-separated
+	DueAfter     int            `json:"due_after"` // Minutes from creation to the due date, 0 for none
+	Schedule     string         `json:"schedule" gorm:"not null"`
+	Timezone     string         `json:"timezone" gorm:"default:UTC"`
+	IsActive     bool           `json:"is_active"`
+	CreatedByID  uuid.UUID      `json:"created_by_id" gorm:"type:uuid;not null"`
+	NextRunAt    *time.Time     `json:"next_run_at" gorm:"index"`
+	LastRunAt    *time.Time     `json:"last_run_at"`
+	LastTicketID *uuid.UUID     `json:"last_ticket_id" gorm:"type:uuid"`
+	LastError    string         `json:"last_error,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// Relations
+	Category   Category `json:"category" gorm:"foreignKey:CategoryID"`
+	AssignedTo *User    `json:"assigned_to,omitempty" gorm:"foreignKey:AssignedToID"`
+	CreatedBy  User     `json:"created_by" gorm:"foreignKey:CreatedByID"`
+}
+
 // Add indexes for better performance
 func (User) TableName() string {
 	return "users"
@@ -164,3 +688,19 @@ func (Vote) TableName() string {
 func (Attachment) TableName() string {
 	return "attachments"
 }
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+func (Team) TableName() string {
+	return "teams"
+}
+
+func (SavedView) TableName() string {
+	return "saved_views"
+}
+
+func (ImportRecord) TableName() string {
+	return "import_records"
+}
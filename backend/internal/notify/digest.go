@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// digestLease is how long a worker holds a user's digest items; items
+// still claimed after that, e.g. because the server died, are sent again
+const digestLease = 10 * time.Minute
+
+// digestPeriods is how long the oldest item of a digest waits before the
+// digest goes out
+var digestPeriods = map[models.NotificationMode]time.Duration{
+	models.ModeHourly: time.Hour,
+	models.ModeDaily:  24 * time.Hour,
+}
+
+// Digester emails the notifications users batch into digests
+type Digester struct {
+	db    *gorm.DB
+	email *email.EmailService
+}
+
+func NewDigester(db *gorm.DB, emailService *email.EmailService) *Digester {
+	return &Digester{db: db, email: emailService}
+}
+
+// RunDue sends every digest whose oldest item has waited its period, so a
+// user gets at most one hourly and one daily digest per period. Items are
+// claimed before sending, so with several server instances only one of
+// them sends a digest; a failed send releases the claim to retry on the
+// next run.
+func (d *Digester) RunDue(ctx context.Context, now time.Time) error {
+	var due []struct {
+		UserID uuid.UUID
+		Mode   models.NotificationMode
+	}
+	err := d.db.WithContext(ctx).Model(&models.DigestItem{}).
+		Select("user_id, mode").
+		Where("claimed_at IS NULL OR claimed_at < ?", now.Add(-digestLease)).
+		Group("user_id, mode").
+		Having("(mode = ? AND MIN(created_at) <= ?) OR (mode = ? AND MIN(created_at) <= ?)",
+			models.ModeHourly, now.Add(-digestPeriods[models.ModeHourly]),
+			models.ModeDaily, now.Add(-digestPeriods[models.ModeDaily])).
+		Scan(&due).Error
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, digest := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := d.send(digest.UserID, digest.Mode, now); err != nil {
+			errs = append(errs, err)
+			log.Printf("notify: %s digest for user %s: %v", digest.Mode, digest.UserID, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send claims a user's items for one digest and emails them
+func (d *Digester) send(userID uuid.UUID, mode models.NotificationMode, now time.Time) error {
+	var items []models.DigestItem
+	err := d.db.Raw(`
+		UPDATE digest_items SET claimed_at = ?
+		WHERE user_id = ? AND mode = ? AND (claimed_at IS NULL OR claimed_at < ?)
+		RETURNING *`,
+		now, userID, mode, now.Add(-digestLease),
+	).Scan(&items).Error
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil // Another instance took them
+	}
+	ids := make([]uuid.UUID, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	var user models.User
+	if err := d.db.Limit(1).Find(&user, "id = ?", userID).Error; err != nil {
+		d.release(ids, now)
+		return err
+	}
+
+	digest := email.DigestInfo{Period: string(mode)}
+	if user.IsActive {
+		if digest.Items, err = d.entries(items); err != nil {
+			d.release(ids, now)
+			return err
+		}
+	}
+	if len(digest.Items) > 0 {
+		if err := d.email.SendDigestEmail(email.RecipientOf(&user), digest); err != nil {
+			d.release(ids, now)
+			return err
+		}
+	}
+
+	// Sent, or nothing left to send: the user or the tickets are gone
+	return d.db.Where("id IN ?", ids).Delete(&models.DigestItem{}).Error
+}
+
+// entries describes the items of tickets that still exist, oldest first
+func (d *Digester) entries(items []models.DigestItem) ([]email.DigestEntry, error) {
+	ticketIDs := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		ticketIDs = append(ticketIDs, item.TicketID)
+	}
+	var tickets []models.Ticket
+	if err := d.db.Where("id IN ?", ticketIDs).Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.Ticket, len(tickets))
+	for i := range tickets {
+		byID[tickets[i].ID] = &tickets[i]
+	}
+
+	slices.SortFunc(items, func(a, b models.DigestItem) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	var entries []email.DigestEntry
+	for _, item := range items {
+		ticket, ok := byID[item.TicketID]
+		if !ok {
+			continue
+		}
+		entry := email.DigestEntry{
+			Event:  string(item.Event),
+			Ticket: d.email.TicketInfo(ticket),
+			Actor:  item.Actor,
+			Detail: item.Detail,
+			Time:   item.CreatedAt,
+		}
+		if item.Deadline != nil {
+			entry.Deadline = *item.Deadline
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// release gives claimed items back for the next run
+func (d *Digester) release(ids []uuid.UUID, claimedAt time.Time) {
+	d.db.Model(&models.DigestItem{}).
+		Where("id IN ? AND claimed_at = ?", ids, claimedAt).
+		UpdateColumn("claimed_at", nil)
+}
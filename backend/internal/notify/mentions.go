@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"regexp"
+	"strings"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// mentionPattern matches "@" followed by an email address, e.g.
+// "@alex.agent@example.com", at the start of the text or after a
+// character that cannot be part of an address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@+-])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Mentions returns the lower-cased addresses a comment @mentions, each
+// once
+func Mentions(content string) []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		address := strings.ToLower(match[1])
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// mentioned loads the users a comment @mentions who can read it: agents
+// and admins, and the requester unless the comment is internal. The
+// author is left out.
+func (n *Notifier) mentioned(ticket *models.Ticket, comment *models.Comment) ([]models.User, error) {
+	addresses := Mentions(comment.Content)
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	var users []models.User
+	if err := n.db.Where("LOWER(email) IN ?", addresses).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	var readers []models.User
+	for _, user := range users {
+		requester := user.ID == ticket.CreatedByID && !comment.IsInternal
+		if (user.Role != models.RoleUser || requester) && notified(&user, comment.UserID) {
+			readers = append(readers, user)
+		}
+	}
+	return readers, nil
+}
+
+func containsUser(users []models.User, id uuid.UUID) bool {
+	for _, user := range users {
+		if user.ID == id {
+			return true
+		}
+	}
+	return false
+}
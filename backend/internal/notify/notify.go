@@ -0,0 +1,217 @@
+// Package notify tells the people involved in a ticket about its
+// activity: requesters about their tickets, agents about tickets assigned
+// to them, comments on tickets they watch or comments that mention them.
+// Every notification lands in the user's in-app notification center; each
+// user chooses per event whether it is also emailed straight away, in an
+// hourly or daily digest, or not at all.
+package notify
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/realtime"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Notifier records and emails the notifications for ticket events
+type Notifier struct {
+	db    *gorm.DB
+	email *email.EmailService
+	hub   *realtime.Hub // Pushes new notifications to connected clients
+}
+
+func NewNotifier(db *gorm.DB, emailService *email.EmailService, hub *realtime.Hub) *Notifier {
+	return &Notifier{db: db, email: emailService, hub: hub}
+}
+
+// notification is one user's news about a ticket. send emails it on its
+// own; the other fields describe it in a digest.
+type notification struct {
+	user     *models.User
+	event    models.NotificationEvent
+	actor    string
+	detail   string
+	deadline *time.Time
+	send     func(to email.Recipient) error
+}
+
+// Handle notifies about an event in the background. It is meant to be
+// subscribed to the ticket event bus.
+func (n *Notifier) Handle(e events.Event) {
+	go func() {
+		if err := n.Send(e); err != nil {
+			log.Printf("notify: %s for ticket %s: %v", e.Type, e.TicketID, err)
+		}
+	}()
+}
+
+// Send notifies everyone an event concerns. Nobody is told about their own
+// actions, except requesters, who get a confirmation of a new ticket.
+func (n *Notifier) Send(e events.Event) error {
+	var ticket models.Ticket
+	if err := n.db.Preload("CreatedBy").Preload("AssignedTo").First(&ticket, "id = ?", e.TicketID).Error; err != nil {
+		return err
+	}
+	requester, assignee := &ticket.CreatedBy, ticket.AssignedTo
+
+	var actor models.User
+	if e.ActorID != uuid.Nil {
+		n.db.Limit(1).Find(&actor, "id = ?", e.ActorID)
+	}
+	actorName := person(&actor).Name
+
+	var out []notification
+	assigned := func() notification {
+		return notification{user: assignee, event: models.NotifyAssigned, actor: actorName, send: func(to email.Recipient) error {
+			return n.email.SendTicketAssignedEmail(to, &ticket, person(assignee))
+		}}
+	}
+
+	switch e.Type {
+	case events.TicketCreated:
+		if notified(requester, uuid.Nil) {
+			out = append(out, notification{user: requester, event: models.NotifyCreated, actor: actorName, send: func(to email.Recipient) error {
+				return n.email.SendTicketCreatedEmail(to, &ticket)
+			}})
+		}
+		if notified(assignee, e.ActorID) {
+			out = append(out, assigned())
+		}
+
+	case events.TicketUpdated:
+		if slices.Contains(e.Changes, "status") && notified(requester, e.ActorID) {
+			out = append(out, notification{user: requester, event: models.NotifyStatusChanged, actor: actorName, detail: string(ticket.Status), send: func(to email.Recipient) error {
+				return n.email.SendTicketUpdatedEmail(to, &ticket)
+			}})
+		}
+
+	case events.TicketAssigned:
+		if notified(assignee, e.ActorID) {
+			out = append(out, assigned())
+		}
+
+	case events.CommentAdded:
+		if e.CommentID == nil {
+			return nil
+		}
+		var comment models.Comment
+		if err := n.db.Preload("User").First(&comment, "id = ?", *e.CommentID).Error; err != nil {
+			return err
+		}
+		mentioned, err := n.mentioned(&ticket, &comment)
+		if err != nil {
+			return err
+		}
+		watchers, err := n.watchers(&ticket, &comment)
+		if err != nil {
+			return err
+		}
+		info := email.CommentInfo{Author: person(&comment.User).Name, Content: comment.Content}
+		commented := func(user *models.User) notification {
+			return notification{user: user, event: models.NotifyCommented, actor: info.Author, detail: info.Content, send: func(to email.Recipient) error {
+				return n.email.SendCommentAddedEmail(to, &ticket, info)
+			}}
+		}
+
+		// Internal notes stay with the agents. People mentioned get the
+		// mention instead of the plain comment email.
+		if !comment.IsInternal && notified(requester, comment.UserID) && !containsUser(mentioned, requester.ID) {
+			out = append(out, commented(requester))
+		}
+		if notified(assignee, comment.UserID) && assignee.ID != requester.ID && !containsUser(mentioned, assignee.ID) {
+			out = append(out, commented(assignee))
+		}
+		for i := range watchers {
+			watcher := &watchers[i]
+			if watcher.ID == requester.ID || assignee != nil && watcher.ID == assignee.ID || containsUser(mentioned, watcher.ID) {
+				continue // Told above
+			}
+			out = append(out, commented(watcher))
+		}
+		for i := range mentioned {
+			out = append(out, notification{user: &mentioned[i], event: models.NotifyMentioned, actor: info.Author, detail: info.Content, send: func(to email.Recipient) error {
+				return n.email.SendMentionEmail(to, &ticket, info)
+			}})
+		}
+	}
+
+	_, err := n.dispatch(&ticket, out)
+	return err
+}
+
+// dispatch adds notifications to their users' notification centers and
+// emails them the way the users asked for: now, in a digest or not at all.
+// It reports whether any notification was recorded or email queued, so
+// callers know whether repeating it would notify anyone twice. Realtime
+// updates are only a hint for open pages, so failing to publish them is
+// logged.
+func (n *Notifier) dispatch(ticket *models.Ticket, out []notification) (bool, error) {
+	var errs []error
+	done := false
+	for _, nt := range out {
+		entry := models.Notification{
+			ID:       uuid.New(),
+			UserID:   nt.user.ID,
+			Event:    nt.event,
+			TicketID: ticket.ID,
+			Actor:    nt.actor,
+			Detail:   nt.detail,
+			Deadline: nt.deadline,
+		}
+		if err := n.db.Create(&entry).Error; err != nil {
+			errs = append(errs, err)
+		} else {
+			done = true
+			if err := n.hub.Publish(realtime.Message{
+				Type:           realtime.NotificationCreated,
+				TicketID:       &ticket.ID,
+				UserID:         &entry.UserID,
+				NotificationID: &entry.ID,
+			}); err != nil {
+				log.Printf("notify: publishing notification %s: %v", entry.ID, err)
+			}
+		}
+
+		mode, err := n.mode(nt.user.ID, nt.event)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case mode == models.ModeImmediate:
+			err = nt.send(email.RecipientOf(nt.user))
+			done = done || err == nil
+			errs = append(errs, err)
+		case mode.IsDigest():
+			err = n.db.Create(&models.DigestItem{
+				ID:       uuid.New(),
+				UserID:   nt.user.ID,
+				Event:    nt.event,
+				Mode:     mode,
+				TicketID: ticket.ID,
+				Actor:    nt.actor,
+				Detail:   nt.detail,
+				Deadline: nt.deadline,
+			}).Error
+			done = done || err == nil
+			errs = append(errs, err)
+		}
+	}
+	return done, errors.Join(errs...)
+}
+
+// notified reports whether a user should hear about an action by actor
+func notified(user *models.User, actorID uuid.UUID) bool {
+	return user != nil && user.ID != uuid.Nil && user.IsActive && user.ID != actorID
+}
+
+func person(user *models.User) email.Person {
+	to := email.RecipientOf(user)
+	return email.Person{Name: to.Name, Email: to.Email}
+}
@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"bytes"
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+
+	"quickdesk-backend/internal/dbtest"
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// newTestNotifier returns a Notifier on a mock database that sends email
+// straight to a MemoryTransport
+func newTestNotifier(t *testing.T) (*Notifier, sqlmock.Sqlmock, *email.MemoryTransport) {
+	t.Helper()
+	db, mock := dbtest.New(t)
+	transport := email.NewMemoryTransport()
+	es := email.NewWithTransport(nil, transport, "QuickDesk <noreply@example.com>", "", "https://desk.example.com")
+	return NewNotifier(db, es, nil), mock, transport
+}
+
+var (
+	requester = models.User{ID: uuid.New(), Email: "riley@example.com", FirstName: "Riley", LastName: "Requester", Role: models.RoleUser, IsActive: true}
+	agent     = models.User{ID: uuid.New(), Email: "alex@example.com", FirstName: "Alex", LastName: "Agent", Role: models.RoleAgent, IsActive: true}
+)
+
+func userRows(u models.User) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "email", "first_name", "last_name", "role", "is_active"}).
+		AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.Role, u.IsActive)
+}
+
+// expectTicket expects Send to load an unassigned, resolved ticket opened
+// by requester, and the actor
+func expectTicket(mock sqlmock.Sqlmock, ticketID uuid.UUID, actor models.User) {
+	mock.ExpectQuery(`SELECT \* FROM "tickets" WHERE id = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "subject", "status", "priority", "created_by_id"}).
+			AddRow(ticketID, "Printer jam", models.StatusResolved, models.PriorityHigh, requester.ID))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WithArgs(requester.ID).WillReturnRows(userRows(requester))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).WillReturnRows(userRows(actor))
+}
+
+func expectNotification(mock sqlmock.Sqlmock, entry *dbtest.Args) {
+	mock.ExpectQuery(`INSERT INTO "notifications"`).WithArgs(entry.Match(9)...).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+}
+
+func expectMode(mock sqlmock.Sqlmock, mode models.NotificationMode) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "event", "mode"})
+	if mode != "" {
+		rows.AddRow(uuid.New(), requester.ID, models.NotifyStatusChanged, mode)
+	}
+	mock.ExpectQuery(`SELECT \* FROM "notification_preferences" WHERE user_id = \$1 AND event = \$2`).WillReturnRows(rows)
+}
+
+func statusChanged(ticketID uuid.UUID, actor models.User) events.Event {
+	return events.Event{Type: events.TicketUpdated, TicketID: ticketID, ActorID: actor.ID, Changes: []string{"status"}}
+}
+
+func TestSendEmailsStatusChange(t *testing.T) {
+	n, mock, transport := newTestNotifier(t)
+	ticketID := uuid.New()
+	var entry dbtest.Args
+	expectTicket(mock, ticketID, agent)
+	expectNotification(mock, &entry)
+	expectMode(mock, "")
+
+	if err := n.Send(statusChanged(ticketID, agent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !entry.Contains(string(models.NotifyStatusChanged)) || !entry.Contains("Alex Agent") || !entry.Contains(string(models.StatusResolved)) {
+		t.Errorf("notification recorded with %v, want status_changed by Alex Agent to resolved", entry.Values)
+	}
+	sent := transport.To(requester.Email)
+	if len(sent) != 1 || len(transport.Messages()) != 1 {
+		t.Fatalf("%d messages to the requester, %d in total; want 1", len(sent), len(transport.Messages()))
+	}
+	if !strings.Contains(sent[0].Subject, "Printer jam") {
+		t.Errorf("subject %q does not name the ticket", sent[0].Subject)
+	}
+	// The body is quoted-printable, which may wrap the link
+	body, _ := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(sent[0].Raw)))
+	if !strings.Contains(string(body), "https://desk.example.com/tickets/"+ticketID.String()) {
+		t.Error("email does not link to the ticket")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSendFollowsPreference(t *testing.T) {
+	for _, mode := range []models.NotificationMode{models.ModeHourly, models.ModeOff} {
+		n, mock, transport := newTestNotifier(t)
+		ticketID := uuid.New()
+		var entry, digest dbtest.Args
+		expectTicket(mock, ticketID, agent)
+		expectNotification(mock, &entry)
+		expectMode(mock, mode)
+		if mode.IsDigest() {
+			mock.ExpectQuery(`INSERT INTO "digest_items"`).WithArgs(digest.Match(10)...).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(uuid.New()))
+		}
+
+		if err := n.Send(statusChanged(ticketID, agent)); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+
+		// The notification center gets it either way, email never straight away
+		if len(entry.Values) == 0 {
+			t.Errorf("%s: no notification recorded", mode)
+		}
+		if got := len(transport.Messages()); got != 0 {
+			t.Errorf("%s: %d emails sent, want none", mode, got)
+		}
+		if mode.IsDigest() && !digest.Contains(string(mode)) {
+			t.Errorf("%s: digest item recorded with %v", mode, digest.Values)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+}
+
+func TestSendSkipsActor(t *testing.T) {
+	n, mock, transport := newTestNotifier(t)
+	ticketID := uuid.New()
+	expectTicket(mock, ticketID, requester)
+
+	// Requesters closing their own ticket need no email about it
+	if err := n.Send(statusChanged(ticketID, requester)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(transport.Messages()); got != 0 {
+		t.Errorf("%d emails sent, want none", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSendTellsWatchersAboutComments(t *testing.T) {
+	watcher := models.User{ID: uuid.New(), Email: "wes@example.com", FirstName: "Wes", LastName: "Watcher", Role: models.RoleAgent, IsActive: true}
+	outsider := models.User{ID: uuid.New(), Email: "olly@example.com", FirstName: "Olly", LastName: "Outsider", Role: models.RoleUser, IsActive: true}
+
+	for _, internal := range []bool{false, true} {
+		n, mock, transport := newTestNotifier(t)
+		ticketID, commentID := uuid.New(), uuid.New()
+		expectTicket(mock, ticketID, agent)
+		mock.ExpectQuery(`SELECT \* FROM "comments" WHERE id = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "ticket_id", "user_id", "content", "is_internal"}).
+				AddRow(commentID, ticketID, agent.ID, "Swapped the drum", internal))
+		mock.ExpectQuery(`SELECT \* FROM "users" WHERE "users"."id" = \$1`).WithArgs(agent.ID).WillReturnRows(userRows(agent))
+		// The author and the requester watching are told once at most
+		rows := userRows(watcher)
+		for _, u := range []models.User{outsider, agent, requester} {
+			rows.AddRow(u.ID, u.Email, u.FirstName, u.LastName, u.Role, u.IsActive)
+		}
+		mock.ExpectQuery(`FROM "users" JOIN ticket_watchers ON ticket_watchers.user_id = users.id WHERE ticket_watchers.ticket_id = \$1`).
+			WithArgs(ticketID).WillReturnRows(rows)
+		var toRequester, toWatcher dbtest.Args
+		if !internal {
+			expectNotification(mock, &toRequester)
+			expectMode(mock, "")
+		}
+		expectNotification(mock, &toWatcher)
+		expectMode(mock, "")
+
+		event := events.Event{Type: events.CommentAdded, TicketID: ticketID, ActorID: agent.ID, CommentID: &commentID}
+		if err := n.Send(event); err != nil {
+			t.Fatalf("internal %v: %v", internal, err)
+		}
+
+		if !toWatcher.Contains(watcher.ID.String()) || !toWatcher.Contains(string(models.NotifyCommented)) {
+			t.Errorf("internal %v: watcher notification recorded with %v", internal, toWatcher.Values)
+		}
+		if len(transport.To(watcher.Email)) != 1 || len(transport.To(outsider.Email)) != 0 || len(transport.To(agent.Email)) != 0 {
+			t.Errorf("internal %v: %d emails to the watcher, %d to the outsider, %d to the author; want 1, 0, 0",
+				internal, len(transport.To(watcher.Email)), len(transport.To(outsider.Email)), len(transport.To(agent.Email)))
+		}
+		want := 1
+		if internal {
+			want = 0
+		}
+		if got := len(transport.To(requester.Email)); got != want {
+			t.Errorf("internal %v: %d emails to the requester, want %d", internal, got, want)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("internal %v: %v", internal, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultMode is used for events a user has not set a preference for
+const DefaultMode = models.ModeImmediate
+
+// Preferences returns how a user receives every event
+func Preferences(db *gorm.DB, userID uuid.UUID) (map[models.NotificationEvent]models.NotificationMode, error) {
+	var stored []models.NotificationPreference
+	if err := db.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	modes := make(map[models.NotificationEvent]models.NotificationMode, len(models.NotificationEvents))
+	for _, event := range models.NotificationEvents {
+		modes[event] = DefaultMode
+	}
+	for _, p := range stored {
+		if p.Event.IsValid() && p.Mode.IsValid() {
+			modes[p.Event] = p.Mode
+		}
+	}
+	return modes, nil
+}
+
+// SetPreferences stores the modes for the events given and leaves the
+// others alone. Notifications already waiting for a digest follow their
+// event: they move to the new digest, are dropped when it is switched off
+// and go out with the digest they were in when switched to immediate.
+func SetPreferences(db *gorm.DB, userID uuid.UUID, modes map[models.NotificationEvent]models.NotificationMode) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for event, mode := range modes {
+			preference := models.NotificationPreference{ID: uuid.New(), UserID: userID, Event: event, Mode: mode}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "event"}},
+				DoUpdates: clause.AssignmentColumns([]string{"mode", "updated_at"}),
+			}).Create(&preference).Error
+			if err != nil {
+				return err
+			}
+
+			pending := tx.Where("user_id = ? AND event = ? AND claimed_at IS NULL", userID, event)
+			switch {
+			case mode == models.ModeOff:
+				err = pending.Delete(&models.DigestItem{}).Error
+			case mode.IsDigest():
+				err = pending.Model(&models.DigestItem{}).UpdateColumn("mode", mode).Error
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mode returns how a user receives one event
+func (n *Notifier) mode(userID uuid.UUID, event models.NotificationEvent) (models.NotificationMode, error) {
+	var preference models.NotificationPreference
+	if err := n.db.Where("user_id = ? AND event = ?", userID, event).Limit(1).Find(&preference).Error; err != nil {
+		return "", err
+	}
+	if !preference.Mode.IsValid() {
+		return DefaultMode, nil
+	}
+	return preference.Mode, nil
+}
@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Retention deletes old entries from the notification centers
+type Retention struct {
+	db   *gorm.DB
+	keep time.Duration
+}
+
+// NewRetention returns a Retention that keeps notifications for keep
+func NewRetention(db *gorm.DB, keep time.Duration) *Retention {
+	return &Retention{db: db, keep: keep}
+}
+
+// RunDue deletes notifications created more than the retention period
+// ago, read or not
+func (r *Retention) RunDue(ctx context.Context, now time.Time) error {
+	return r.db.WithContext(ctx).Where("created_at < ?", now.Add(-r.keep)).Delete(&models.Notification{}).Error
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+)
+
+// SLA targets a warning can be about
+const (
+	targetFirstResponse = "first_response"
+	targetResolution    = "resolution"
+)
+
+// SLAWatcher warns agents about tickets about to miss their SLA
+type SLAWatcher struct {
+	notifier *Notifier
+	policy   reports.SLAPolicy
+	lead     time.Duration
+}
+
+// NewSLAWatcher returns an SLAWatcher that warns lead before a target
+func NewSLAWatcher(notifier *Notifier, policy reports.SLAPolicy, lead time.Duration) *SLAWatcher {
+	return &SLAWatcher{notifier: notifier, policy: policy, lead: lead}
+}
+
+// RunDue warns about every unresolved ticket whose first response or
+// resolution target falls within the lead time, once per target. The
+// assignee is warned, or the admins while nobody is assigned. Like due
+// reminders, each ticket is claimed by recording the deadline before
+// sending. A failed warning releases the claim to be tried again, unless
+// it reached someone already; then it is only logged, so nobody is warned
+// twice.
+func (w *SLAWatcher) RunDue(ctx context.Context, now time.Time) error {
+	var conditions []string
+	var args []interface{}
+	window := func(priority models.TicketPriority, target time.Duration, extra string) {
+		conditions = append(conditions, "(priority = ? AND created_at > ? AND created_at <= ?"+extra+")")
+		args = append(args, priority, now.Add(-target), now.Add(w.lead-target))
+	}
+	for priority, target := range w.policy.FirstResponse {
+		window(priority, target, " AND first_response_at IS NULL")
+	}
+	for priority, target := range w.policy.Resolution {
+		window(priority, target, "")
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	db := w.notifier.db
+	var tickets []models.Ticket
+	if err := db.WithContext(ctx).Preload("AssignedTo").
+		Where("status NOT IN ?", []models.TicketStatus{models.StatusResolved, models.StatusClosed}).
+		Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Find(&tickets).Error; err != nil {
+		return err
+	}
+
+	for i := range tickets {
+		ticket := &tickets[i]
+		target, deadline, ok := w.upcoming(ticket, now)
+		if !ok {
+			continue
+		}
+
+		claim := db.Model(&models.Ticket{}).
+			Where("id = ? AND (sla_warned_for IS NULL OR sla_warned_for <> ?)", ticket.ID, deadline).
+			UpdateColumn("sla_warned_for", deadline)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue // Warned already, maybe by another instance
+		}
+
+		if warned, err := w.warn(ticket, target, deadline); err != nil {
+			log.Printf("notify: SLA warning for ticket %s: %v", ticket.ID, err)
+			if warned {
+				continue
+			}
+			db.Model(&models.Ticket{}).
+				Where("id = ? AND sla_warned_for = ?", ticket.ID, deadline).
+				UpdateColumn("sla_warned_for", ticket.SLAWarnedFor)
+		}
+	}
+	return nil
+}
+
+// upcoming returns the earliest target a ticket misses within the lead
+// time
+func (w *SLAWatcher) upcoming(ticket *models.Ticket, now time.Time) (string, time.Time, bool) {
+	var target string
+	var deadline time.Time
+	consider := func(name string, limit time.Duration, ok bool) {
+		at := ticket.CreatedAt.Add(limit)
+		if ok && at.After(now) && !at.After(now.Add(w.lead)) && (target == "" || at.Before(deadline)) {
+			target, deadline = name, at
+		}
+	}
+	limit, ok := w.policy.FirstResponse[ticket.Priority]
+	consider(targetFirstResponse, limit, ok && ticket.FirstResponseAt == nil)
+	limit, ok = w.policy.Resolution[ticket.Priority]
+	consider(targetResolution, limit, ok)
+	return target, deadline, target != ""
+}
+
+// warn notifies the assignee or admins, reporting whether anyone was
+func (w *SLAWatcher) warn(ticket *models.Ticket, target string, deadline time.Time) (bool, error) {
+	var recipients []models.User
+	if ticket.AssignedTo != nil {
+		recipients = append(recipients, *ticket.AssignedTo)
+	} else if err := w.notifier.db.Where("role = ?", models.RoleAdmin).Find(&recipients).Error; err != nil {
+		return false, err
+	}
+
+	info := email.SLAInfo{Target: target, Deadline: deadline}
+	var out []notification
+	for i := range recipients {
+		if !notified(&recipients[i], uuid.Nil) {
+			continue
+		}
+		out = append(out, notification{user: &recipients[i], event: models.NotifySLAWarning, detail: target, deadline: &deadline, send: func(to email.Recipient) error {
+			return w.notifier.email.SendSLAWarningEmail(to, ticket, info)
+		}})
+	}
+	return w.notifier.dispatch(ticket, out)
+}
@@ -0,0 +1,24 @@
+package notify
+
+import "quickdesk-backend/internal/models"
+
+// watchers loads the users watching a ticket who can read a comment on it,
+// like mentioned: agents and admins, and the requester unless the comment
+// is internal. The author is left out.
+func (n *Notifier) watchers(ticket *models.Ticket, comment *models.Comment) ([]models.User, error) {
+	var users []models.User
+	if err := n.db.Joins("JOIN ticket_watchers ON ticket_watchers.user_id = users.id").
+		Where("ticket_watchers.ticket_id = ?", ticket.ID).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	var readers []models.User
+	for _, user := range users {
+		requester := user.ID == ticket.CreatedByID && !comment.IsInternal
+		if (user.Role != models.RoleUser || requester) && notified(&user, comment.UserID) {
+			readers = append(readers, user)
+		}
+	}
+	return readers, nil
+}
@@ -0,0 +1,227 @@
+// Package presence tracks which agents have a ticket open and whether they
+// are drafting a comment, so agents on the same ticket see each other and
+// an agent who is drafting is warned when someone else comments first.
+// Clients send heartbeats while the ticket is open; presence expires when
+// they stop.
+package presence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/realtime"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TTL is how long presence lasts without a heartbeat. Clients should send
+// one at least every TTL/3.
+const TTL = 30 * time.Second
+
+// Entry is an agent on a ticket as other agents see them
+type Entry struct {
+	UserID      uuid.UUID            `json:"user_id"`
+	Name        string               `json:"name"`
+	State       models.PresenceState `json:"state"`
+	TypingSince *time.Time           `json:"typing_since,omitempty"`
+	LastSeenAt  time.Time            `json:"last_seen_at"`
+}
+
+// Tracker records heartbeats and pushes presence changes and collision
+// warnings to the agents watching a ticket
+type Tracker struct {
+	db  *gorm.DB
+	hub *realtime.Hub
+}
+
+func NewTracker(db *gorm.DB, hub *realtime.Hub) *Tracker {
+	return &Tracker{db: db, hub: hub}
+}
+
+// Heartbeat records that a user has a ticket open in the given state. A
+// draft keeps its start time across heartbeats while the user stays typing.
+func (t *Tracker) Heartbeat(ticket *models.Ticket, userID uuid.UUID, state models.PresenceState, now time.Time) (*models.TicketPresence, error) {
+	var p models.TicketPresence
+	if err := t.db.Where("ticket_id = ? AND user_id = ?", ticket.ID, userID).Limit(1).Find(&p).Error; err != nil {
+		return nil, err
+	}
+	active := p.ID != uuid.Nil && p.LastSeenAt.After(now.Add(-TTL))
+	changed := !active || p.State != state
+
+	if p.ID == uuid.Nil {
+		p = models.TicketPresence{ID: uuid.New(), TicketID: ticket.ID, UserID: userID}
+	}
+	if state != models.PresenceTyping {
+		p.TypingSince = nil
+	} else if !active || p.State != models.PresenceTyping || p.TypingSince == nil {
+		p.TypingSince = &now
+	}
+	p.State = state
+	p.LastSeenAt = now
+	if err := t.db.Save(&p).Error; err != nil {
+		return nil, err
+	}
+
+	if changed {
+		t.publish(ticket, now)
+	}
+	return &p, nil
+}
+
+// Leave removes a user's presence on a ticket, e.g. when they close it
+func (t *Tracker) Leave(ticket *models.Ticket, userID uuid.UUID) error {
+	result := t.db.Where("ticket_id = ? AND user_id = ?", ticket.ID, userID).Delete(&models.TicketPresence{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		t.publish(ticket, time.Now())
+	}
+	return nil
+}
+
+// Present lists the agents with a ticket open, those who arrived first
+// first
+func (t *Tracker) Present(ticketID uuid.UUID, now time.Time) ([]Entry, error) {
+	var rows []models.TicketPresence
+	if err := t.db.Preload("User").
+		Where("ticket_id = ? AND last_seen_at > ?", ticketID, now.Add(-TTL)).
+		Order("created_at").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, p := range rows {
+		entries = append(entries, Entry{
+			UserID:      p.UserID,
+			Name:        p.User.FirstName + " " + p.User.LastName,
+			State:       p.State,
+			TypingSince: p.TypingSince,
+			LastSeenAt:  p.LastSeenAt,
+		})
+	}
+	return entries, nil
+}
+
+// CommentsSince lists the comments others added to the ticket since the
+// user started their draft; none unless they are typing
+func (t *Tracker) CommentsSince(p *models.TicketPresence) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	if p.State != models.PresenceTyping || p.TypingSince == nil {
+		return comments, nil
+	}
+	err := t.db.Preload("User").
+		Where("ticket_id = ? AND user_id <> ? AND created_at > ?", p.TicketID, p.UserID, *p.TypingSince).
+		Order("created_at").
+		Find(&comments).Error
+	return comments, err
+}
+
+// RunDue expires presence whose heartbeats stopped and tells the agents
+// still on those tickets
+func (t *Tracker) RunDue(ctx context.Context, now time.Time) error {
+	var expired []models.TicketPresence
+	if err := t.db.WithContext(ctx).
+		Raw("DELETE FROM ticket_presences WHERE last_seen_at <= ? RETURNING ticket_id", now.Add(-TTL)).
+		Scan(&expired).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[uuid.UUID]bool, len(expired))
+	for _, p := range expired {
+		if seen[p.TicketID] {
+			continue
+		}
+		seen[p.TicketID] = true
+		var ticket models.Ticket
+		if err := t.db.Unscoped().Select("id", "created_by_id").First(&ticket, "id = ?", p.TicketID).Error; err != nil {
+			continue
+		}
+		t.publish(&ticket, now)
+	}
+	return nil
+}
+
+// Handle warns agents drafting on a ticket when someone else comments on
+// it. It is meant to be subscribed to the ticket event bus.
+func (t *Tracker) Handle(e events.Event) {
+	if e.Type != events.CommentAdded || e.CommentID == nil {
+		return
+	}
+	go func() {
+		if err := t.collide(e); err != nil {
+			log.Printf("presence: %s for ticket %s: %v", e.Type, e.TicketID, err)
+		}
+	}()
+}
+
+func (t *Tracker) collide(e events.Event) error {
+	var comment models.Comment
+	if err := t.db.Select("id", "ticket_id", "user_id").First(&comment, "id = ?", *e.CommentID).Error; err != nil {
+		return err
+	}
+	var ticket models.Ticket
+	if err := t.db.Select("id", "created_by_id").First(&ticket, "id = ?", comment.TicketID).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+
+	// The author's draft was just posted
+	result := t.db.Model(&models.TicketPresence{}).
+		Where("ticket_id = ? AND user_id = ? AND state = ?", ticket.ID, comment.UserID, models.PresenceTyping).
+		Updates(map[string]interface{}{"state": models.PresenceViewing, "typing_since": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		t.publish(&ticket, now)
+	}
+
+	var drafting []models.TicketPresence
+	if err := t.db.Where("ticket_id = ? AND user_id <> ? AND state = ? AND last_seen_at > ?",
+		ticket.ID, comment.UserID, models.PresenceTyping, now.Add(-TTL)).
+		Find(&drafting).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, p := range drafting {
+		errs = append(errs, t.hub.Publish(realtime.Message{
+			Type:        realtime.PresenceCollision,
+			TicketID:    &ticket.ID,
+			RequesterID: ticket.CreatedByID,
+			ActorID:     comment.UserID,
+			CommentID:   &comment.ID,
+			Internal:    true,
+			UserID:      &p.UserID,
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// publish pushes who is on a ticket to the agents watching it. Presence is
+// advisory, so failures are only logged.
+func (t *Tracker) publish(ticket *models.Ticket, now time.Time) {
+	entries, err := t.Present(ticket.ID, now)
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(entries); err == nil {
+			err = t.hub.Publish(realtime.Message{
+				Type:        realtime.PresenceChanged,
+				TicketID:    &ticket.ID,
+				RequesterID: ticket.CreatedByID,
+				Internal:    true,
+				Presence:    data,
+			})
+		}
+	}
+	if err != nil {
+		log.Printf("presence: publishing for ticket %s: %v", ticket.ID, err)
+	}
+}
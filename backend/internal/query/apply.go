@@ -0,0 +1,178 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Viewer is the user a query is evaluated for. It resolves `me` and
+// decides which tickets are visible at all.
+type Viewer struct {
+	UserID uuid.UUID
+	Role   models.Role
+}
+
+// Visible restricts a tickets query to what the viewer may see:
+// users only see their own tickets, agents and admins see everything.
+func Visible(db *gorm.DB, v Viewer) *gorm.DB {
+	if v.Role == models.RoleUser || v.Role == "" {
+		return db.Where("tickets.created_by_id = ?", v.UserID)
+	}
+	return db
+}
+
+// VisibleViews scopes saved views to those the viewer owns, those shared
+// with everyone and those shared with one of the viewer's teams
+func VisibleViews(db *gorm.DB, v Viewer) *gorm.DB {
+	return db.Model(&models.SavedView{}).Where(
+		"owner_id = ? OR visibility = ? OR (visibility = ? AND team_id IN (SELECT team_id FROM team_members WHERE user_id = ?))",
+		v.UserID, models.VisibilityEveryone, models.VisibilityTeam, v.UserID,
+	)
+}
+
+// Sees reports whether the viewer may see a ticket opened by requester,
+// the same rule Visible applies to queries
+func (v Viewer) Sees(requesterID uuid.UUID) bool {
+	if v.Role == models.RoleUser || v.Role == "" {
+		return requesterID == v.UserID
+	}
+	return true
+}
+
+// Apply adds the query's conditions to a tickets query
+func (q *Query) Apply(db *gorm.DB, v Viewer) *gorm.DB {
+	if q == nil {
+		return db
+	}
+	for _, term := range q.Terms {
+		sql, args := term.condition(v)
+		if sql == "" {
+			continue
+		}
+		if term.Negated {
+			// A condition on a NULL column, such as an unassigned ticket's
+			// assignee, is NULL rather than false; count it as not matching
+			// so that -x is the complement of x
+			sql = "NOT COALESCE((" + sql + "), false)"
+		}
+		db = db.Where(sql, args...)
+	}
+	return db
+}
+
+// condition renders a term as a SQL fragment over the tickets table.
+// Values of one term are OR'ed together.
+func (t Term) condition(v Viewer) (string, []interface{}) {
+	var parts []string
+	var args []interface{}
+	for _, value := range t.Values {
+		sql, valueArgs := t.valueCondition(value, v)
+		if sql == "" {
+			continue
+		}
+		parts = append(parts, "("+sql+")")
+		args = append(args, valueArgs...)
+	}
+	return strings.Join(parts, " OR "), args
+}
+
+func (t Term) valueCondition(value string, v Viewer) (string, []interface{}) {
+	switch t.Field {
+	case FieldText:
+		pattern := "%" + value + "%"
+		return "tickets.subject ILIKE ? OR tickets.description ILIKE ?", []interface{}{pattern, pattern}
+	case FieldStatus:
+		return "tickets.status = ?", []interface{}{value}
+	case FieldPriority:
+		return "tickets.priority IN ?", []interface{}{priorities(t.Op, models.TicketPriority(value))}
+	case FieldAssignee:
+		if strings.EqualFold(value, "none") {
+			return "tickets.assigned_to_id IS NULL", nil
+		}
+		return userCondition("tickets.assigned_to_id", value, v)
+	case FieldCreator:
+		return userCondition("tickets.created_by_id", value, v)
+	case FieldCategory:
+		if id, err := uuid.Parse(value); err == nil {
+			return "tickets.category_id = ?", []interface{}{id}
+		}
+		return "tickets.category_id IN (SELECT id FROM categories WHERE name ILIKE ? AND deleted_at IS NULL)", []interface{}{value}
+	case FieldTag:
+		return "EXISTS (SELECT 1 FROM ticket_tags JOIN tags ON tags.id = ticket_tags.tag_id " +
+			"WHERE ticket_tags.ticket_id = tickets.id AND tags.name = ?)", []interface{}{strings.ToLower(value)}
+	case FieldCreated:
+		return dateCondition("tickets.created_at", t.Op, value)
+	case FieldUpdated:
+		return dateCondition("tickets.updated_at", t.Op, value)
+	case FieldDue:
+		if strings.EqualFold(value, "none") {
+			return "tickets.due_at IS NULL", nil
+		}
+		return dateCondition("tickets.due_at", t.Op, value)
+	case FieldOverdue:
+		sql := "tickets.due_at IS NOT NULL AND tickets.due_at < ? AND tickets.status NOT IN ?"
+		if overdue, _ := strconv.ParseBool(value); !overdue {
+			sql = "NOT (" + sql + ")"
+		}
+		return sql, []interface{}{time.Now(), []models.TicketStatus{models.StatusResolved, models.StatusClosed}}
+	}
+	return "", nil
+}
+
+// userCondition matches a user column by `me`, ID or email address
+func userCondition(column, value string, v Viewer) (string, []interface{}) {
+	if strings.EqualFold(value, "me") {
+		return column + " = ?", []interface{}{v.UserID}
+	}
+	if id, err := uuid.Parse(value); err == nil {
+		return column + " = ?", []interface{}{id}
+	}
+	return column + " IN (SELECT id FROM users WHERE email ILIKE ? AND deleted_at IS NULL)", []interface{}{value}
+}
+
+func dateCondition(column string, op Op, value string) (string, []interface{}) {
+	t, wholeDay, _ := parseDate(value)
+	if !wholeDay {
+		return column + " " + string(op) + " ?", []interface{}{t}
+	}
+
+	// A day matches from its first to its last instant
+	next := t.Add(24 * time.Hour)
+	switch op {
+	case OpGt:
+		return column + " >= ?", []interface{}{next}
+	case OpGte:
+		return column + " >= ?", []interface{}{t}
+	case OpLt:
+		return column + " < ?", []interface{}{t}
+	case OpLte:
+		return column + " < ?", []interface{}{next}
+	default:
+		return column + " >= ? AND " + column + " < ?", []interface{}{t, next}
+	}
+}
+
+// priorities lists the priorities satisfying `op p`
+func priorities(op Op, p models.TicketPriority) []models.TicketPriority {
+	rank := PriorityRank(p)
+	var matched []models.TicketPriority
+	for _, candidate := range []models.TicketPriority{
+		models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityUrgent,
+	} {
+		r := PriorityRank(candidate)
+		if (op == OpEq && r == rank) ||
+			(op == OpGt && r > rank) ||
+			(op == OpGte && r >= rank) ||
+			(op == OpLt && r < rank) ||
+			(op == OpLte && r <= rank) {
+			matched = append(matched, candidate)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,87 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// FacetCount is the number of tickets sharing one value of a facet
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+type facet struct {
+	value string // grouped expression
+	label string // display expression, empty when value is readable
+	joins string
+}
+
+// facets are computed with one GROUP BY per facet over the filtered
+// tickets query. Unassigned tickets count under the assignee value `none`.
+var facets = map[string]facet{
+	"status":   {value: "tickets.status"},
+	"priority": {value: "tickets.priority"},
+	"category": {
+		value: "tickets.category_id::text",
+		label: "categories.name",
+		joins: "JOIN categories ON categories.id = tickets.category_id",
+	},
+	"assignee": {
+		value: "COALESCE(tickets.assigned_to_id::text, 'none')",
+		label: "COALESCE(users.first_name || ' ' || users.last_name, '')",
+		joins: "LEFT JOIN users ON users.id = tickets.assigned_to_id",
+	},
+	"tag": {
+		value: "tags.name",
+		joins: "JOIN ticket_tags ON ticket_tags.ticket_id = tickets.id JOIN tags ON tags.id = ticket_tags.tag_id",
+	},
+}
+
+// ParseFacets splits a `facets` parameter such as "status,priority"
+func ParseFacets(param string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := facets[name]; !ok {
+			return nil, fmt.Errorf("unknown facet %q, expected status, priority, category, assignee or tag", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Facets counts the tickets matched by db for each named facet. db must be
+// a filtered tickets query without ordering or paging.
+func Facets(db *gorm.DB, names []string) (map[string][]FacetCount, error) {
+	result := make(map[string][]FacetCount, len(names))
+	for _, name := range names {
+		f := facets[name]
+
+		label, group := "''", f.value
+		if f.label != "" {
+			label, group = f.label, f.value+", "+f.label
+		}
+
+		counts := []FacetCount{}
+		q := db.Session(&gorm.Session{})
+		if f.joins != "" {
+			q = q.Joins(f.joins)
+		}
+		err := q.Select(f.value + " AS value, " + label + " AS label, COUNT(*) AS count").
+			Group(group).
+			Order("count DESC, value").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, err
+		}
+		result[name] = counts
+	}
+	return result, nil
+}
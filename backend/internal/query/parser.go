@@ -0,0 +1,336 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+)
+
+// Op is the comparison operator of a field term
+type Op string
+
+const (
+	OpEq  Op = "="
+	OpGt  Op = ">"
+	OpGte Op = ">="
+	OpLt  Op = "<"
+	OpLte Op = "<="
+)
+
+// Field names understood by the parser. FieldText is used for bare words.
+const (
+	FieldText     = "text"
+	FieldStatus   = "status"
+	FieldPriority = "priority"
+	FieldAssignee = "assignee"
+	FieldCreator  = "creator"
+	FieldCategory = "category"
+	FieldTag      = "tag"
+	FieldCreated  = "created"
+	FieldUpdated  = "updated"
+	FieldDue      = "due"
+	FieldOverdue  = "overdue"
+)
+
+// fieldAliases maps accepted spellings to their canonical field name
+var fieldAliases = map[string]string{
+	"text":        FieldText,
+	"status":      FieldStatus,
+	"priority":    FieldPriority,
+	"assignee":    FieldAssignee,
+	"assigned_to": FieldAssignee,
+	"creator":     FieldCreator,
+	"author":      FieldCreator,
+	"created_by":  FieldCreator,
+	"category":    FieldCategory,
+	"tag":         FieldTag,
+	"created":     FieldCreated,
+	"updated":     FieldUpdated,
+	"due":         FieldDue,
+	"due_at":      FieldDue,
+	"overdue":     FieldOverdue,
+}
+
+// Term is a single filter such as `-priority:>=high`
+type Term struct {
+	Field   string   `json:"field"`
+	Op      Op       `json:"op"`
+	Values  []string `json:"values"` // Multiple values are OR'ed together
+	Negated bool     `json:"negated,omitempty"`
+	Pos     int      `json:"pos"` // 1-based column of the term in the input
+}
+
+// Query is a parsed ticket query; all terms must match
+type Query struct {
+	Terms []Term `json:"terms"`
+}
+
+// SyntaxError describes an invalid query and where it went wrong
+type SyntaxError struct {
+	Pos int // 1-based column
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query syntax error at column %d: %s", e.Pos, e.Msg)
+}
+
+// Parse parses a query such as
+//
+//	status:open priority:>=high assignee:me category:"Network" created:>2026-01-01 -tag:spam
+//
+// `due:none` matches tickets without a due date and `overdue:true` those
+// past it that are not yet resolved.
+//
+// Bare words and quoted phrases search the subject and description.
+func Parse(input string) (*Query, error) {
+	p := &parser{input: input}
+	q := &Query{}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return q, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+}
+
+// Add appends a term to the query. It is used to merge shortcut
+// parameters such as `status` into a parsed query.
+func (q *Query) Add(field string, op Op, values ...string) {
+	q.Terms = append(q.Terms, Term{Field: field, Op: op, Values: values})
+}
+
+// Validate checks a value for a field the way the parser does, for
+// terms built with Add
+func Validate(field string, op Op, value string) error {
+	return validate(field, op, value)
+}
+
+type parser struct {
+	input string
+	pos   int // byte offset
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() byte {
+	return p.input[p.pos]
+}
+
+func (p *parser) skipSpace() {
+	for !p.eof() && isSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(pos int, format string, args ...interface{}) error {
+	return &SyntaxError{Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) term() (Term, error) {
+	start := p.pos
+	term := Term{Op: OpEq, Pos: start + 1}
+
+	if p.peek() == '-' && p.pos+1 < len(p.input) && !isSpace(p.input[p.pos+1]) {
+		term.Negated = true
+		p.pos++
+	}
+
+	// Quoted phrases are always free text
+	if p.peek() == '"' {
+		value, err := p.quoted()
+		if err != nil {
+			return term, err
+		}
+		term.Field = FieldText
+		term.Values = []string{value}
+		return term, p.endOfTerm()
+	}
+
+	wordStart := p.pos
+	for !p.eof() && !isSpace(p.peek()) && p.peek() != ':' && p.peek() != '"' {
+		p.pos++
+	}
+	word := p.input[wordStart:p.pos]
+
+	if p.eof() || p.peek() != ':' {
+		if !p.eof() && p.peek() == '"' {
+			return term, p.errorf(p.pos, "unexpected quote")
+		}
+		if word == "" {
+			return term, p.errorf(wordStart, "expected a search term")
+		}
+		term.Field = FieldText
+		term.Values = []string{word}
+		return term, nil
+	}
+
+	field, ok := fieldAliases[strings.ToLower(word)]
+	if !ok {
+		return term, p.errorf(wordStart, "unknown field %q", word)
+	}
+	term.Field = field
+	p.pos++ // consume ':'
+
+	term.Op = p.op()
+	valuesStart := p.pos
+	for {
+		valueStart := p.pos
+		var value string
+		if !p.eof() && p.peek() == '"' {
+			v, err := p.quoted()
+			if err != nil {
+				return term, err
+			}
+			value = v
+		} else {
+			for !p.eof() && !isSpace(p.peek()) && p.peek() != ',' && p.peek() != '"' {
+				p.pos++
+			}
+			value = p.input[valueStart:p.pos]
+			if value == "" {
+				return term, p.errorf(valueStart, "missing value for %s", word)
+			}
+		}
+		if err := validate(field, term.Op, value); err != nil {
+			return term, p.errorf(valueStart, "%s", err)
+		}
+		term.Values = append(term.Values, value)
+
+		if p.eof() || p.peek() != ',' {
+			break
+		}
+		p.pos++ // consume ','
+	}
+
+	if term.Op != OpEq && len(term.Values) > 1 {
+		return term, p.errorf(valuesStart, "comparison operators take a single value")
+	}
+	return term, p.endOfTerm()
+}
+
+func (p *parser) op() Op {
+	for _, op := range []Op{OpGte, OpLte, OpGt, OpLt, OpEq} {
+		if strings.HasPrefix(p.input[p.pos:], string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return OpEq
+}
+
+// quoted reads a double-quoted string; \" and \\ are the only escapes
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	var b strings.Builder
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\\' && p.pos+1 < len(p.input):
+			b.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+		case c == '"':
+			p.pos++
+			if b.Len() == 0 {
+				return "", p.errorf(start, "empty quoted value")
+			}
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf(start, "unterminated quoted string")
+}
+
+func (p *parser) endOfTerm() error {
+	if !p.eof() && !isSpace(p.peek()) {
+		return p.errorf(p.pos, "unexpected %q", p.peek())
+	}
+	return nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func validate(field string, op Op, value string) error {
+	switch field {
+	case FieldStatus:
+		if op != OpEq {
+			return fmt.Errorf("status does not support %s", op)
+		}
+		if !models.TicketStatus(value).IsValid() {
+			return fmt.Errorf("invalid status %q", value)
+		}
+		return nil
+	case FieldPriority:
+		if PriorityRank(models.TicketPriority(value)) == 0 {
+			return fmt.Errorf("invalid priority %q", value)
+		}
+		return nil
+	case FieldDue:
+		if strings.EqualFold(value, "none") {
+			if op != OpEq {
+				return fmt.Errorf("due:none does not support %s", op)
+			}
+			return nil
+		}
+		fallthrough
+	case FieldCreated, FieldUpdated:
+		if _, _, err := parseDate(value); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+		}
+		return nil
+	case FieldOverdue:
+		if op != OpEq {
+			return fmt.Errorf("overdue does not support %s", op)
+		}
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("overdue must be true or false")
+		}
+		return nil
+	default:
+		if op != OpEq {
+			return fmt.Errorf("%s does not support %s", field, op)
+		}
+		return nil
+	}
+}
+
+// PriorityRank orders priorities from low (1) to urgent (4); unknown
+// priorities rank 0.
+func PriorityRank(p models.TicketPriority) int {
+	switch p {
+	case models.PriorityLow:
+		return 1
+	case models.PriorityMedium:
+		return 2
+	case models.PriorityHigh:
+		return 3
+	case models.PriorityUrgent:
+		return 4
+	}
+	return 0
+}
+
+// parseDate returns the instant a value refers to and whether it names a
+// whole day (YYYY-MM-DD) rather than a point in time
+func parseDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
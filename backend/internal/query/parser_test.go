@@ -0,0 +1,82 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Term
+	}{
+		{"", nil},
+		{"printer", []Term{{Field: FieldText, Op: OpEq, Values: []string{"printer"}, Pos: 1}}},
+		{`"paper jam" status:open`, []Term{
+			{Field: FieldText, Op: OpEq, Values: []string{"paper jam"}, Pos: 1},
+			{Field: FieldStatus, Op: OpEq, Values: []string{"open"}, Pos: 13},
+		}},
+		{"priority:>=high", []Term{{Field: FieldPriority, Op: OpGte, Values: []string{"high"}, Pos: 1}}},
+		{"-tag:spam", []Term{{Field: FieldTag, Op: OpEq, Values: []string{"spam"}, Negated: true, Pos: 1}}},
+		{"status:open,in_progress", []Term{{Field: FieldStatus, Op: OpEq, Values: []string{"open", "in_progress"}, Pos: 1}}},
+		{`Category:"Network gear"`, []Term{{Field: FieldCategory, Op: OpEq, Values: []string{"Network gear"}, Pos: 1}}},
+		{"assigned_to:me author:bob@example.com", []Term{
+			{Field: FieldAssignee, Op: OpEq, Values: []string{"me"}, Pos: 1},
+			{Field: FieldCreator, Op: OpEq, Values: []string{"bob@example.com"}, Pos: 16},
+		}},
+		{"created:>2026-01-01 due:none overdue:true", []Term{
+			{Field: FieldCreated, Op: OpGt, Values: []string{"2026-01-01"}, Pos: 1},
+			{Field: FieldDue, Op: OpEq, Values: []string{"none"}, Pos: 21},
+			{Field: FieldOverdue, Op: OpEq, Values: []string{"true"}, Pos: 30},
+		}},
+		{`"say \"hi\""`, []Term{{Field: FieldText, Op: OpEq, Values: []string{`say "hi"`}, Pos: 1}}},
+		// A lone dash is a word, not a negation
+		{"- x", []Term{
+			{Field: FieldText, Op: OpEq, Values: []string{"-"}, Pos: 1},
+			{Field: FieldText, Op: OpEq, Values: []string{"x"}, Pos: 3},
+		}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(q.Terms, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, q.Terms, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+	}{
+		{"colour:red", 1},
+		{"status:", 8},
+		{"status:pending", 8},
+		{"status:>open", 9},
+		{"priority:extreme", 10},
+		{"created:yesterday", 9},
+		{"due:<none", 6},
+		{"overdue:maybe", 9},
+		{"priority:>high,low", 11},
+		{`"unterminated`, 1},
+		{`""`, 1},
+		{`status:"open"x`, 14},
+		{`foo"bar`, 4},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) error = %v, want a SyntaxError", tt.input, err)
+			continue
+		}
+		if syntaxErr.Pos != tt.pos {
+			t.Errorf("Parse(%q) error at column %d, want %d: %v", tt.input, syntaxErr.Pos, tt.pos, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for cursors that cannot be decoded or were
+// issued for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type sortKind int
+
+const (
+	sortTime sortKind = iota
+	sortString
+	sortInt
+)
+
+type sortField struct {
+	expr string
+	kind sortKind
+}
+
+// sortFields whitelists the sortable ticket fields. Every sort is broken
+// by ticket ID so keyset pagination has a total order.
+var sortFields = map[string]sortField{
+	"created_at": {"tickets.created_at", sortTime},
+	"updated_at": {"tickets.updated_at", sortTime},
+	"subject":    {"tickets.subject", sortString},
+	"status":     {"tickets.status", sortString},
+	"priority": {"CASE tickets.priority WHEN 'low' THEN 1 WHEN 'medium' THEN 2 " +
+		"WHEN 'high' THEN 3 WHEN 'urgent' THEN 4 ELSE 0 END", sortInt},
+	"up_votes":   {"tickets.up_votes", sortInt},
+	"down_votes": {"tickets.down_votes", sortInt},
+	"view_count": {"tickets.view_count", sortInt},
+	"most_replied": {"(SELECT COUNT(*) FROM comments WHERE comments.ticket_id = tickets.id " +
+		"AND comments.deleted_at IS NULL)", sortInt},
+}
+
+// Sort is a validated ticket sort order
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultSort lists the newest tickets first
+var DefaultSort = Sort{Field: "created_at", Desc: true}
+
+// ParseSort validates `sort_by` and `sort_order` against the whitelist.
+// Empty values fall back to DefaultSort and descending order.
+func ParseSort(sortBy, sortOrder string) (Sort, error) {
+	s := DefaultSort
+	if sortBy != "" {
+		if _, ok := sortFields[sortBy]; !ok {
+			return s, fmt.Errorf("cannot sort by %q, expected one of %s", sortBy, strings.Join(SortFields(), ", "))
+		}
+		s.Field = sortBy
+	}
+
+	switch strings.ToLower(sortOrder) {
+	case "", "desc":
+		s.Desc = true
+	case "asc":
+		s.Desc = false
+	default:
+		return s, fmt.Errorf("sort_order must be asc or desc")
+	}
+	return s, nil
+}
+
+// SortFields lists the accepted `sort_by` values
+func SortFields() []string {
+	return []string{"created_at", "updated_at", "subject", "status", "priority",
+		"up_votes", "down_votes", "view_count", "most_replied"}
+}
+
+func (s Sort) direction() string {
+	if s.Desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// Order adds the ORDER BY clause for the sort
+func (s Sort) Order(db *gorm.DB) *gorm.DB {
+	field := sortFields[s.Field]
+	return db.Order(field.expr + " " + s.direction()).Order("tickets.id " + s.direction())
+}
+
+// cursor is the decoded form of an opaque page cursor: the sort key and
+// ID of the last ticket on the previous page
+type cursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d"`
+	Value interface{} `json:"v"`
+	ID    uuid.UUID   `json:"id"`
+}
+
+// After restricts db to tickets that come after the cursor in this sort
+func (s Sort) After(db *gorm.DB, encoded string) (*gorm.DB, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != s.Field || c.Desc != s.Desc {
+		return nil, ErrInvalidCursor
+	}
+
+	field := sortFields[s.Field]
+	var value interface{}
+	switch v := c.Value.(type) {
+	case string:
+		if field.kind == sortTime {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			value = t
+		} else if field.kind == sortString {
+			value = v
+		} else {
+			return nil, ErrInvalidCursor
+		}
+	case float64:
+		if field.kind != sortInt {
+			return nil, ErrInvalidCursor
+		}
+		value = int64(v)
+	default:
+		return nil, ErrInvalidCursor
+	}
+
+	op := ">"
+	if s.Desc {
+		op = "<"
+	}
+	return db.Where("("+field.expr+", tickets.id) "+op+" (?, ?)", value, c.ID), nil
+}
+
+// Cursor returns the opaque cursor that continues after ticket t
+func (s Sort) Cursor(db *gorm.DB, t *models.Ticket) (string, error) {
+	var value interface{}
+	switch s.Field {
+	case "created_at":
+		value = t.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		value = t.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case "subject":
+		value = t.Subject
+	case "status":
+		value = string(t.Status)
+	case "priority":
+		value = PriorityRank(t.Priority)
+	case "up_votes":
+		value = t.UpVotes
+	case "down_votes":
+		value = t.DownVotes
+	case "view_count":
+		value = t.ViewCount
+	case "most_replied":
+		var count int64
+		if err := db.Model(&models.Comment{}).Where("ticket_id = ?", t.ID).Count(&count).Error; err != nil {
+			return "", err
+		}
+		value = count
+	}
+
+	raw, err := json.Marshal(cursor{Sort: s.Field, Desc: s.Desc, Value: value, ID: t.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
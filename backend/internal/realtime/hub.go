@@ -0,0 +1,362 @@
+// Package realtime pushes ticket activity and notifications to connected
+// clients. Messages go through Postgres NOTIFY, and every server instance
+// LISTENs and hands them to its own clients, so a client sees changes made
+// through any instance.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// channel is the Postgres NOTIFY channel shared by all instances
+const channel = "quickdesk_realtime"
+
+// Message types besides the ticket event types
+const (
+	NotificationCreated = "notification.created"
+	NotificationRead    = "notification.read"
+	// PresenceChanged lists the agents who have a ticket open
+	PresenceChanged = "presence.changed"
+	// PresenceCollision warns an agent drafting a comment that someone
+	// else commented on the ticket
+	PresenceCollision = "presence.collision"
+	// Resync tells clients they may have missed messages, e.g. while the
+	// listener reconnected, and should reload what they show
+	Resync = "resync"
+)
+
+// sendBuffer is how many frames a client may fall behind before it is
+// disconnected; it reconnects and reloads
+const sendBuffer = 64
+
+// Message is what travels between instances. NOTIFY payloads are limited
+// to 8000 bytes, so it carries IDs rather than records; the receiving
+// instance loads the comment or notification for the clients that get it.
+type Message struct {
+	Type           string     `json:"type"`
+	TicketID       *uuid.UUID `json:"ticket_id,omitempty"`
+	RequesterID    uuid.UUID  `json:"requester_id,omitempty"` // Decides who may see a ticket message
+	ActorID        uuid.UUID  `json:"actor_id,omitempty"`
+	Changes        []string   `json:"changes,omitempty"`
+	CommentID      *uuid.UUID `json:"comment_id,omitempty"`
+	Internal       bool       `json:"internal,omitempty"` // Only agents and admins get it, e.g. internal comments
+	UserID         *uuid.UUID `json:"user_id,omitempty"`  // Only this user gets the message
+	NotificationID *uuid.UUID `json:"notification_id,omitempty"`
+	// Presence is the encoded presence list of presence.changed, small
+	// enough to travel with the message
+	Presence json.RawMessage `json:"presence,omitempty"`
+}
+
+// Event is what clients receive
+type Event struct {
+	Type         string               `json:"type"`
+	TicketID     *uuid.UUID           `json:"ticket_id,omitempty"`
+	ActorID      *uuid.UUID           `json:"actor_id,omitempty"`
+	Changes      []string             `json:"changes,omitempty"`
+	Comment      *models.Comment      `json:"comment,omitempty"`
+	Notification *models.Notification `json:"notification,omitempty"`
+	// NotificationID is set for notification.read; empty means all
+	NotificationID *uuid.UUID      `json:"notification_id,omitempty"`
+	Presence       json.RawMessage `json:"presence,omitempty"`
+}
+
+// Frame is an encoded Event ready to be written to a client
+type Frame struct {
+	Type string
+	Data []byte
+}
+
+// Subscription says what a client wants to hear about
+type Subscription struct {
+	Viewer        query.Viewer
+	Tickets       bool        // Changes to any ticket the viewer can see
+	TicketIDs     []uuid.UUID // Changes and comments on these tickets
+	Notifications bool        // The viewer's own notifications
+}
+
+// Client is one connected subscriber
+type Client struct {
+	sub     Subscription
+	tickets map[uuid.UUID]bool
+	frames  chan Frame
+	done    chan struct{}
+}
+
+// Frames delivers the client's frames until Done is closed
+func (c *Client) Frames() <-chan Frame {
+	return c.frames
+}
+
+// Done is closed when the hub drops the client for falling behind
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// wants reports whether a message concerns the client and may be shown
+// to it
+func (c *Client) wants(m *Message) bool {
+	if m.Type == Resync {
+		return true
+	}
+	if m.UserID != nil && *m.UserID != c.sub.Viewer.UserID {
+		return false
+	}
+	if m.Type == NotificationCreated || m.Type == NotificationRead {
+		return c.sub.Notifications
+	}
+	if m.TicketID == nil || !c.sub.Viewer.Sees(m.RequesterID) {
+		return false
+	}
+	agent := c.sub.Viewer.Role == models.RoleAgent || c.sub.Viewer.Role == models.RoleAdmin
+	if m.Internal && !agent {
+		return false
+	}
+	return c.sub.Tickets || c.tickets[*m.TicketID]
+}
+
+// Hub fans messages out to the clients connected to this instance
+type Hub struct {
+	db  *gorm.DB
+	dsn string
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+}
+
+// NewHub returns a hub that publishes through db and listens on its own
+// connection to dsn. A nil Hub drops everything published to it.
+func NewHub(db *gorm.DB, dsn string) *Hub {
+	return &Hub{db: db, dsn: dsn, clients: make(map[*Client]struct{})}
+}
+
+// Subscribe connects a client
+func (h *Hub) Subscribe(sub Subscription) *Client {
+	c := &Client{
+		sub:     sub,
+		tickets: make(map[uuid.UUID]bool, len(sub.TicketIDs)),
+		frames:  make(chan Frame, sendBuffer),
+		done:    make(chan struct{}),
+	}
+	for _, id := range sub.TicketIDs {
+		c.tickets[id] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+	return c
+}
+
+// Unsubscribe disconnects a client
+func (h *Hub) Unsubscribe(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(c)
+}
+
+// Close disconnects every client, so their streams end and they reconnect,
+// to another instance when this one is shutting down
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		h.drop(c)
+	}
+}
+
+// drop removes a client; h.mu must be held
+func (h *Hub) drop(c *Client) {
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.done)
+	}
+}
+
+// Publish sends a message to the clients of every instance
+func (h *Hub) Publish(m Message) error {
+	if h == nil {
+		return nil
+	}
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return h.db.Exec("SELECT pg_notify(?, ?)", channel, string(payload)).Error
+}
+
+// Handle publishes ticket events in the background. It is meant to be
+// subscribed to the ticket event bus.
+func (h *Hub) Handle(e events.Event) {
+	go func() {
+		if err := h.publishEvent(e); err != nil {
+			log.Printf("realtime: %s for ticket %s: %v", e.Type, e.TicketID, err)
+		}
+	}()
+}
+
+func (h *Hub) publishEvent(e events.Event) error {
+	// Deleted tickets still need their requester for the visibility check
+	var ticket models.Ticket
+	if err := h.db.Unscoped().Select("id", "created_by_id").First(&ticket, "id = ?", e.TicketID).Error; err != nil {
+		return err
+	}
+
+	m := Message{
+		Type:        string(e.Type),
+		TicketID:    &ticket.ID,
+		RequesterID: ticket.CreatedByID,
+		ActorID:     e.ActorID,
+		Changes:     e.Changes,
+		CommentID:   e.CommentID,
+	}
+	if e.CommentID != nil {
+		var comment models.Comment
+		if err := h.db.Select("id", "is_internal").First(&comment, "id = ?", *e.CommentID).Error; err != nil {
+			return err
+		}
+		m.Internal = comment.IsInternal
+	}
+	return h.Publish(m)
+}
+
+// Start listens for messages until ctx is cancelled, reconnecting after
+// errors. Clients are told to resync once listening again, since messages
+// sent in between are lost.
+func (h *Hub) Start(ctx context.Context) {
+	go func() {
+		backoff, resync := time.Second, false
+		for {
+			connected, err := h.listen(ctx, resync)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				backoff = time.Second
+			}
+			resync = true
+			log.Printf("realtime: listening: %v; retrying in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, 30*time.Second)
+		}
+	}()
+}
+
+// listen delivers notifications from one connection until it fails,
+// reporting whether it got as far as listening
+func (h *Hub) listen(ctx context.Context, resync bool) (bool, error) {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+		return false, err
+	}
+	if resync {
+		h.deliver(&Message{Type: Resync})
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		var m Message
+		if err := json.Unmarshal([]byte(n.Payload), &m); err != nil {
+			log.Printf("realtime: bad message %q: %v", n.Payload, err)
+			continue
+		}
+		h.deliver(&m)
+	}
+}
+
+// deliver hands a message to the local clients that want it. Comments and
+// notifications are only loaded when some client gets them.
+func (h *Hub) deliver(m *Message) {
+	h.mu.RLock()
+	var recipients []*Client
+	for c := range h.clients {
+		if c.wants(m) {
+			recipients = append(recipients, c)
+		}
+	}
+	h.mu.RUnlock()
+	if len(recipients) == 0 {
+		return
+	}
+
+	event, err := h.event(m)
+	if err != nil {
+		log.Printf("realtime: loading %s: %v", m.Type, err)
+		return
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("realtime: encoding %s: %v", m.Type, err)
+		return
+	}
+	frame := Frame{Type: m.Type, Data: data}
+
+	var slow []*Client
+	for _, c := range recipients {
+		select {
+		case c.frames <- frame:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, c := range slow {
+			h.drop(c)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// event builds what clients see of a message
+func (h *Hub) event(m *Message) (*Event, error) {
+	event := &Event{Type: m.Type, TicketID: m.TicketID, Changes: m.Changes, Presence: m.Presence}
+	if m.ActorID != uuid.Nil {
+		event.ActorID = &m.ActorID
+	}
+	if m.CommentID != nil {
+		event.Comment = &models.Comment{}
+		if err := h.db.Preload("User").First(event.Comment, "id = ?", *m.CommentID).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	switch m.Type {
+	case NotificationCreated:
+		if m.NotificationID == nil {
+			break
+		}
+		event.Notification = &models.Notification{}
+		if err := h.db.Preload("Ticket").First(event.Notification, "id = ?", *m.NotificationID).Error; err != nil {
+			return nil, err
+		}
+	case NotificationRead:
+		event.NotificationID = m.NotificationID
+	}
+	return event, nil
+}
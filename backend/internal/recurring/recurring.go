@@ -0,0 +1,134 @@
+// Package recurring opens tickets from admin-defined templates on a cron
+// schedule.
+package recurring
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/scheduler"
+	"quickdesk-backend/internal/tickets"
+
+	"gorm.io/gorm"
+)
+
+// Runner creates the tickets of due templates
+type Runner struct {
+	db     *gorm.DB
+	events *events.Bus
+}
+
+func NewRunner(db *gorm.DB, bus *events.Bus) *Runner {
+	return &Runner{db: db, events: bus}
+}
+
+// NextRun returns when a template fires next after t
+func NextRun(template *models.RecurringTicket, t time.Time) (time.Time, error) {
+	return scheduler.NextRun(template.Schedule, template.Timezone, t)
+}
+
+// RunDue opens a ticket for every active template whose next run has
+// passed. Moving the template to its next run and creating the ticket
+// happen in one transaction, and the ticket is unique per template and
+// occurrence, so each occurrence opens exactly one ticket even with
+// several server instances. Occurrences missed while the server was down
+// are caught up with a single ticket.
+func (r *Runner) RunDue(ctx context.Context, now time.Time) error {
+	var due []models.RecurringTicket
+	if err := r.db.WithContext(ctx).Where("is_active = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for i := range due {
+		template := &due[i]
+		next, err := NextRun(template, now)
+		if err != nil {
+			// An unparseable schedule can never run; stop it from being retried
+			r.db.Model(template).Updates(map[string]interface{}{"is_active": false, "next_run_at": nil, "last_error": err.Error()})
+			continue
+		}
+
+		ticket, err := r.create(template, *template.NextRunAt, &next)
+		if errors.Is(err, tickets.ErrInvalidCategory) || errors.Is(err, tickets.ErrInvalidAssignee) {
+			// The category or assignee is gone; retrying would fail forever
+			r.db.Model(template).Updates(map[string]interface{}{"is_active": false, "next_run_at": nil, "last_error": err.Error()})
+			continue
+		}
+		if err != nil {
+			// The claim was rolled back, so the next run retries
+			log.Printf("recurring: template %s: %v", template.ID, err)
+			r.db.Model(template).UpdateColumn("last_error", err.Error())
+			continue
+		}
+		if ticket != nil {
+			r.events.Publish(events.Event{Type: events.TicketCreated, TicketID: ticket.ID, ActorID: template.CreatedByID})
+		}
+	}
+	return nil
+}
+
+// RunNow opens a template's ticket immediately, outside its schedule
+func (r *Runner) RunNow(template *models.RecurringTicket, now time.Time) (*models.Ticket, error) {
+	ticket, err := r.create(template, now, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.events.Publish(events.Event{Type: events.TicketCreated, TicketID: ticket.ID, ActorID: template.CreatedByID})
+	return ticket, nil
+}
+
+// create opens the ticket for one occurrence. With next set, the template
+// is claimed by moving its next run forward in the same transaction; a
+// nil ticket and error mean another instance claimed it first.
+func (r *Runner) create(template *models.RecurringTicket, occurrence time.Time, next *time.Time) (*models.Ticket, error) {
+	var ticket *models.Ticket
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if next != nil {
+			claim := tx.Model(&models.RecurringTicket{}).
+				Where("id = ? AND next_run_at = ?", template.ID, occurrence).
+				Update("next_run_at", *next)
+			if claim.Error != nil {
+				return claim.Error
+			}
+			if claim.RowsAffected == 0 {
+				return nil // Another instance took it
+			}
+		}
+
+		newTicket := tickets.NewTicket{
+			Subject:      template.Subject,
+			Description:  template.Description,
+			Priority:     template.Priority,
+			CategoryID:   template.CategoryID,
+			CreatedByID:  template.CreatedByID,
+			AssignedToID: template.AssignedToID,
+			Tags:         strings.Split(template.Tags, ","),
+			RecurrenceID: &template.ID,
+			RecurrenceAt: &occurrence,
+		}
+		if template.DueAfter > 0 {
+			dueAt := time.Now().Add(time.Duration(template.DueAfter) * time.Minute)
+			newTicket.DueAt = &dueAt
+		}
+
+		var err error
+		ticket, err = tickets.Create(tx, newTicket)
+		if err != nil {
+			return err
+		}
+		return tx.Model(template).Updates(map[string]interface{}{
+			"last_run_at":    time.Now(),
+			"last_ticket_id": ticket.ID,
+			"last_error":     "",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ticket, nil
+}
@@ -0,0 +1,221 @@
+package reports
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AgentPerformance summarises the tickets an agent resolved in a period.
+// Rates are percentages of handled tickets and null when nothing was
+// handled.
+type AgentPerformance struct {
+	AgentID                 uuid.UUID `json:"agent_id"`
+	Name                    string    `json:"name"`
+	Email                   string    `json:"email"`
+	Handled                 int       `json:"handled"`
+	MedianResolutionSeconds *float64  `json:"median_resolution_seconds"`
+	ReopenPercent           *float64  `json:"reopen_percent"`
+	CommentsPerTicket       *float64  `json:"comments_per_ticket"`
+	SLAHit                  int       `json:"sla_hit"`
+	SLAMissed               int       `json:"sla_missed"`
+	SLAHitPercent           *float64  `json:"sla_hit_percent"`
+	SLAMissPercent          *float64  `json:"sla_miss_percent"`
+	CSATAverage             *float64  `json:"csat_average"` // Mean 1-5 rating of surveys answered in the period
+	CSATResponses           int       `json:"csat_responses"`
+}
+
+// resolvedTicket is the slice of a ticket the reports work from
+type resolvedTicket struct {
+	ID              uuid.UUID
+	AssignedToID    *uuid.UUID
+	Priority        models.TicketPriority
+	CreatedAt       time.Time
+	FirstResponseAt *time.Time
+	ResolvedAt      time.Time
+	ReopenCount     int
+}
+
+func loadResolved(db *gorm.DB, f Filter) ([]resolvedTicket, error) {
+	var rows []resolvedTicket
+	err := f.resolvedTickets(db.Model(&models.Ticket{})).
+		Select("tickets.id, tickets.assigned_to_id, tickets.priority, tickets.created_at, tickets.first_response_at, tickets.resolved_at, tickets.reopen_count").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// AgentReport computes performance for every agent and admin, or the
+// members of the filter's team. A ticket counts towards its current
+// assignee when it was resolved in the filter's range; survey ratings
+// count towards the agent the ticket was resolved by.
+func AgentReport(db *gorm.DB, f Filter, policy SLAPolicy) ([]AgentPerformance, error) {
+	agents := db.Model(&models.User{}).Where("users.role IN ?", []models.Role{models.RoleAgent, models.RoleAdmin})
+	if f.TeamID != nil {
+		agents = agents.Where("users.id IN (SELECT user_id FROM team_members WHERE team_id = ?)", *f.TeamID)
+	}
+	var users []models.User
+	if err := agents.Order("first_name, last_name").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	resolved, err := loadResolved(db, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments each assignee wrote on the tickets they handled
+	var commentCounts []struct {
+		AgentID uuid.UUID
+		Count   int
+	}
+	err = f.resolvedTickets(db.Model(&models.Comment{})).
+		Joins("JOIN tickets ON tickets.id = comments.ticket_id AND tickets.deleted_at IS NULL").
+		Where("comments.user_id = tickets.assigned_to_id").
+		Select("tickets.assigned_to_id AS agent_id, COUNT(*) AS count").
+		Group("tickets.assigned_to_id").
+		Scan(&commentCounts).Error
+	if err != nil {
+		return nil, err
+	}
+	comments := make(map[uuid.UUID]int, len(commentCounts))
+	for _, c := range commentCounts {
+		comments[c.AgentID] = c.Count
+	}
+
+	// Survey ratings for the agent each ticket was resolved by
+	var csatRows []struct {
+		AgentID uuid.UUID
+		Average float64
+		Count   int
+	}
+	err = f.answeredSurveys(db).
+		Select("satisfaction_surveys.agent_id, AVG(satisfaction_surveys.rating) AS average, COUNT(*) AS count").
+		Where("satisfaction_surveys.agent_id IS NOT NULL").
+		Group("satisfaction_surveys.agent_id").
+		Scan(&csatRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byAgent := make(map[uuid.UUID][]resolvedTicket)
+	for _, t := range resolved {
+		if t.AssignedToID != nil {
+			byAgent[*t.AssignedToID] = append(byAgent[*t.AssignedToID], t)
+		}
+	}
+
+	// Former agents who still hold resolved tickets are listed too
+	listed := make(map[uuid.UUID]bool, len(users))
+	for _, u := range users {
+		listed[u.ID] = true
+	}
+	var missing []uuid.UUID
+	for id := range byAgent {
+		if !listed[id] {
+			missing = append(missing, id)
+			listed[id] = true
+		}
+	}
+	for _, row := range csatRows {
+		if !listed[row.AgentID] {
+			missing = append(missing, row.AgentID)
+			listed[row.AgentID] = true
+		}
+	}
+	if len(missing) > 0 {
+		var former []models.User
+		if err := db.Unscoped().Where("id IN ?", missing).Order("first_name, last_name").Find(&former).Error; err != nil {
+			return nil, err
+		}
+		users = append(users, former...)
+	}
+
+	report := make([]AgentPerformance, 0, len(users))
+	for _, u := range users {
+		tickets := byAgent[u.ID]
+		row := AgentPerformance{
+			AgentID: u.ID,
+			Name:    u.FirstName + " " + u.LastName,
+			Email:   u.Email,
+			Handled: len(tickets),
+		}
+
+		var durations []float64
+		reopened := 0
+		for _, t := range tickets {
+			durations = append(durations, t.ResolvedAt.Sub(t.CreatedAt).Seconds())
+			if t.ReopenCount > 0 {
+				reopened++
+			}
+			if policy.Met(t.Priority, t.CreatedAt, t.FirstResponseAt, t.ResolvedAt) {
+				row.SLAHit++
+			} else {
+				row.SLAMissed++
+			}
+		}
+
+		row.MedianResolutionSeconds = median(durations)
+		row.ReopenPercent = percent(reopened, row.Handled)
+		row.SLAHitPercent = percent(row.SLAHit, row.Handled)
+		row.SLAMissPercent = percent(row.SLAMissed, row.Handled)
+		if row.Handled > 0 {
+			perTicket := float64(comments[u.ID]) / float64(row.Handled)
+			row.CommentsPerTicket = &perTicket
+		}
+		for _, c := range csatRows {
+			if c.AgentID == u.ID {
+				average := c.Average
+				row.CSATAverage, row.CSATResponses = &average, c.Count
+			}
+		}
+		report = append(report, row)
+	}
+	return report, nil
+}
+
+// AgentTable renders an agent report for export
+func AgentTable(report []AgentPerformance) Table {
+	table := Table{Columns: []string{
+		"Agent", "Email", "Handled", "Median resolution (hours)", "Reopened %",
+		"Comments per ticket", "SLA hit", "SLA missed", "SLA hit %", "SLA miss %",
+		"CSAT average", "CSAT responses",
+	}}
+	for _, row := range report {
+		var medianHours *float64
+		if row.MedianResolutionSeconds != nil {
+			h := *row.MedianResolutionSeconds / 3600
+			medianHours = &h
+		}
+		table.Rows = append(table.Rows, []interface{}{
+			row.Name, row.Email, row.Handled, rounded(medianHours), rounded(row.ReopenPercent),
+			rounded(row.CommentsPerTicket), row.SLAHit, row.SLAMissed, rounded(row.SLAHitPercent), rounded(row.SLAMissPercent),
+			rounded(row.CSATAverage), row.CSATResponses,
+		})
+	}
+	return table
+}
+
+func median(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	m := values[len(values)/2]
+	if len(values)%2 == 0 {
+		m = (values[len(values)/2-1] + m) / 2
+	}
+	return &m
+}
+
+// rounded gives a table cell with two decimals, empty for nil
+func rounded(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return math.Round(*v*100) / 100
+}
@@ -0,0 +1,61 @@
+package reports
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds memory when many distinct reports are requested
+const maxCacheEntries = 1000
+
+// Cache keeps computed reports for a short time. Ticket writes call
+// Invalidate; the TTL bounds staleness from writes this process does not
+// see, such as imports or other instances.
+type Cache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	generation uint64
+	entries    map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// Get returns a cached value and the cache generation to pass to Set
+func (c *Cache) Get(key string) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, c.generation, false
+	}
+	return entry.value, c.generation, true
+}
+
+// Set stores a value computed during generation. Values computed before
+// an invalidation are dropped so they cannot outlive the write.
+func (c *Cache) Set(key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if len(c.entries) >= maxCacheEntries {
+		c.entries = make(map[string]cacheEntry)
+	}
+	c.entries[key] = cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+}
+
+// Invalidate drops every cached value
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[string]cacheEntry)
+}
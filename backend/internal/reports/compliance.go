@@ -0,0 +1,79 @@
+package reports
+
+import (
+	"quickdesk-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SLACompliance is the share of resolved tickets that met their targets.
+// The row with an empty priority totals all priorities.
+type SLACompliance struct {
+	Priority           models.TicketPriority `json:"priority"`
+	FirstResponseHours interface{}           `json:"first_response_hours"`
+	ResolutionHours    interface{}           `json:"resolution_hours"`
+	Resolved           int                   `json:"resolved"`
+	Hit                int                   `json:"hit"`
+	Missed             int                   `json:"missed"`
+	HitPercent         *float64              `json:"hit_percent"`
+	MissPercent        *float64              `json:"miss_percent"`
+}
+
+// SLAReport computes SLA compliance per priority for tickets resolved in
+// the filter's range
+func SLAReport(db *gorm.DB, f Filter, policy SLAPolicy) ([]SLACompliance, error) {
+	resolved, err := loadResolved(db, f)
+	if err != nil {
+		return nil, err
+	}
+
+	report := make([]SLACompliance, len(priorities)+1)
+	index := make(map[models.TicketPriority]int, len(priorities))
+	for i, p := range priorities {
+		index[p] = i
+		report[i] = SLACompliance{
+			Priority:           p,
+			FirstResponseHours: hours(policy.FirstResponse, p),
+			ResolutionHours:    hours(policy.Resolution, p),
+		}
+	}
+	total := &report[len(priorities)]
+
+	for _, t := range resolved {
+		row := &report[index[t.Priority]]
+		met := policy.Met(t.Priority, t.CreatedAt, t.FirstResponseAt, t.ResolvedAt)
+		for _, r := range []*SLACompliance{row, total} {
+			r.Resolved++
+			if met {
+				r.Hit++
+			} else {
+				r.Missed++
+			}
+		}
+	}
+
+	for i := range report {
+		report[i].HitPercent = percent(report[i].Hit, report[i].Resolved)
+		report[i].MissPercent = percent(report[i].Missed, report[i].Resolved)
+	}
+	return report, nil
+}
+
+// SLATable renders an SLA report for export
+func SLATable(report []SLACompliance) Table {
+	table := Table{Columns: []string{
+		"Priority", "First response target (hours)", "Resolution target (hours)",
+		"Resolved", "SLA hit", "SLA missed", "SLA hit %", "SLA miss %",
+	}}
+	for _, row := range report {
+		priority := string(row.Priority)
+		if priority == "" {
+			priority = "all"
+		}
+		table.Rows = append(table.Rows, []interface{}{
+			priority, row.FirstResponseHours, row.ResolutionHours,
+			row.Resolved, row.Hit, row.Missed, rounded(row.HitPercent), rounded(row.MissPercent),
+		})
+	}
+	return table
+}
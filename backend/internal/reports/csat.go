@@ -0,0 +1,63 @@
+package reports
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CSATResponse is one answered satisfaction survey
+type CSATResponse struct {
+	TicketID      uuid.UUID  `json:"ticket_id"`
+	TicketSubject string     `json:"ticket_subject"`
+	AgentID       *uuid.UUID `json:"agent_id"`
+	AgentName     string     `json:"agent_name"`
+	Rating        int        `json:"rating"`
+	Comment       string     `json:"comment"`
+	RespondedAt   time.Time  `json:"responded_at"`
+}
+
+// CSATSummary lists survey answers in the filter's range, newest first
+type CSATSummary struct {
+	Average   *float64       `json:"average"`
+	Responses []CSATResponse `json:"responses"`
+}
+
+// CSATReport collects the satisfaction surveys answered in the filter's
+// range
+func CSATReport(db *gorm.DB, f Filter) (*CSATSummary, error) {
+	responses := []CSATResponse{}
+	err := f.answeredSurveys(db).
+		Joins("LEFT JOIN users ON users.id = satisfaction_surveys.agent_id").
+		Select("satisfaction_surveys.ticket_id, tickets.subject AS ticket_subject, satisfaction_surveys.agent_id, " +
+			"COALESCE(users.first_name || ' ' || users.last_name, '') AS agent_name, " +
+			"satisfaction_surveys.rating, satisfaction_surveys.comment, satisfaction_surveys.responded_at").
+		Order("satisfaction_surveys.responded_at DESC").
+		Scan(&responses).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &CSATSummary{Responses: responses}
+	if len(responses) > 0 {
+		total := 0
+		for _, r := range responses {
+			total += r.Rating
+		}
+		average := float64(total) / float64(len(responses))
+		summary.Average = &average
+	}
+	return summary, nil
+}
+
+// CSATTable renders survey answers for export
+func CSATTable(summary *CSATSummary) Table {
+	table := Table{Columns: []string{"Ticket", "Subject", "Agent", "Rating", "Comment", "Responded"}}
+	for _, r := range summary.Responses {
+		table.Rows = append(table.Rows, []interface{}{
+			r.TicketID.String(), r.TicketSubject, r.AgentName, r.Rating, r.Comment, r.RespondedAt,
+		})
+	}
+	return table
+}
@@ -0,0 +1,236 @@
+// Package reports computes ticket statistics for the dashboard, the admin
+// reports and scheduled report emails.
+package reports
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+
+	"gorm.io/gorm"
+)
+
+// DefaultDays is the length of the dashboard's per-day series
+const DefaultDays = 30
+
+// MaxDays bounds the per-day series
+const MaxDays = 365
+
+// DayCount is the number of tickets for one calendar day (UTC)
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// AgeBucket counts unresolved tickets by how long they have been open
+type AgeBucket struct {
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// AssignedCounts are the viewer's own assigned tickets by status
+type AssignedCounts struct {
+	Total    int64                         `json:"total"`
+	ByStatus map[models.TicketStatus]int64 `json:"by_status"`
+}
+
+// Dashboard holds the statistics shown on the dashboard. Averages are in
+// seconds and null when there was nothing to average.
+type Dashboard struct {
+	From                    string                          `json:"from"`
+	To                      string                          `json:"to"`
+	Total                   int64                           `json:"total"`
+	ByStatus                map[models.TicketStatus]int64   `json:"by_status"`
+	ByPriority              map[models.TicketPriority]int64 `json:"by_priority"`
+	CreatedPerDay           []DayCount                      `json:"created_per_day"`
+	ResolvedPerDay          []DayCount                      `json:"resolved_per_day"`
+	AvgFirstResponseSeconds *float64                        `json:"avg_first_response_seconds"`
+	AvgResolutionSeconds    *float64                        `json:"avg_resolution_seconds"`
+	BacklogAge              []AgeBucket                     `json:"backlog_age"`
+	MyAssigned              *AssignedCounts                 `json:"my_assigned,omitempty"` // Agents and admins only
+}
+
+var statuses = []models.TicketStatus{models.StatusOpen, models.StatusInProgress, models.StatusResolved, models.StatusClosed}
+var priorities = []models.TicketPriority{models.PriorityLow, models.PriorityMedium, models.PriorityHigh, models.PriorityUrgent}
+
+// backlogBuckets are upper bounds on the age of unresolved tickets; the
+// last bucket is open-ended
+var backlogBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"<1d", 24 * time.Hour},
+	{"1-3d", 3 * 24 * time.Hour},
+	{"3-7d", 7 * 24 * time.Hour},
+	{"7-30d", 30 * 24 * time.Hour},
+	{">30d", 0},
+}
+
+// BuildDashboard computes the dashboard for the tickets visible to viewer.
+// Per-day series and averages cover the last `days` days up to now;
+// counts and backlog cover all visible tickets.
+func BuildDashboard(db *gorm.DB, viewer query.Viewer, days int, now time.Time) (*Dashboard, error) {
+	if days <= 0 {
+		days = DefaultDays
+	}
+	if days > MaxDays {
+		days = MaxDays
+	}
+	now = now.UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+
+	tickets := func() *gorm.DB {
+		return query.Visible(db.Model(&models.Ticket{}), viewer)
+	}
+
+	d := &Dashboard{
+		From:       from.Format("2006-01-02"),
+		To:         now.Format("2006-01-02"),
+		ByStatus:   make(map[models.TicketStatus]int64, len(statuses)),
+		ByPriority: make(map[models.TicketPriority]int64, len(priorities)),
+	}
+
+	byStatus, err := countBy(tickets(), "tickets.status")
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		d.ByStatus[s] = byStatus[string(s)]
+		d.Total += byStatus[string(s)]
+	}
+
+	byPriority, err := countBy(tickets(), "tickets.priority")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range priorities {
+		d.ByPriority[p] = byPriority[string(p)]
+	}
+
+	if d.CreatedPerDay, err = perDay(tickets(), "tickets.created_at", from, days); err != nil {
+		return nil, err
+	}
+	if d.ResolvedPerDay, err = perDay(tickets(), "tickets.resolved_at", from, days); err != nil {
+		return nil, err
+	}
+
+	if d.AvgFirstResponseSeconds, err = avgSeconds(tickets(), "tickets.first_response_at", from); err != nil {
+		return nil, err
+	}
+	if d.AvgResolutionSeconds, err = avgSeconds(tickets(), "tickets.resolved_at", from); err != nil {
+		return nil, err
+	}
+
+	if d.BacklogAge, err = backlogAge(tickets(), now); err != nil {
+		return nil, err
+	}
+
+	if viewer.Role == models.RoleAgent || viewer.Role == models.RoleAdmin {
+		assigned, err := countBy(tickets().Where("tickets.assigned_to_id = ?", viewer.UserID), "tickets.status")
+		if err != nil {
+			return nil, err
+		}
+		d.MyAssigned = &AssignedCounts{ByStatus: make(map[models.TicketStatus]int64, len(statuses))}
+		for _, s := range statuses {
+			d.MyAssigned.ByStatus[s] = assigned[string(s)]
+			d.MyAssigned.Total += assigned[string(s)]
+		}
+	}
+
+	return d, nil
+}
+
+// countBy counts tickets grouped by a column
+func countBy(db *gorm.DB, column string) (map[string]int64, error) {
+	var rows []struct {
+		Value string
+		Count int64
+	}
+	err := db.Select(column + " AS value, COUNT(*) AS count").Group(column).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Value] = row.Count
+	}
+	return counts, nil
+}
+
+// perDay counts tickets by the UTC day of a timestamp column, with a zero
+// entry for every day in the range
+func perDay(db *gorm.DB, column string, from time.Time, days int) ([]DayCount, error) {
+	var rows []DayCount
+	err := db.Select("to_char("+column+" AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date, COUNT(*) AS count").
+		Where(column+" >= ?", from).
+		Group("date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Date] = row.Count
+	}
+	series := make([]DayCount, days)
+	for i := range series {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+		series[i] = DayCount{Date: date, Count: counts[date]}
+	}
+	return series, nil
+}
+
+// avgSeconds averages the time from creation to a timestamp column for
+// tickets where that timestamp falls on or after from
+func avgSeconds(db *gorm.DB, column string, from time.Time) (*float64, error) {
+	var avg sql.NullFloat64
+	err := db.Select("AVG(EXTRACT(EPOCH FROM ("+column+" - tickets.created_at)))").
+		Where(column+" >= ?", from).
+		Scan(&avg).Error
+	if err != nil || !avg.Valid {
+		return nil, err
+	}
+	return &avg.Float64, nil
+}
+
+// backlogAge buckets open and in-progress tickets by age
+func backlogAge(db *gorm.DB, now time.Time) ([]AgeBucket, error) {
+	var ages []struct {
+		Bucket int
+		Count  int64
+	}
+
+	// Build a CASE that maps each ticket's age to its bucket index
+	expr := "CASE"
+	var args []interface{}
+	for i, b := range backlogBuckets {
+		if b.max == 0 {
+			expr += " ELSE " + strconv.Itoa(i)
+			break
+		}
+		expr += " WHEN tickets.created_at > ? THEN " + strconv.Itoa(i)
+		args = append(args, now.Add(-b.max))
+	}
+	expr += " END"
+
+	err := db.Select(expr+" AS bucket, COUNT(*) AS count", args...).
+		Where("tickets.status IN ?", []models.TicketStatus{models.StatusOpen, models.StatusInProgress}).
+		Group("bucket").
+		Scan(&ages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]AgeBucket, len(backlogBuckets))
+	for i, b := range backlogBuckets {
+		buckets[i].Label = b.label
+	}
+	for _, age := range ages {
+		buckets[age.Bucket].Count = age.Count
+	}
+	return buckets, nil
+}
@@ -0,0 +1,113 @@
+package reports
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Filter narrows the admin reports to tickets resolved in a date range,
+// optionally within one category or handled by one team's members
+type Filter struct {
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	TeamID     *uuid.UUID
+	CategoryID *uuid.UUID
+}
+
+// ParseFilter reads `from` and `to` (YYYY-MM-DD, both inclusive, UTC),
+// `team_id` and `category_id`. Instead of `from`, `days` selects that many
+// days up to `to`. The range defaults to the last DefaultDays days
+// including today.
+func ParseFilter(params url.Values, now time.Time) (Filter, error) {
+	var f Filter
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	f.To = today.AddDate(0, 0, 1)
+	days := DefaultDays
+
+	if v := params.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return f, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", v)
+		}
+		f.From = t
+	}
+	if v := params.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return f, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", v)
+		}
+		f.To = t.AddDate(0, 0, 1)
+	}
+	if v := params.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > MaxDays {
+			return f, fmt.Errorf("days must be between 1 and %d", MaxDays)
+		}
+		days = n
+	}
+	if f.From.IsZero() {
+		f.From = f.To.AddDate(0, 0, -days)
+	}
+	if !f.From.Before(f.To) {
+		return f, fmt.Errorf("from must not be after to")
+	}
+
+	for _, p := range []struct {
+		name string
+		dest **uuid.UUID
+	}{{"team_id", &f.TeamID}, {"category_id", &f.CategoryID}} {
+		if v := params.Get(p.name); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s %q", p.name, v)
+			}
+			*p.dest = &id
+		}
+	}
+	return f, nil
+}
+
+// resolvedTickets restricts a query over tickets to those resolved in the
+// filter's range
+func (f Filter) resolvedTickets(db *gorm.DB) *gorm.DB {
+	db = db.Where("tickets.resolved_at >= ? AND tickets.resolved_at < ?", f.From, f.To)
+	if f.CategoryID != nil {
+		db = db.Where("tickets.category_id = ?", *f.CategoryID)
+	}
+	if f.TeamID != nil {
+		db = db.Where("tickets.assigned_to_id IN (SELECT user_id FROM team_members WHERE team_id = ?)", *f.TeamID)
+	}
+	return db
+}
+
+// answeredSurveys restricts a query to satisfaction surveys answered in
+// the filter's range, joined to their tickets
+func (f Filter) answeredSurveys(db *gorm.DB) *gorm.DB {
+	db = db.Model(&models.SatisfactionSurvey{}).
+		Joins("JOIN tickets ON tickets.id = satisfaction_surveys.ticket_id AND tickets.deleted_at IS NULL").
+		Where("satisfaction_surveys.responded_at >= ? AND satisfaction_surveys.responded_at < ?", f.From, f.To)
+	if f.CategoryID != nil {
+		db = db.Where("tickets.category_id = ?", *f.CategoryID)
+	}
+	if f.TeamID != nil {
+		db = db.Where("satisfaction_surveys.agent_id IN (SELECT user_id FROM team_members WHERE team_id = ?)", *f.TeamID)
+	}
+	return db
+}
+
+// percent returns part as a percentage of total, nil when total is zero
+func percent(part, total int) *float64 {
+	if total == 0 {
+		return nil
+	}
+	p := float64(part) * 100 / float64(total)
+	return &p
+}
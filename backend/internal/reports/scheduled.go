@@ -0,0 +1,253 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+	"quickdesk-backend/internal/scheduler"
+	"quickdesk-backend/pkg/email"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reports that can be scheduled
+const (
+	ReportAgents = "agents"
+	ReportSLA    = "sla"
+	ReportCSAT   = "csat"
+	ReportTime   = "time"
+	ReportView   = "view"
+)
+
+const (
+	// maxViewRows bounds the tickets a scheduled view sends
+	maxViewRows = 1000
+	// maxInlineRows bounds the HTML table in the email body
+	maxInlineRows = 50
+)
+
+// NextRun returns when a schedule fires next after t
+func NextRun(schedule *models.ReportSchedule, t time.Time) (time.Time, error) {
+	return scheduler.NextRun(schedule.Schedule, schedule.Timezone, t)
+}
+
+// Recipients splits a comma-separated recipient list
+func Recipients(list string) []string {
+	var recipients []string
+	for _, r := range strings.Split(list, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// RunScheduled computes the report a schedule delivers and returns its
+// title and rows. Report ranges are relative to now.
+func RunScheduled(db *gorm.DB, schedule *models.ReportSchedule, policy SLAPolicy, now time.Time) (string, Table, error) {
+	params, err := url.ParseQuery(schedule.Params)
+	if err != nil {
+		return "", Table{}, fmt.Errorf("invalid params: %w", err)
+	}
+
+	switch schedule.Report {
+	case ReportAgents, ReportSLA, ReportCSAT, ReportTime:
+		filter, err := ParseFilter(params, now)
+		if err != nil {
+			return "", Table{}, err
+		}
+		period := fmt.Sprintf("%s to %s", filter.From.Format("2006-01-02"), filter.To.AddDate(0, 0, -1).Format("2006-01-02"))
+		if schedule.Report == ReportAgents {
+			report, err := AgentReport(db, filter, policy)
+			if err != nil {
+				return "", Table{}, err
+			}
+			return "Agent performance, " + period, AgentTable(report), nil
+		}
+		if schedule.Report == ReportTime {
+			groupBy := params.Get("group_by")
+			if groupBy == "" {
+				groupBy = TimeByAgent
+			}
+			report, err := TimeReport(db, filter, groupBy)
+			if err != nil {
+				return "", Table{}, err
+			}
+			return "Time logged by " + groupBy + ", " + period, TimeTable(groupBy, report), nil
+		}
+		if schedule.Report == ReportCSAT {
+			summary, err := CSATReport(db, filter)
+			if err != nil {
+				return "", Table{}, err
+			}
+			return "Customer satisfaction, " + period, CSATTable(summary), nil
+		}
+		report, err := SLAReport(db, filter, policy)
+		if err != nil {
+			return "", Table{}, err
+		}
+		return "SLA compliance, " + period, SLATable(report), nil
+	case ReportView:
+		return runView(db, schedule)
+	}
+	return "", Table{}, fmt.Errorf("unknown report %q", schedule.Report)
+}
+
+// runView runs a saved view as the schedule's owner, so `me` and
+// visibility resolve for them. The view must still be one the owner can
+// open, e.g. it may have been unshared since it was scheduled.
+func runView(db *gorm.DB, schedule *models.ReportSchedule) (string, Table, error) {
+	if schedule.ViewID == nil {
+		return "", Table{}, errors.New("no view selected")
+	}
+	var owner models.User
+	if err := db.First(&owner, "id = ?", schedule.OwnerID).Error; err != nil {
+		return "", Table{}, fmt.Errorf("schedule owner not found")
+	}
+	viewer := query.Viewer{UserID: owner.ID, Role: owner.Role}
+	var view models.SavedView
+	if err := query.VisibleViews(db, viewer).First(&view, "id = ?", *schedule.ViewID).Error; err != nil {
+		return "", Table{}, fmt.Errorf("view not found")
+	}
+
+	q, err := query.Parse(view.Query)
+	if err != nil {
+		return "", Table{}, err
+	}
+	sort, err := query.ParseSort(view.SortBy, view.SortOrder)
+	if err != nil {
+		return "", Table{}, err
+	}
+
+	var tickets []models.Ticket
+	err = sort.Order(q.Apply(query.Visible(db.Model(&models.Ticket{}), viewer), viewer)).
+		Preload("Category").
+		Preload("AssignedTo").
+		Limit(maxViewRows).
+		Find(&tickets).Error
+	if err != nil {
+		return "", Table{}, err
+	}
+
+	table := Table{Columns: []string{"ID", "Subject", "Status", "Priority", "Category", "Assignee", "Created", "Updated"}}
+	for _, t := range tickets {
+		assignee := ""
+		if t.AssignedTo != nil {
+			assignee = t.AssignedTo.FirstName + " " + t.AssignedTo.LastName
+		}
+		table.Rows = append(table.Rows, []interface{}{
+			t.ID.String(), t.Subject, string(t.Status), string(t.Priority), t.Category.Name, assignee, t.CreatedAt, t.UpdatedAt,
+		})
+	}
+	return view.Name, table, nil
+}
+
+// Deliverer emails scheduled reports and logs every run
+type Deliverer struct {
+	db     *gorm.DB
+	email  *email.EmailService
+	policy SLAPolicy
+}
+
+func NewDeliverer(db *gorm.DB, emailService *email.EmailService, policy SLAPolicy) *Deliverer {
+	return &Deliverer{db: db, email: emailService, policy: policy}
+}
+
+// RunDue delivers every active schedule whose next run has passed. Each
+// schedule is claimed by moving its next run forward first, so with
+// several server instances only one of them sends it.
+func (d *Deliverer) RunDue(ctx context.Context, now time.Time) error {
+	var due []models.ReportSchedule
+	if err := d.db.WithContext(ctx).Where("is_active = ? AND next_run_at <= ?", true, now).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for i := range due {
+		schedule := &due[i]
+		next, err := NextRun(schedule, now)
+		if err != nil {
+			// An unparseable schedule can never run; stop it from being retried
+			if err := d.db.Model(schedule).Updates(map[string]interface{}{"is_active": false, "next_run_at": nil}).Error; err != nil {
+				return err
+			}
+			if _, recordErr := d.record(schedule, now, 0, err); recordErr != nil {
+				log.Printf("reports: schedule %s: %v", schedule.ID, recordErr)
+			}
+			continue
+		}
+
+		claim := d.db.Model(&models.ReportSchedule{}).
+			Where("id = ? AND next_run_at = ?", schedule.ID, schedule.NextRunAt).
+			Update("next_run_at", next)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue // Another instance took it
+		}
+
+		if _, err := d.Deliver(schedule, now); err != nil {
+			log.Printf("reports: schedule %s: %v", schedule.ID, err)
+		}
+	}
+	return nil
+}
+
+// Deliver runs a schedule's report, emails it and logs the run. A failed
+// report is a failed run; the error is only for failing to log it.
+func (d *Deliverer) Deliver(schedule *models.ReportSchedule, now time.Time) (*models.ReportRun, error) {
+	title, table, err := RunScheduled(d.db, schedule, d.policy, now)
+	if err == nil {
+		err = d.send(schedule, title, table, now)
+	}
+	return d.record(schedule, now, len(table.Rows), err)
+}
+
+func (d *Deliverer) send(schedule *models.ReportSchedule, title string, table Table, now time.Time) error {
+	recipients := Recipients(schedule.Recipients)
+	if len(recipients) == 0 {
+		return errors.New("no recipients")
+	}
+	body, err := table.HTML(maxInlineRows)
+	if err != nil {
+		return err
+	}
+	csv, err := table.CSV()
+	if err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("%s-%s.csv", schedule.Report, now.Format("20060102"))
+	return d.email.SendReportEmail(recipients, title, body, filename, csv)
+}
+
+// record logs a run and stamps the schedule's last run
+func (d *Deliverer) record(schedule *models.ReportSchedule, started time.Time, rows int, err error) (*models.ReportRun, error) {
+	run := &models.ReportRun{
+		ID:         uuid.New(),
+		ScheduleID: schedule.ID,
+		Status:     models.RunSucceeded,
+		Rows:       rows,
+		Recipients: schedule.Recipients,
+		StartedAt:  started,
+		FinishedAt: time.Now(),
+	}
+	if err != nil {
+		run.Status = models.RunFailed
+		run.Error = err.Error()
+	}
+	if err := d.db.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("saving run: %w", err)
+	}
+	if err := d.db.Model(schedule).UpdateColumn("last_run_at", started).Error; err != nil {
+		return nil, fmt.Errorf("stamping last run: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,80 @@
+package reports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+)
+
+// SLAPolicy holds first-response and resolution targets per priority.
+// Priorities without a target always meet it.
+type SLAPolicy struct {
+	FirstResponse map[models.TicketPriority]time.Duration
+	Resolution    map[models.TicketPriority]time.Duration
+}
+
+// ParseSLAPolicy reads targets written as "urgent=4,high=24,..." in hours
+func ParseSLAPolicy(firstResponse, resolution string) (SLAPolicy, error) {
+	var policy SLAPolicy
+	var err error
+	if policy.FirstResponse, err = parseTargets(firstResponse); err != nil {
+		return policy, fmt.Errorf("first response SLA: %w", err)
+	}
+	if policy.Resolution, err = parseTargets(resolution); err != nil {
+		return policy, fmt.Errorf("resolution SLA: %w", err)
+	}
+	return policy, nil
+}
+
+func parseTargets(spec string) (map[models.TicketPriority]time.Duration, error) {
+	targets := make(map[models.TicketPriority]time.Duration)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, hours, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected priority=hours, got %q", part)
+		}
+		priority := models.TicketPriority(strings.TrimSpace(name))
+		if !priority.IsValid() {
+			return nil, fmt.Errorf("unknown priority %q", name)
+		}
+		h, err := strconv.ParseFloat(strings.TrimSpace(hours), 64)
+		if err != nil || h <= 0 {
+			return nil, fmt.Errorf("invalid hours %q for %s", hours, priority)
+		}
+		targets[priority] = time.Duration(h * float64(time.Hour))
+	}
+	return targets, nil
+}
+
+// Met reports whether a resolved ticket was answered and resolved within
+// its targets. Tickets resolved without a public reply count the
+// resolution as their first response.
+func (p SLAPolicy) Met(priority models.TicketPriority, created time.Time, firstResponse *time.Time, resolved time.Time) bool {
+	response := resolved
+	if firstResponse != nil && firstResponse.Before(resolved) {
+		response = *firstResponse
+	}
+	if target, ok := p.FirstResponse[priority]; ok && response.Sub(created) > target {
+		return false
+	}
+	if target, ok := p.Resolution[priority]; ok && resolved.Sub(created) > target {
+		return false
+	}
+	return true
+}
+
+// hours renders a target for reports, empty when there is none
+func hours(targets map[models.TicketPriority]time.Duration, priority models.TicketPriority) interface{} {
+	target, ok := targets[priority]
+	if !ok {
+		return nil
+	}
+	return target.Hours()
+}
@@ -0,0 +1,58 @@
+package reports
+
+import (
+	"bytes"
+	"html/template"
+
+	"quickdesk-backend/pkg/export"
+)
+
+// Table is a report rendered as rows for CSV export and emails
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// CSV renders the table as a CSV file
+func (t Table) CSV() ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := export.NewWriter(export.FormatCSV, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.WriteHeader(t.Columns); err != nil {
+		return nil, err
+	}
+	for _, row := range t.Rows {
+		if err := writer.WriteRow(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var tableTemplate = template.Must(template.New("table").Funcs(template.FuncMap{
+	"cell": export.FormatValue,
+}).Parse(`<table cellpadding="6" cellspacing="0" border="1" style="border-collapse: collapse; font-family: sans-serif; font-size: 13px;">
+<thead><tr>{{range .Columns}}<th style="background: #f1f3f5; text-align: left;">{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{cell .}}</td>{{end}}</tr>
+{{else}}<tr><td colspan="{{len .Columns}}">No rows</td></tr>
+{{end}}</tbody>
+</table>`))
+
+// HTML renders the table for an email body, with at most limit rows; the
+// CSV attachment carries the rest
+func (t Table) HTML(limit int) (string, error) {
+	if limit > 0 && len(t.Rows) > limit {
+		t.Rows = t.Rows[:limit]
+	}
+	var buf bytes.Buffer
+	if err := tableTemplate.Execute(&buf, t); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
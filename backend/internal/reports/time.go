@@ -0,0 +1,94 @@
+package reports
+
+import (
+	"fmt"
+	"math"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Groupings for the time report
+const (
+	TimeByTicket   = "ticket"
+	TimeByAgent    = "agent"
+	TimeByCategory = "category"
+)
+
+// timeGroups maps a grouping to its key and label columns and the join
+// that provides the label
+var timeGroups = map[string]struct {
+	key, label, join string
+}{
+	TimeByTicket:   {"tickets.id", "tickets.subject", ""},
+	TimeByAgent:    {"time_entries.user_id", "users.first_name || ' ' || users.last_name", "JOIN users ON users.id = time_entries.user_id"},
+	TimeByCategory: {"tickets.category_id", "categories.name", "JOIN categories ON categories.id = tickets.category_id"},
+}
+
+// ValidTimeGroup reports whether the time report can be grouped by g
+func ValidTimeGroup(g string) bool {
+	_, ok := timeGroups[g]
+	return ok
+}
+
+// TimeTotal sums the time logged against one ticket, agent or category
+type TimeTotal struct {
+	ID              uuid.UUID `json:"id"`
+	Name            string    `json:"name"`
+	Entries         int       `json:"entries"`
+	TotalSeconds    int       `json:"total_seconds"`
+	BillableSeconds int       `json:"billable_seconds"`
+}
+
+// TimeReport totals the finished time entries started in the filter's
+// range, grouped by ticket, agent or category and largest first. The team
+// filter selects entries logged by the team's members.
+func TimeReport(db *gorm.DB, f Filter, groupBy string) ([]TimeTotal, error) {
+	group, ok := timeGroups[groupBy]
+	if !ok {
+		return nil, fmt.Errorf("group_by must be ticket, agent or category")
+	}
+
+	q := db.Model(&models.TimeEntry{}).
+		Joins("JOIN tickets ON tickets.id = time_entries.ticket_id AND tickets.deleted_at IS NULL").
+		Where("time_entries.ended_at IS NOT NULL").
+		Where("time_entries.started_at >= ? AND time_entries.started_at < ?", f.From, f.To)
+	if group.join != "" {
+		q = q.Joins(group.join)
+	}
+	if f.CategoryID != nil {
+		q = q.Where("tickets.category_id = ?", *f.CategoryID)
+	}
+	if f.TeamID != nil {
+		q = q.Where("time_entries.user_id IN (SELECT user_id FROM team_members WHERE team_id = ?)", *f.TeamID)
+	}
+
+	var totals []TimeTotal
+	err := q.Select(fmt.Sprintf(
+		"%s AS id, %s AS name, COUNT(*) AS entries, SUM(time_entries.duration_seconds) AS total_seconds, "+
+			"SUM(CASE WHEN time_entries.billable THEN time_entries.duration_seconds ELSE 0 END) AS billable_seconds",
+		group.key, group.label)).
+		Group(group.key + ", " + group.label).
+		Order("total_seconds DESC").
+		Scan(&totals).Error
+	return totals, err
+}
+
+// TimeTable renders a time report for export
+func TimeTable(groupBy string, report []TimeTotal) Table {
+	name := map[string]string{TimeByTicket: "Ticket", TimeByAgent: "Agent", TimeByCategory: "Category"}[groupBy]
+	table := Table{Columns: []string{name, "Entries", "Total hours", "Billable hours"}}
+	for _, row := range report {
+		table.Rows = append(table.Rows, []interface{}{
+			row.Name, row.Entries, hoursOf(row.TotalSeconds), hoursOf(row.BillableSeconds),
+		})
+	}
+	return table
+}
+
+// hoursOf converts seconds to hours with two decimals
+func hoursOf(seconds int) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
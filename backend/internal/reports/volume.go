@@ -0,0 +1,263 @@
+package reports
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"quickdesk-backend/internal/models"
+	"quickdesk-backend/internal/query"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Volume intervals. Weeks start on Monday.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxBuckets bounds the length of a volume series
+const maxBuckets = 2000
+
+type volumeGroup struct {
+	value  string // grouped expression
+	label  string // display expression, empty when value is readable
+	joins  string
+	values []string // values always present, in display order
+}
+
+var volumeGroups = map[string]volumeGroup{
+	"status": {
+		value:  "tickets.status",
+		values: []string{string(models.StatusOpen), string(models.StatusInProgress), string(models.StatusResolved), string(models.StatusClosed)},
+	},
+	"priority": {
+		value:  "tickets.priority",
+		values: []string{string(models.PriorityLow), string(models.PriorityMedium), string(models.PriorityHigh), string(models.PriorityUrgent)},
+	},
+	"category": {
+		value: "tickets.category_id::text",
+		label: "categories.name",
+		joins: "JOIN categories ON categories.id = tickets.category_id",
+	},
+}
+
+// VolumeOptions select the buckets of a volume series
+type VolumeOptions struct {
+	Interval string
+	GroupBy  string // empty for a single series
+	Location *time.Location
+	From     time.Time // start of the first bucket, in Location
+	To       time.Time // exclusive
+}
+
+// ParseVolumeOptions reads `interval`, `group_by`, `tz` (an IANA zone,
+// default UTC) and `from`/`to` (YYYY-MM-DD in that zone, both inclusive).
+// The range defaults to two days of hours, 30 days or 12 weeks.
+func ParseVolumeOptions(params url.Values, now time.Time) (VolumeOptions, error) {
+	opts := VolumeOptions{Interval: params.Get("interval"), GroupBy: params.Get("group_by"), Location: time.UTC}
+	if opts.Interval == "" {
+		opts.Interval = IntervalDay
+	}
+	if opts.Interval != IntervalHour && opts.Interval != IntervalDay && opts.Interval != IntervalWeek {
+		return opts, fmt.Errorf("interval must be hour, day or week")
+	}
+	if _, ok := volumeGroups[opts.GroupBy]; opts.GroupBy != "" && !ok {
+		return opts, fmt.Errorf("group_by must be category, priority or status")
+	}
+
+	if tz := params.Get("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, fmt.Errorf("unknown time zone %q", tz)
+		}
+		opts.Location = loc
+	}
+
+	now = now.In(opts.Location)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, opts.Location)
+	opts.To = today.AddDate(0, 0, 1)
+	switch opts.Interval {
+	case IntervalHour:
+		opts.From = today.AddDate(0, 0, -1)
+	case IntervalDay:
+		opts.From = today.AddDate(0, 0, -(DefaultDays - 1))
+	case IntervalWeek:
+		opts.From = today.AddDate(0, 0, -7*11)
+	}
+
+	if v := params.Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, opts.Location)
+		if err != nil {
+			return opts, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", v)
+		}
+		opts.From = t
+	}
+	if v := params.Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, opts.Location)
+		if err != nil {
+			return opts, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", v)
+		}
+		opts.To = t.AddDate(0, 0, 1)
+	}
+	if !opts.From.Before(opts.To) {
+		return opts, fmt.Errorf("from must not be after to")
+	}
+
+	opts.From = opts.truncate(opts.From)
+	if n := len(opts.buckets()); n > maxBuckets {
+		return opts, fmt.Errorf("range has %d %ss, the limit is %d", n, opts.Interval, maxBuckets)
+	}
+	return opts, nil
+}
+
+// truncate returns the start of the bucket t falls in
+func (o VolumeOptions) truncate(t time.Time) time.Time {
+	t = t.In(o.Location)
+	switch o.Interval {
+	case IntervalHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, o.Location)
+	case IntervalWeek:
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, o.Location)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, o.Location)
+}
+
+// buckets lists the local start time of every bucket in the range. The
+// repeated hour when clocks go back is one bucket, as in Postgres.
+func (o VolumeOptions) buckets() []string {
+	var labels []string
+	seen := make(map[string]bool)
+	for t := o.From; t.Before(o.To); {
+		label := t.Format(bucketLayout)
+		if !seen[label] {
+			seen[label] = true
+			labels = append(labels, label)
+		}
+		switch o.Interval {
+		case IntervalHour:
+			t = t.Add(time.Hour)
+		case IntervalDay:
+			t = t.AddDate(0, 0, 1)
+		default:
+			t = t.AddDate(0, 0, 7)
+		}
+	}
+	return labels
+}
+
+// bucketLayout matches the Postgres format below: local wall time without
+// an offset
+const bucketLayout = "2006-01-02T15:04:05"
+
+// VolumeSeries is one line of a volume chart. Created and Resolved line
+// up with the Buckets of the enclosing Volume.
+type VolumeSeries struct {
+	Key      string  `json:"key"`
+	Label    string  `json:"label"`
+	Created  []int64 `json:"created"`
+	Resolved []int64 `json:"resolved"`
+}
+
+// Volume counts tickets created and resolved per bucket
+type Volume struct {
+	Interval string         `json:"interval"`
+	GroupBy  string         `json:"group_by,omitempty"`
+	Timezone string         `json:"timezone"`
+	Buckets  []string       `json:"buckets"`
+	Series   []VolumeSeries `json:"series"`
+}
+
+// BuildVolume computes ticket volume for the tickets visible to viewer.
+// Tickets are bucketed by the local time of their creation and of their
+// resolution.
+func BuildVolume(db *gorm.DB, viewer query.Viewer, opts VolumeOptions) (*Volume, error) {
+	group, grouped := volumeGroups[opts.GroupBy]
+	buckets := opts.buckets()
+	index := make(map[string]int, len(buckets))
+	for i, b := range buckets {
+		index[b] = i
+	}
+
+	v := &Volume{
+		Interval: opts.Interval,
+		GroupBy:  opts.GroupBy,
+		Timezone: opts.Location.String(),
+		Buckets:  buckets,
+		Series:   []VolumeSeries{},
+	}
+	series := make(map[string]int)
+	seriesFor := func(key, label string) *VolumeSeries {
+		if i, ok := series[key]; ok {
+			return &v.Series[i]
+		}
+		if label == "" {
+			label = key
+		}
+		series[key] = len(v.Series)
+		v.Series = append(v.Series, VolumeSeries{
+			Key:      key,
+			Label:    label,
+			Created:  make([]int64, len(buckets)),
+			Resolved: make([]int64, len(buckets)),
+		})
+		return &v.Series[len(v.Series)-1]
+	}
+
+	// Fixed values come first so every chart has the same lines
+	if !grouped {
+		seriesFor("all", "All tickets")
+	}
+	for _, value := range group.values {
+		seriesFor(value, "")
+	}
+
+	for _, metric := range []string{"created_at", "resolved_at"} {
+		column := "tickets." + metric
+		key, label, groupBy := "'all'", "''", "1"
+		if grouped {
+			key, groupBy = group.value, "1, 2"
+			if group.label != "" {
+				label, groupBy = group.label, "1, 2, 3"
+			}
+		}
+
+		var rows []struct {
+			Bucket string
+			Key    string
+			Label  string
+			Count  int64
+		}
+		q := query.Visible(db.Model(&models.Ticket{}), viewer)
+		if grouped && group.joins != "" {
+			q = q.Joins(group.joins)
+		}
+		err := q.Select("to_char(date_trunc(?, "+column+" AT TIME ZONE ?), 'YYYY-MM-DD\"T\"HH24:MI:SS') AS bucket, "+
+			key+" AS key, "+label+" AS label, COUNT(*) AS count", opts.Interval, opts.Location.String()).
+			Where(column+" >= ? AND "+column+" < ?", opts.From, opts.To).
+			Clauses(clause.GroupBy{Columns: []clause.Column{{Name: groupBy, Raw: true}}}).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// Only non-empty buckets come back; spread them over the series
+		for _, row := range rows {
+			i, ok := index[row.Bucket]
+			if !ok {
+				continue
+			}
+			s := seriesFor(row.Key, row.Label)
+			if metric == "created_at" {
+				s.Created[i] += row.Count
+			} else {
+				s.Resolved[i] += row.Count
+			}
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 3, 4, 10, 7, 30, 0, time.UTC) // A Wednesday
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 3, 4, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 3, 4, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{"30 8-18/2 * * *", time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * sat,sun", time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 3, 4, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match
+		{"0 0 13 * fri", time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := cron.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.expr, from, got, tt.want)
+		}
+	}
+}
+
+func TestCronNextNeverFires(t *testing.T) {
+	cron, err := ParseCron("0 0 31 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cron.Next(time.Now()); !got.IsZero() {
+		t.Errorf("Next = %s, want the zero time", got)
+	}
+	if _, err := NextRun("0 0 31 feb *", "UTC", time.Now()); err == nil {
+		t.Error("NextRun succeeded for a schedule that never fires")
+	}
+}
+
+func TestNextRunUsesTimeZone(t *testing.T) {
+	from := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	got, err := NextRun("0 9 * * *", "Europe/Berlin", from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 09:00 in Berlin is 08:00 UTC in winter
+	if want := time.Date(2026, 1, 16, 8, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("NextRun = %s, want %s", got.UTC(), want)
+	}
+
+	if _, err := NextRun("0 9 * * *", "Mars/Olympus", from); err == nil {
+		t.Error("NextRun succeeded with an unknown time zone")
+	}
+}
+
+func TestCronNextSkipsMissingDSTTime(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data unavailable:", err)
+	}
+	cron, err := ParseCron("30 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 02:30 does not exist on 29 March 2026 in Berlin
+	from := time.Date(2026, 3, 28, 12, 0, 0, 0, loc)
+	if got, want := cron.Next(from), time.Date(2026, 3, 30, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Package scheduler runs periodic background jobs inside the server
+// process, so deployments need no external cron.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job does one round of work. Jobs that must run once across several
+// server instances claim their rows in the database before acting.
+type Job func(ctx context.Context, now time.Time) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+// Scheduler runs each job on its own ticker
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []entry
+	wg      sync.WaitGroup
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job to run at a fixed interval. Jobs registered after
+// Start are not run.
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry{name: name, interval: interval, job: job})
+}
+
+// Start runs every job once straight away and then on its interval until
+// ctx is cancelled. A run that overlaps the next tick delays it instead of
+// running twice.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	entries := append([]entry(nil), s.entries...)
+	s.mu.Unlock()
+
+	for _, e := range entries {
+		s.wg.Add(1)
+		go func(e entry) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(e.interval)
+			defer ticker.Stop()
+			for {
+				s.run(ctx, e)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(e)
+	}
+}
+
+// Wait blocks until every job has returned after ctx was cancelled
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(ctx context.Context, e entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler: %s panicked: %v", e.name, r)
+		}
+	}()
+	if err := e.job(ctx, time.Now()); err != nil {
+		log.Printf("scheduler: %s: %v", e.name, err)
+	}
+}
@@ -0,0 +1,143 @@
+package tickets
+
+import (
+	"errors"
+	"quickdesk-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCategory    = errors.New("invalid category")
+	ErrInvalidAssignee    = errors.New("invalid assignee")
+	ErrInvalidTemplate    = errors.New("invalid template")
+	ErrTemplateNotFilled  = errors.New("fill in the template's description before submitting")
+	ErrTemplateMismatched = errors.New("template belongs to a different category")
+)
+
+// NewTicket describes a ticket to create
+type NewTicket struct {
+	Subject      string
+	Description  string
+	Priority     models.TicketPriority
+	CategoryID   uuid.UUID
+	CreatedByID  uuid.UUID
+	AssignedToID *uuid.UUID // Must be an agent or admin
+	DueAt        *time.Time
+	Tags         []string
+	CustomFields models.Fields
+	TemplateID   *uuid.UUID
+
+	// Set for tickets created by a recurring template; the pair is unique
+	RecurrenceID *uuid.UUID
+	RecurrenceAt *time.Time
+}
+
+// Create checks the category and assignee, resolves tags and inserts an
+// open ticket. Assigned tickets start in progress, like AssignTicket.
+func Create(db *gorm.DB, n NewTicket) (*models.Ticket, error) {
+	var category models.Category
+	if err := db.First(&category, "id = ?", n.CategoryID).Error; err != nil {
+		return nil, ErrInvalidCategory
+	}
+
+	status := models.StatusOpen
+	if n.AssignedToID != nil {
+		var count int64
+		db.Model(&models.User{}).
+			Where("id = ? AND role IN ?", *n.AssignedToID, []models.Role{models.RoleAgent, models.RoleAdmin}).
+			Count(&count)
+		if count == 0 {
+			return nil, ErrInvalidAssignee
+		}
+		status = models.StatusInProgress
+	}
+
+	tags, err := ResolveTags(db, n.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := models.Ticket{
+		ID:           uuid.New(),
+		Subject:      n.Subject,
+		Description:  n.Description,
+		Priority:     n.Priority,
+		Status:       status,
+		CreatedByID:  n.CreatedByID,
+		AssignedToID: n.AssignedToID,
+		CategoryID:   n.CategoryID,
+		DueAt:        n.DueAt,
+		TemplateID:   n.TemplateID,
+		CustomFields: n.CustomFields,
+		RecurrenceID: n.RecurrenceID,
+		RecurrenceAt: n.RecurrenceAt,
+		Tags:         tags,
+	}
+	if err := db.Create(&ticket).Error; err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// ApplyTemplate fills a new ticket from an active template of its
+// category. Empty fields take the template's skeleton and default
+// priority, and the template's field defaults apply to custom fields the
+// ticket leaves unset. A description still equal to the skeleton is
+// rejected. The ticket's category may be left empty to use the template's.
+func ApplyTemplate(db *gorm.DB, n *NewTicket, templateID uuid.UUID) error {
+	var template models.TicketTemplate
+	if err := db.First(&template, "id = ? AND is_active = ?", templateID, true).Error; err != nil {
+		return ErrInvalidTemplate
+	}
+	if n.CategoryID == uuid.Nil {
+		n.CategoryID = template.CategoryID
+	} else if n.CategoryID != template.CategoryID {
+		return ErrTemplateMismatched
+	}
+
+	if strings.TrimSpace(n.Subject) == "" {
+		n.Subject = template.Subject
+	}
+	if strings.TrimSpace(n.Description) == "" || strings.TrimSpace(n.Description) == strings.TrimSpace(template.Description) {
+		return ErrTemplateNotFilled
+	}
+	if n.Priority == "" {
+		n.Priority = template.Priority
+	}
+
+	fields := make(models.Fields, len(template.FieldDefaults)+len(n.CustomFields))
+	for name, value := range template.FieldDefaults {
+		fields[name] = value
+	}
+	for name, value := range n.CustomFields {
+		fields[name] = value
+	}
+	n.CustomFields = fields
+	n.TemplateID = &template.ID
+	return nil
+}
+
+// AddComment adds a comment by author to a ticket. Only agents and admins
+// can write internal comments; their first public reply marks the
+// ticket's first response.
+func AddComment(db *gorm.DB, ticket *models.Ticket, author *models.User, content string, internal bool) (*models.Comment, error) {
+	comment := models.Comment{
+		ID:         uuid.New(),
+		Content:    content,
+		TicketID:   ticket.ID,
+		UserID:     author.ID,
+		IsInternal: internal && author.Role != models.RoleUser,
+	}
+	if err := db.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+
+	if author.Role != models.RoleUser && !comment.IsInternal && author.ID != ticket.CreatedByID && ticket.FirstResponseAt == nil {
+		db.Model(ticket).UpdateColumn("first_response_at", comment.CreatedAt)
+	}
+	return &comment, nil
+}
@@ -0,0 +1,30 @@
+// Package tickets holds ticket logic shared by the HTTP controllers and
+// background jobs.
+package tickets
+
+import (
+	"quickdesk-backend/internal/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ResolveTags finds or creates the named tags. Names are case-insensitive.
+func ResolveTags(db *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		tag := models.Tag{Name: name}
+		if err := db.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
@@ -0,0 +1,217 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it fails
+	MaxAttempts = 8
+	// DisableAfter is how many deliveries may fail in a row before the
+	// webhook is disabled
+	DisableAfter = 10
+	// retryBase and retryCap bound the backoff: 30 seconds, 1, 2 ... 60 minutes
+	retryBase = 30 * time.Second
+	retryCap  = time.Hour
+	// requestTimeout bounds one attempt, including reading the response
+	requestTimeout = 10 * time.Second
+	// sendLease is how long a worker holds a delivery; one still sending
+	// after that, e.g. because its server died, is tried again
+	sendLease = 2 * time.Minute
+	// pollInterval picks up retries and deliveries queued by other instances
+	pollInterval = 15 * time.Second
+	// maxResponseBody is how much of a response is kept in the history
+	maxResponseBody = 1024
+)
+
+// Start runs workers that post queued deliveries until ctx is cancelled.
+// Several server instances can share one queue.
+func (d *Dispatcher) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			ticker := time.NewTicker(pollInterval)
+			defer ticker.Stop()
+			for {
+				for ctx.Err() == nil && d.deliverNext(time.Now()) {
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-d.wake:
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until every worker has returned after ctx was cancelled
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// deliverNext claims and posts one due delivery, reporting whether there
+// was one. Deliveries of disabled webhooks wait until it is enabled again.
+func (d *Dispatcher) deliverNext(now time.Time) bool {
+	var delivery models.WebhookDelivery
+	err := d.db.Raw(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM webhook_deliveries
+			WHERE status IN ? AND next_attempt_at <= ?
+			AND webhook_id IN (SELECT id FROM webhooks WHERE is_active)
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.WebhookSending, now.Add(sendLease), now,
+		[]models.WebhookDeliveryStatus{models.WebhookPending, models.WebhookSending}, now,
+	).Scan(&delivery).Error
+	if err != nil {
+		log.Printf("webhooks: claiming delivery: %v", err)
+		return false
+	}
+	if delivery.ID == uuid.Nil {
+		return false
+	}
+
+	var hook models.Webhook
+	if err := d.db.First(&hook, "id = ?", delivery.WebhookID).Error; err != nil {
+		log.Printf("webhooks: loading webhook for delivery %s: %v", delivery.ID, err)
+		return true
+	}
+	d.attempt(&hook, &delivery)
+	return true
+}
+
+// attempt posts a claimed delivery and records the outcome on it and its
+// webhook
+func (d *Dispatcher) attempt(hook *models.Webhook, delivery *models.WebhookDelivery) {
+	status, body, err := d.post(hook, delivery)
+	now := time.Now()
+	updates := map[string]interface{}{
+		"response_status": status,
+		"response_body":   body,
+		"updated_at":      now,
+	}
+	retry := false
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case delivery.Event != PingEvent && retryable(status) && delivery.Attempts < MaxAttempts:
+		retry = true
+		updates["status"] = models.WebhookPending
+		updates["next_attempt_at"] = now.Add(retryDelay(delivery.Attempts))
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = models.WebhookFailed
+		updates["last_error"] = err.Error()
+		log.Printf("webhooks: giving up on %s to %s: %v", delivery.ID, hook.URL, err)
+	}
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("webhooks: recording delivery %s: %v", delivery.ID, err)
+	}
+
+	switch {
+	case err == nil:
+		if err := d.db.Model(hook).Where("consecutive_failures <> 0").UpdateColumn("consecutive_failures", 0).Error; err != nil {
+			log.Printf("webhooks: resetting failures of %s: %v", hook.ID, err)
+		}
+	case !retry && delivery.Event != PingEvent:
+		d.recordFailure(hook, err)
+	}
+}
+
+// recordFailure counts a failed delivery against its webhook and disables
+// the webhook once too many failed in a row
+func (d *Dispatcher) recordFailure(hook *models.Webhook, cause error) {
+	var counted models.Webhook
+	if err := d.db.Raw(`
+		UPDATE webhooks SET consecutive_failures = consecutive_failures + 1
+		WHERE id = ?
+		RETURNING id, consecutive_failures, is_active`, hook.ID,
+	).Scan(&counted).Error; err != nil {
+		log.Printf("webhooks: counting failure of %s: %v", hook.ID, err)
+		return
+	}
+	if counted.ConsecutiveFailures < DisableAfter || !counted.IsActive {
+		return
+	}
+
+	reason := fmt.Sprintf("%d deliveries failed in a row; last error: %v", counted.ConsecutiveFailures, cause)
+	if err := d.db.Model(&models.Webhook{}).Where("id = ? AND is_active", hook.ID).Updates(map[string]interface{}{
+		"is_active":       false,
+		"disabled_at":     time.Now(),
+		"disabled_reason": reason,
+	}).Error; err != nil {
+		log.Printf("webhooks: disabling %s: %v", hook.ID, err)
+		return
+	}
+	log.Printf("webhooks: disabled %s (%s): %s", hook.ID, hook.URL, reason)
+}
+
+// post sends a delivery once, returning the response status and the start
+// of its body. Any status but 2xx is an error.
+func (d *Dispatcher) post(hook *models.Webhook, delivery *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "QuickDesk-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	// Drain the rest so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, string(body), fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+// retryable reports whether an attempt that got status is worth repeating:
+// network errors (status 0), timeouts, rate limits and server errors are.
+// Other client errors mean the receiver rejected the payload for good.
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryDelay doubles the wait after every failed attempt, with jitter so
+// a backlog does not retry in lockstep
+func retryDelay(attempts int) time.Duration {
+	delay := retryCap
+	if attempts <= 8 {
+		delay = min(retryBase<<(attempts-1), retryCap)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
@@ -0,0 +1,170 @@
+package webhooks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"quickdesk-backend/internal/dbtest"
+	"quickdesk-backend/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// newTestDispatcher returns a Dispatcher on a mock database that may post
+// to httptest servers
+func newTestDispatcher(t *testing.T) (*Dispatcher, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock := dbtest.New(t)
+	return NewDispatcher(db, true), mock
+}
+
+// receiver answers every request with status and counts them
+func receiver(t *testing.T, status int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get(HeaderSignature) == "" || r.Header.Get(HeaderTimestamp) == "" {
+			t.Error("delivery without signature headers")
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func testDelivery(hook *models.Webhook, attempts int) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: hook.ID,
+		Event:     "ticket.created",
+		Payload:   []byte(`{}`),
+		Status:    models.WebhookSending,
+		Attempts:  attempts,
+	}
+}
+
+func TestAttemptDelivered(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	server, requests := receiver(t, http.StatusNoContent)
+	hook := &models.Webhook{ID: uuid.New(), URL: server.URL, Secret: "0123456789abcdef", IsActive: true, ConsecutiveFailures: 3}
+
+	var delivery dbtest.Args
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET`).WithArgs(delivery.Match(7)...).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "webhooks" SET "consecutive_failures"=\$1 WHERE consecutive_failures <> 0`).
+		WithArgs(0, hook.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d.attempt(hook, testDelivery(hook, 1))
+
+	if *requests != 1 {
+		t.Errorf("%d requests, want 1", *requests)
+	}
+	if !delivery.Contains(string(models.WebhookDelivered)) {
+		t.Errorf("delivery updated with %v, want status delivered", delivery.Values)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAttemptRetries(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	server, _ := receiver(t, http.StatusServiceUnavailable)
+	hook := &models.Webhook{ID: uuid.New(), URL: server.URL, Secret: "0123456789abcdef", IsActive: true}
+
+	var delivery dbtest.Args
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET .*"next_attempt_at"=`).WithArgs(delivery.Match(7)...).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	before := time.Now()
+	d.attempt(hook, testDelivery(hook, 2))
+
+	if !delivery.Contains(string(models.WebhookPending)) || !delivery.Contains(int64(http.StatusServiceUnavailable)) {
+		t.Errorf("delivery updated with %v, want status pending and response 503", delivery.Values)
+	}
+	var next time.Time
+	for _, v := range delivery.Values {
+		if at, ok := v.(time.Time); ok && at.After(next) {
+			next = at
+		}
+	}
+	if wait := next.Sub(before); wait < retryBase*2 || wait > retryBase*2*11/10+time.Second {
+		t.Errorf("next attempt in %s, want a minute after the second attempt", wait)
+	}
+	// A retry does not count against the webhook
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAttemptDisablesWebhook(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	server, _ := receiver(t, http.StatusServiceUnavailable)
+	hook := &models.Webhook{ID: uuid.New(), URL: server.URL, Secret: "0123456789abcdef", IsActive: true}
+
+	var delivery, disabled dbtest.Args
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET`).WithArgs(delivery.Match(6)...).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE webhooks SET consecutive_failures = consecutive_failures \+ 1`).WithArgs(hook.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "consecutive_failures", "is_active"}).AddRow(hook.ID, DisableAfter, true))
+	mock.ExpectExec(`UPDATE "webhooks" SET "disabled_at"=\$1,"disabled_reason"=\$2,"is_active"=\$3`).
+		WithArgs(disabled.Match(5)...).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	d.attempt(hook, testDelivery(hook, MaxAttempts))
+
+	if !delivery.Contains(string(models.WebhookFailed)) {
+		t.Errorf("delivery updated with %v, want status failed", delivery.Values)
+	}
+	if !disabled.Contains(false) {
+		t.Errorf("webhook updated with %v, want is_active false", disabled.Values)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAttemptCountsRejectedDelivery(t *testing.T) {
+	d, mock := newTestDispatcher(t)
+	server, requests := receiver(t, http.StatusGone)
+	hook := &models.Webhook{ID: uuid.New(), URL: server.URL, Secret: "0123456789abcdef", IsActive: true}
+
+	var delivery dbtest.Args
+	mock.ExpectExec(`UPDATE "webhook_deliveries" SET`).WithArgs(delivery.Match(6)...).WillReturnResult(sqlmock.NewResult(0, 1))
+	// Below the threshold the webhook stays active
+	mock.ExpectQuery(`UPDATE webhooks SET consecutive_failures = consecutive_failures \+ 1`).WithArgs(hook.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "consecutive_failures", "is_active"}).AddRow(hook.ID, 1, true))
+
+	// A 4xx other than 408 and 429 fails on the first attempt
+	d.attempt(hook, testDelivery(hook, 1))
+
+	if *requests != 1 || !delivery.Contains(string(models.WebhookFailed)) {
+		t.Errorf("%d requests, delivery updated with %v; want 1 request and status failed", *requests, delivery.Values)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for status, want := range map[int]bool{
+		0: true, 408: true, 429: true, 500: true, 503: true,
+		302: false, 400: false, 401: false, 404: false, 410: false, 422: false,
+	} {
+		if got := retryable(status); got != want {
+			t.Errorf("retryable(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, base := range map[int]time.Duration{
+		1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 7: 32 * time.Minute,
+		8: time.Hour, 20: time.Hour,
+	} {
+		got := retryDelay(attempts)
+		if got < base || got > base+base/10 {
+			t.Errorf("retryDelay(%d) = %s, want %s plus up to 10%% jitter", attempts, got, base)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for webhook URLs that reach the server's
+// own network, such as localhost, cloud metadata or internal services
+var ErrPrivateAddress = errors.New("webhook url points to a private address")
+
+// sharedAddressSpace is carrier-grade NAT, which net.IP does not count as
+// private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// blocked reports whether an address is off limits for webhooks
+func blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || sharedAddressSpace.Contains(addr)
+}
+
+// newClient returns the HTTP client deliveries are posted with. Unless
+// allowPrivate is set, it refuses to connect to private addresses. The
+// check runs on the address actually dialled, after DNS resolution, so a
+// name that resolves to a public address when the webhook is saved and to
+// a private one later is still refused. Redirects are not followed, as
+// they could point anywhere.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: requestTimeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if blocked(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			// No proxy from the environment: it would dial for us, unchecked
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: requestTimeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckURL resolves a webhook URL's host and reports an error when it
+// points to a private address, so admins learn about it when saving the
+// webhook rather than from failed deliveries
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	if d.allowPrivate {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve %s: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if blocked(addr) {
+			return fmt.Errorf("%w: %s", ErrPrivateAddress, addr.Unmap())
+		}
+	}
+	return nil
+}
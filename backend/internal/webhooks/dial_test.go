@@ -0,0 +1,88 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestBlocked(t *testing.T) {
+	for _, addr := range []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "100.64.0.1", "224.0.0.1", "::1", "::", "fe80::1", "fd00::1",
+		"::ffff:127.0.0.1",
+	} {
+		if !blocked(netip.MustParseAddr(addr)) {
+			t.Errorf("blocked(%s) = false, want true", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111"} {
+		if blocked(netip.MustParseAddr(addr)) {
+			t.Errorf("blocked(%s) = true, want false", addr)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	_, err := newClient(false).Post(server.URL, "application/json", nil)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("posting to %s: err = %v, want ErrPrivateAddress", server.URL, err)
+	}
+	if hit {
+		t.Error("the request reached the server")
+	}
+
+	resp, err := newClient(true).Post(server.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("posting with private addresses allowed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/internal", http.StatusFound)
+	})
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := newClient(true).Post(server.URL+"/hook", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || followed {
+		t.Errorf("status %d, redirect followed: %v; want 302 and not followed", resp.StatusCode, followed)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	d := &Dispatcher{}
+	for _, url := range []string{"http://127.0.0.1:9000/", "https://[::1]/hook", "http://169.254.169.254/latest/meta-data"} {
+		if err := d.CheckURL(context.Background(), url); !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("CheckURL(%q) = %v, want ErrPrivateAddress", url, err)
+		}
+	}
+	if err := d.CheckURL(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("CheckURL of a public address: %v", err)
+	}
+
+	d.allowPrivate = true
+	if err := d.CheckURL(context.Background(), "http://127.0.0.1:9000/"); err != nil {
+		t.Errorf("CheckURL with private addresses allowed: %v", err)
+	}
+}
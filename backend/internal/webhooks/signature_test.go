@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	body := []byte(`{"type":"ticket.created"}`)
+	now := time.Unix(1760000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(secret, now.Unix(), body)
+
+	if !strings.HasPrefix(signature, "sha256=") || len(signature) != len("sha256=")+64 {
+		t.Fatalf("Sign = %q, want sha256= and 64 hex digits", signature)
+	}
+	if Sign(secret, now.Unix(), body) != signature {
+		t.Error("Sign is not deterministic")
+	}
+	if !Verify(secret, timestamp, signature, body, now) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if !Verify(secret, timestamp, signature, body, now.Add(SignatureTolerance)) {
+		t.Error("Verify rejected a signature at the edge of the tolerance")
+	}
+
+	tests := []struct {
+		name                         string
+		secret, timestamp, signature string
+		body                         []byte
+		now                          time.Time
+	}{
+		{"wrong secret", "another secret of some length", timestamp, signature, body, now},
+		{"tampered body", secret, timestamp, signature, []byte(`{"type":"ticket.deleted"}`), now},
+		{"different timestamp", secret, strconv.FormatInt(now.Unix()+1, 10), signature, body, now},
+		{"stale", secret, timestamp, signature, body, now.Add(SignatureTolerance + time.Second)},
+		{"from the future", secret, timestamp, signature, body, now.Add(-SignatureTolerance - time.Second)},
+		{"bad timestamp", secret, "yesterday", signature, body, now},
+		{"missing prefix", secret, timestamp, strings.TrimPrefix(signature, "sha256="), body, now},
+		{"empty signature", secret, timestamp, "", body, now},
+	}
+	for _, tt := range tests {
+		if Verify(tt.secret, tt.timestamp, tt.signature, tt.body, tt.now) {
+			t.Errorf("%s: Verify accepted the signature", tt.name)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 || a == b {
+		t.Errorf("NewSecret returned %q and %q, want two different 64 character secrets", a, b)
+	}
+}
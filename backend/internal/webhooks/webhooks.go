@@ -0,0 +1,250 @@
+// Package webhooks posts ticket activity to endpoints registered by
+// admins, such as chat bots and asset databases. Payloads are JSON signed
+// with HMAC-SHA256 and queued as deliveries, which workers post in the
+// background with retries, so a slow receiver never holds up a request.
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventTypes are the events webhooks can subscribe to
+var EventTypes = []string{
+	string(events.TicketCreated),
+	string(events.TicketUpdated),
+	string(events.TicketDeleted),
+	string(events.TicketAssigned),
+	string(events.CommentAdded),
+}
+
+// PingEvent is sent by Test to check an endpoint. Pings are tried once and
+// do not count towards disabling the webhook.
+const PingEvent = "ping"
+
+// ErrNotFound is returned when redelivering a delivery that does not exist
+var ErrNotFound = errors.New("delivery not found")
+
+// ValidEvent reports whether webhooks can subscribe to an event type
+func ValidEvent(event string) bool {
+	for _, e := range EventTypes {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID        uuid.UUID       `json:"id"` // The event's ID; redeliveries repeat it
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     *Actor          `json:"actor,omitempty"`
+	Ticket    *models.Ticket  `json:"ticket,omitempty"`
+	Changes   []string        `json:"changes,omitempty"` // Fields changed by a ticket.updated
+	Comment   *models.Comment `json:"comment,omitempty"`
+	Webhook   *WebhookInfo    `json:"webhook,omitempty"` // ping only
+}
+
+// Actor is who caused an event
+type Actor struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+}
+
+// WebhookInfo names the webhook a ping was sent for
+type WebhookInfo struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// Dispatcher queues and delivers webhook payloads
+type Dispatcher struct {
+	db           *gorm.DB
+	client       *http.Client
+	allowPrivate bool // Deliver to private addresses, e.g. for local testing
+	wake         chan struct{}
+	wg           sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher. Webhooks may only point to private
+// addresses, such as localhost, when allowPrivate is set.
+func NewDispatcher(db *gorm.DB, allowPrivate bool) *Dispatcher {
+	return &Dispatcher{
+		db:           db,
+		client:       newClient(allowPrivate),
+		allowPrivate: allowPrivate,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Handle queues deliveries for an event in the background. It is meant to
+// be subscribed to the ticket event bus.
+func (d *Dispatcher) Handle(e events.Event) {
+	go func() {
+		if err := d.Enqueue(e); err != nil {
+			log.Printf("webhooks: %s for ticket %s: %v", e.Type, e.TicketID, err)
+		}
+	}()
+}
+
+// Enqueue queues a delivery of an event for every active webhook
+// subscribed to it
+func (d *Dispatcher) Enqueue(e events.Event) error {
+	var hooks []models.Webhook
+	if err := d.db.Where("is_active = ?", true).Find(&hooks).Error; err != nil {
+		return err
+	}
+	var subscribed []models.Webhook
+	for _, hook := range hooks {
+		if hook.Subscribes(string(e.Type)) {
+			subscribed = append(subscribed, hook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	payload, err := d.payload(e)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(subscribed))
+	for _, hook := range subscribed {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     hook.ID,
+			EventID:       payload.ID,
+			Event:         payload.Type,
+			TicketID:      &e.TicketID,
+			Payload:       body,
+			Status:        models.WebhookPending,
+			NextAttemptAt: now,
+		})
+	}
+	if err := d.db.Create(&deliveries).Error; err != nil {
+		return err
+	}
+	d.wakeWorker()
+	return nil
+}
+
+// payload describes an event with the ticket as it is now. Deleted tickets
+// are still loaded so receivers learn what was deleted.
+func (d *Dispatcher) payload(e events.Event) (*Payload, error) {
+	p := &Payload{ID: uuid.New(), Type: string(e.Type), CreatedAt: e.Time, Changes: e.Changes}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+
+	p.Ticket = &models.Ticket{}
+	if err := d.db.Unscoped().Preload("CreatedBy").Preload("AssignedTo").Preload("Category").
+		First(p.Ticket, "id = ?", e.TicketID).Error; err != nil {
+		return nil, err
+	}
+	if e.CommentID != nil {
+		p.Comment = &models.Comment{}
+		if err := d.db.Preload("User").First(p.Comment, "id = ?", *e.CommentID).Error; err != nil {
+			return nil, err
+		}
+	}
+	if e.ActorID != uuid.Nil {
+		var actor models.User
+		if err := d.db.Unscoped().Limit(1).Find(&actor, "id = ?", e.ActorID).Error; err != nil {
+			return nil, err
+		}
+		if actor.ID != uuid.Nil {
+			p.Actor = &Actor{ID: actor.ID, Name: actor.FirstName + " " + actor.LastName, Email: actor.Email}
+		}
+	}
+	return p, nil
+}
+
+// Test sends a ping to a webhook straight away and returns the delivery
+// with the receiver's response
+func (d *Dispatcher) Test(hook *models.Webhook) (*models.WebhookDelivery, error) {
+	payload := Payload{
+		ID:        uuid.New(),
+		Type:      PingEvent,
+		CreatedAt: time.Now(),
+		Webhook:   &WebhookInfo{ID: hook.ID, Name: hook.Name},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{
+		ID:            uuid.New(),
+		WebhookID:     hook.ID,
+		EventID:       payload.ID,
+		Event:         PingEvent,
+		Payload:       body,
+		Status:        models.WebhookSending,
+		Attempts:      1,
+		NextAttemptAt: now.Add(sendLease),
+	}
+	if err := d.db.Create(&delivery).Error; err != nil {
+		return nil, err
+	}
+	d.attempt(hook, &delivery)
+	if err := d.db.First(&delivery, "id = ?", delivery.ID).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Redeliver queues a copy of an earlier delivery, e.g. one that failed
+// while the receiver was down. The original stays in the history.
+func (d *Dispatcher) Redeliver(id uuid.UUID) (*models.WebhookDelivery, error) {
+	var original models.WebhookDelivery
+	if err := d.db.First(&original, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	copied := models.WebhookDelivery{
+		ID:                uuid.New(),
+		WebhookID:         original.WebhookID,
+		EventID:           original.EventID,
+		Event:             original.Event,
+		TicketID:          original.TicketID,
+		Payload:           original.Payload,
+		Status:            models.WebhookPending,
+		NextAttemptAt:     time.Now(),
+		RedeliveredFromID: &original.ID,
+	}
+	if err := d.db.Create(&copied).Error; err != nil {
+		return nil, err
+	}
+	d.wakeWorker()
+	return &copied, nil
+}
+
+func (d *Dispatcher) wakeWorker() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
@@ -1,13 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"quickdesk-backend/internal/config"
 	"quickdesk-backend/internal/controllers"
+	"quickdesk-backend/internal/csat"
+	"quickdesk-backend/internal/due"
+	"quickdesk-backend/internal/events"
+	"quickdesk-backend/internal/inbound"
 	"quickdesk-backend/internal/middleware"
+	"quickdesk-backend/internal/notify"
+	"quickdesk-backend/internal/presence"
+	"quickdesk-backend/internal/realtime"
+	"quickdesk-backend/internal/recurring"
+	"quickdesk-backend/internal/reports"
+	"quickdesk-backend/internal/scheduler"
+	"quickdesk-backend/internal/webhooks"
 	"quickdesk-backend/pkg/database"
+	"quickdesk-backend/pkg/email"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
@@ -15,6 +33,9 @@ import (
 	"github.com/joho/godotenv"
 )
 
+// shutdownTimeout is how long requests in flight get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
@@ -49,11 +70,137 @@ func main() {
     MaxAge:           300,
 	}))
 
+	sla, err := reports.ParseSLAPolicy(cfg.SLAFirstResponseHours, cfg.SLAResolutionHours)
+	if err != nil {
+		log.Fatal("Invalid SLA configuration:", err)
+	}
+
+	dueReminderLead, err := time.ParseDuration(cfg.DueReminderLead)
+	if err != nil || dueReminderLead < 0 {
+		log.Fatal("Invalid DUE_REMINDER_LEAD:", cfg.DueReminderLead)
+	}
+
+	slaWarningLead, err := time.ParseDuration(cfg.SLAWarningLead)
+	if err != nil || slaWarningLead <= 0 {
+		log.Fatal("Invalid SLA_WARNING_LEAD:", cfg.SLAWarningLead)
+	}
+
+	imapPollInterval, err := time.ParseDuration(cfg.IMAPPollInterval)
+	if err != nil || imapPollInterval <= 0 {
+		log.Fatal("Invalid IMAP_POLL_INTERVAL:", cfg.IMAPPollInterval)
+	}
+
+	emailWorkers, err := strconv.Atoi(cfg.EmailWorkers)
+	if err != nil || emailWorkers < 1 {
+		log.Fatal("Invalid EMAIL_WORKERS:", cfg.EmailWorkers)
+	}
+	webhookWorkers, err := strconv.Atoi(cfg.WebhookWorkers)
+	if err != nil || webhookWorkers < 1 {
+		log.Fatal("Invalid WEBHOOK_WORKERS:", cfg.WebhookWorkers)
+	}
+
+	notificationRetention, err := time.ParseDuration(cfg.NotificationRetention)
+	if err != nil || notificationRetention <= 0 {
+		log.Fatal("Invalid NOTIFICATION_RETENTION:", cfg.NotificationRetention)
+	}
+
+	// Background workers run until SIGINT or SIGTERM, then finish what they
+	// are doing before the process exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Ticket activity is published here for caches and notifications
+	bus := events.NewBus()
+	// Clients connected to any instance are pushed activity through Postgres
+	hub := realtime.NewHub(db, cfg.DatabaseURL)
+	hub.Start(ctx)
+	bus.Subscribe(hub.Handle)
+	// Emails are queued in the outbox and delivered by its workers
+	emailService, err := email.NewEmailService(db, cfg)
+	if err != nil {
+		log.Fatal("Invalid email configuration:", err)
+	}
+	emailService.Start(ctx, emailWorkers)
+
+	// Background jobs run in-process until the server stops
+	deliverer := reports.NewDeliverer(db, emailService, sla)
+	jobs := scheduler.New()
+	jobs.Every("report delivery", time.Minute, deliverer.RunDue)
+	reminder := due.NewReminder(db, emailService, dueReminderLead)
+	jobs.Every("due date reminders", 5*time.Minute, reminder.RunDue)
+	notifier := notify.NewNotifier(db, emailService, hub)
+	slaWatcher := notify.NewSLAWatcher(notifier, sla, slaWarningLead)
+	jobs.Every("SLA warnings", 5*time.Minute, slaWatcher.RunDue)
+	digester := notify.NewDigester(db, emailService)
+	jobs.Every("notification digests", 5*time.Minute, digester.RunDue)
+	jobs.Every("notification cleanup", time.Hour, notify.NewRetention(db, notificationRetention).RunDue)
+	recurringRunner := recurring.NewRunner(db, bus)
+	jobs.Every("recurring tickets", time.Minute, recurringRunner.RunDue)
+	// Agents with a ticket open see each other; presence expires without heartbeats
+	tracker := presence.NewTracker(db, hub)
+	jobs.Every("presence expiry", presence.TTL/2, tracker.RunDue)
+	inboundProcessor := inbound.NewProcessor(db, bus, inbound.Options{
+		AutoProvision: cfg.InboundAutoProvision,
+		Category:      cfg.InboundCategory,
+		AttachmentDir: cfg.AttachmentDir,
+		Threads:       email.NewThreads(cfg.JWTSecret),
+		AuthServID:    cfg.InboundAuthServID,
+	})
+	if cfg.IMAPHost != "" {
+		poller := inbound.NewPoller(inbound.IMAPConfig{
+			Host:     cfg.IMAPHost,
+			Port:     cfg.IMAPPort,
+			TLS:      cfg.IMAPTLS,
+			User:     cfg.IMAPUser,
+			Password: cfg.IMAPPass,
+			Mailbox:  cfg.IMAPMailbox,
+		}, inboundProcessor)
+		jobs.Every("inbound mailbox", imapPollInterval, poller.Poll)
+	}
+	jobs.Start(ctx)
+
+	// Requesters get a satisfaction survey when their ticket is resolved
+	surveys := csat.NewService(db, emailService, cfg.FrontendURL)
+	bus.Subscribe(surveys.Handle)
+
+	// Requesters, assignees, watchers and mentioned agents are notified in the
+	// app and by email about ticket activity, as their notification
+	// preferences say
+	bus.Subscribe(notifier.Handle)
+
+	// Agents drafting on a ticket are warned when someone else comments
+	bus.Subscribe(tracker.Handle)
+
+	// Registered webhooks get signed payloads, posted by background workers
+	dispatcher := webhooks.NewDispatcher(db, cfg.WebhookAllowPrivate)
+	dispatcher.Start(ctx, webhookWorkers)
+	bus.Subscribe(dispatcher.Handle)
+
 	// Initialize controllers
 	authController := controllers.NewAuthController(db)
 	userController := controllers.NewUserController(db)
-	ticketController := controllers.NewTicketController(db)
+	ticketController := controllers.NewTicketController(db, bus)
 	categoryController := controllers.NewCategoryController(db)
+	teamController := controllers.NewTeamController(db)
+	viewController := controllers.NewViewController(db)
+	importController := controllers.NewImportController(db)
+	statsController := controllers.NewStatsController(db)
+	reportController := controllers.NewReportController(db, sla, deliverer)
+	analyticsController := controllers.NewAnalyticsController(db, bus)
+	csatController := controllers.NewCSATController(db)
+	timeController := controllers.NewTimeController(db)
+	calendarController := controllers.NewCalendarController(db, cfg.FrontendURL)
+	recurringController := controllers.NewRecurringTicketController(db, recurringRunner)
+	templateController := controllers.NewTicketTemplateController(db)
+	inboundController := controllers.NewInboundController(db, inboundProcessor, cfg.InboundToken)
+	emailLogController := controllers.NewEmailLogController(db, emailService)
+	emailTemplateController := controllers.NewEmailTemplateController(db, emailService)
+	notificationPreferenceController := controllers.NewNotificationPreferenceController(db)
+	notificationController := controllers.NewNotificationController(db, hub)
+	streamController := controllers.NewStreamController(db, hub)
+	presenceController := controllers.NewPresenceController(db, tracker)
+	watchController := controllers.NewWatchController(db)
+	webhookController := controllers.NewWebhookController(db, dispatcher)
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
@@ -64,6 +211,21 @@ func main() {
 			r.Post("/logout", authController.Logout)
 		})
 
+		// Satisfaction survey links (public, authorized by the signed token)
+		r.Route("/csat", func(r chi.Router) {
+			r.Get("/{token}", csatController.GetSurvey)
+			r.Post("/{token}", csatController.SubmitSurvey)
+		})
+
+		// Due date calendar feeds (public, authorized by the signed token)
+		r.Get("/calendar/{token}", calendarController.GetFeed) // {token}.ics
+
+		// Raw emails from the mail server (public, authorized by X-Inbound-Token)
+		r.Post("/inbound/email", inboundController.ReceiveEmail)
+
+		// Server-Sent Events (a stream token may also come as ?token= for EventSource)
+		r.With(middleware.StreamAuthMiddleware).Get("/stream", streamController.Stream)
+
 		// Protected routes
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.AuthMiddleware)
@@ -76,6 +238,22 @@ func main() {
 				r.Delete("/{id}", userController.DeleteUser)
 			})
 
+			// Token for opening the event stream with ?token=
+			r.Post("/stream/token", streamController.CreateToken)
+
+			// Notification center of the caller
+			r.Route("/notifications", func(r chi.Router) {
+				r.Get("/", notificationController.GetNotifications)   // Newest first, with unread counts
+				r.Post("/read", notificationController.MarkAllRead)   // Mark all read
+				r.Post("/{id}/read", notificationController.MarkRead) // Mark one read
+			})
+
+			// Notification preferences of the caller
+			r.Route("/notification-preferences", func(r chi.Router) {
+				r.Get("/", notificationPreferenceController.GetPreferences)    // Mode per event
+				r.Put("/", notificationPreferenceController.UpdatePreferences) // Set modes for some events
+			})
+
 			// Ticket routes
 			// ...existing code...
 
@@ -83,6 +261,7 @@ func main() {
 			r.Route("/tickets", func(r chi.Router) {
 				r.Get("/", ticketController.GetTickets)              // List all tickets
 				r.Post("/", ticketController.CreateTicket)           // Create a new ticket
+				r.Get("/export", ticketController.ExportTickets)     // Export tickets as CSV, JSON or XLSX
 				r.Route("/{id}", func(r chi.Router) {
         			r.Get("/", ticketController.GetTicket)           // Get a specific ticket
         			r.Put("/", ticketController.UpdateTicket)        // Update a specific ticket
@@ -90,14 +269,48 @@ func main() {
         			r.Post("/comments", ticketController.AddComment) // Add comment to a ticket
         			r.Post("/vote", ticketController.VoteTicket)     // Vote on a ticket
         			r.Post("/assign", ticketController.AssignTicket) // Assign a ticket
+        			r.Get("/export", ticketController.ExportTicket)  // Export a ticket with its comments
+        			r.Get("/watch", watchController.GetWatch)        // Whether you watch a ticket
+        			r.Post("/watch", watchController.Watch)          // Get notified of a ticket's comments
+        			r.Delete("/watch", watchController.Unwatch)      // Stop watching a ticket
+
+        			// Time tracking (agents and admins)
+        			r.Group(func(r chi.Router) {
+        				r.Use(middleware.AgentOrAdminMiddleware)
+        				r.Get("/time", timeController.GetTicketTime)     // Time entries and totals
+        				r.Post("/time", timeController.LogTime)          // Log finished time
+        				r.Post("/time/start", timeController.StartTimer) // Start a timer
+        				r.Post("/time/stop", timeController.StopTimer)   // Stop the running timer
+        			})
+
+        			// Presence and collision warnings (agents and admins)
+        			r.Group(func(r chi.Router) {
+        				r.Use(middleware.AgentOrAdminMiddleware)
+        				r.Get("/presence", presenceController.GetPresence) // Agents with the ticket open
+        				r.Post("/presence", presenceController.Heartbeat)  // Viewing or typing heartbeat
+        				r.Delete("/presence", presenceController.Leave)    // Close the ticket
+        			})
     			})
 			})
 
 // ...existing code...
 
+			// Time entry routes (agents and admins)
+			r.Route("/time", func(r chi.Router) {
+				r.Use(middleware.AgentOrAdminMiddleware)
+				r.Get("/running", timeController.GetRunningTimer)      // The caller's running timer
+				r.Put("/{entryID}", timeController.UpdateTimeEntry)    // Edit an entry
+				r.Delete("/{entryID}", timeController.DeleteTimeEntry) // Delete an entry
+			})
+
+			// Calendar feed URL (agents and admins)
+			r.With(middleware.AgentOrAdminMiddleware).Get("/calendar/feed", calendarController.GetFeedURL)
+			r.With(middleware.AgentOrAdminMiddleware).Post("/calendar/feed/rotate", calendarController.RotateFeedURL)
+
 			// Category routes (admin only)
 			r.Route("/categories", func(r chi.Router) {
 				r.Get("/", categoryController.GetCategories)
+				r.Get("/{id}/templates", templateController.GetTemplates) // Templates for the create form
 
 				// Admin only routes
 				r.Group(func(r chi.Router) {
@@ -105,8 +318,113 @@ func main() {
 					r.Post("/", categoryController.CreateCategory)
 					r.Put("/{id}", categoryController.UpdateCategory)
 					r.Delete("/{id}", categoryController.DeleteCategory)
+					r.Post("/{id}/templates", templateController.CreateTemplate)
+					r.Put("/{id}/templates/{templateID}", templateController.UpdateTemplate)
+					r.Delete("/{id}/templates/{templateID}", templateController.DeleteTemplate)
+				})
+			})
+
+			// Team routes
+			r.Route("/teams", func(r chi.Router) {
+				r.Get("/", teamController.GetTeams)
+
+				// Admin only routes
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.AdminMiddleware)
+					r.Post("/", teamController.CreateTeam)
+					r.Put("/{id}", teamController.UpdateTeam)
+					r.Delete("/{id}", teamController.DeleteTeam)
+					r.Put("/{id}/members", teamController.SetMembers)
 				})
 			})
+
+			// Recurring ticket routes (admin only)
+			r.Route("/recurring-tickets", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Get("/", recurringController.GetRecurringTickets)                   // List templates
+				r.Post("/", recurringController.CreateRecurringTicket)                // Create a template
+				r.Put("/{id}", recurringController.UpdateRecurringTicket)             // Update a template
+				r.Delete("/{id}", recurringController.DeleteRecurringTicket)          // Delete a template
+				r.Post("/{id}/run", recurringController.RunRecurringTicket)           // Open its ticket now
+				r.Get("/{id}/tickets", recurringController.GetRecurringTicketHistory) // Tickets it opened
+			})
+
+			// Inbound email log (admin only)
+			r.With(middleware.AdminMiddleware).Get("/inbound/emails", inboundController.GetInboundEmails)
+
+			// Outgoing email log (admin only)
+			r.Route("/emails", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Get("/", emailLogController.GetEmails)               // Delivery log
+				r.Get("/{id}", emailLogController.GetEmail)            // An email with its rendered message
+				r.Post("/{id}/resend", emailLogController.ResendEmail) // Queue a copy
+			})
+
+			// Webhook routes (admin only)
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Get("/", webhookController.GetWebhooks)                                              // List webhooks
+				r.Post("/", webhookController.CreateWebhook)                                           // Register a webhook
+				r.Get("/{id}", webhookController.GetWebhook)                                           // Get a webhook
+				r.Put("/{id}", webhookController.UpdateWebhook)                                        // Update or re-enable a webhook
+				r.Delete("/{id}", webhookController.DeleteWebhook)                                     // Delete a webhook and its history
+				r.Post("/{id}/test", webhookController.TestWebhook)                                    // Send a ping now
+				r.Post("/{id}/rotate-secret", webhookController.RotateSecret)                          // Generate a new secret
+				r.Get("/{id}/deliveries", webhookController.GetDeliveries)                             // Delivery history
+				r.Get("/{id}/deliveries/{deliveryID}", webhookController.GetDelivery)                  // A delivery with its payload
+				r.Post("/{id}/deliveries/{deliveryID}/redeliver", webhookController.RedeliverDelivery) // Queue a copy
+			})
+
+			// Email template routes (admin only)
+			r.Route("/email-templates", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Get("/", emailTemplateController.GetTemplates)                     // Built-in templates and overrides
+				r.Get("/{name}", emailTemplateController.GetTemplate)                // The template used for ?locale=
+				r.Put("/{name}/{locale}", emailTemplateController.SaveTemplate)      // Override a template for a locale
+				r.Delete("/{name}/{locale}", emailTemplateController.DeleteTemplate) // Drop an override
+				r.Post("/{name}/preview", emailTemplateController.PreviewTemplate)   // Render against a sample ticket
+			})
+
+			// Import routes (admin only)
+			r.Route("/import", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Post("/{entity}", importController.Import) // Import users, categories, tickets or comments
+			})
+
+			// Saved view routes
+			r.Route("/views", func(r chi.Router) {
+				r.Get("/", viewController.GetViews)                   // List visible views, ?counts=true for live counts
+				r.Post("/", viewController.CreateView)                // Save a view
+				r.Put("/{id}", viewController.UpdateView)             // Update a view
+				r.Delete("/{id}", viewController.DeleteView)          // Delete a view
+				r.Get("/{id}/tickets", viewController.GetViewTickets) // Run a view
+			})
+
+			// Statistics routes
+			r.Route("/stats", func(r chi.Router) {
+				r.Get("/dashboard", statsController.GetDashboard) // Dashboard counts, trends and averages
+			})
+
+			// Analytics routes
+			r.Route("/analytics", func(r chi.Router) {
+				r.Get("/volume", analyticsController.GetVolume) // Tickets created and resolved per hour, day or week
+			})
+
+			// Report routes (admin only)
+			r.Route("/reports", func(r chi.Router) {
+				r.Use(middleware.AdminMiddleware)
+				r.Get("/agents", reportController.GetAgentReport) // Per-agent performance, ?format=csv to export
+				r.Get("/sla", reportController.GetSLAReport)      // SLA compliance per priority
+				r.Get("/csat", reportController.GetCSATReport)    // Satisfaction survey answers
+				r.Get("/time", reportController.GetTimeReport)    // Time logged per ticket, agent or category
+
+				r.Get("/schedules", reportController.GetSchedules)              // List scheduled report emails
+				r.Post("/schedules", reportController.CreateSchedule)           // Schedule a report or saved view
+				r.Put("/schedules/{id}", reportController.UpdateSchedule)       // Update a schedule
+				r.Delete("/schedules/{id}", reportController.DeleteSchedule)    // Delete a schedule
+				r.Post("/schedules/{id}/run", reportController.RunSchedule)     // Send a schedule now
+				r.Get("/schedules/{id}/runs", reportController.GetScheduleRuns) // Delivery log
+			})
 		})
 	})
 
@@ -116,8 +434,26 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		log.Fatal("Failed to start server:", err)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	// Event streams never finish on their own, so end them when shutting down
+	server.RegisterOnShutdown(hub.Close)
+	go func() {
+		log.Printf("Starting server on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // A second signal kills the process
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
 	}
+	jobs.Wait()
+	emailService.Wait()
+	dispatcher.Wait()
 }
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const calendarAudience = "calendar"
+
+// CalendarClaims identify the agent whose calendar feed a URL serves and
+// the version of their feed URLs it was issued for
+type CalendarClaims struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Version int       `json:"version,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// calendarKey is derived from the JWT secret like surveyKey, so feed
+// tokens only open feeds
+func calendarKey() []byte {
+	return []byte(secret() + ":" + calendarAudience)
+}
+
+// GenerateCalendarToken signs a calendar feed token for the user's current
+// feed version. Calendar clients cannot log in or refresh tokens, so it
+// does not expire; raising the version revokes it.
+func GenerateCalendarToken(userID uuid.UUID, version int) (string, error) {
+	claims := &CalendarClaims{
+		UserID:  userID,
+		Version: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience: jwt.ClaimStrings{calendarAudience},
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(calendarKey())
+}
+
+// ErrCalendarRevoked is returned for a feed token issued before the
+// user's feed URLs were rotated
+var ErrCalendarRevoked = errors.New("calendar token revoked")
+
+// ValidateCalendarToken returns the user a feed token belongs to. version
+// looks up the user's current feed version.
+func ValidateCalendarToken(tokenString string, version func(userID uuid.UUID) (int, error)) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &CalendarClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return calendarKey(), nil
+	}, jwt.WithAudience(calendarAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	claims, ok := token.Claims.(*CalendarClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, errors.New("invalid token")
+	}
+
+	current, err := version(claims.UserID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Version != current {
+		return uuid.Nil, ErrCalendarRevoked
+	}
+	return claims.UserID, nil
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestCalendarTokenRotation(t *testing.T) {
+	t.Setenv("JWT_SECRET", "calendar test secret")
+	userID := uuid.New()
+	current := 0
+	version := func(id uuid.UUID) (int, error) {
+		if id != userID {
+			t.Errorf("looked up the version of %s, want %s", id, userID)
+		}
+		return current, nil
+	}
+
+	token, err := GenerateCalendarToken(userID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ValidateCalendarToken(token, version); err != nil || got != userID {
+		t.Fatalf("ValidateCalendarToken = %s, %v; want %s", got, err, userID)
+	}
+
+	current++
+	if _, err := ValidateCalendarToken(token, version); !errors.Is(err, ErrCalendarRevoked) {
+		t.Errorf("token of a rotated feed: err = %v, want ErrCalendarRevoked", err)
+	}
+	rotated, err := GenerateCalendarToken(userID, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateCalendarToken(rotated, version); err != nil {
+		t.Errorf("token after rotating: %v", err)
+	}
+
+	// Tokens for other purposes do not open feeds
+	session, err := GenerateToken(&models.User{ID: userID, Email: "agent@example.com", Role: models.RoleAgent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateCalendarToken(session, version); err == nil {
+		t.Error("a session token opened a calendar feed")
+	}
+}
@@ -29,19 +29,13 @@ func GenerateToken(user *models.User) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "your-secret-key"
-	}
+	secretKey := secret()
 
 	return token.SignedString([]byte(secretKey))
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "your-secret-key"
-	}
+	secretKey := secret()
 
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secretKey), nil
@@ -58,3 +52,10 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+func secret() string {
+	if key := os.Getenv("JWT_SECRET"); key != "" {
+		return key
+	}
+	return "your-secret-key"
+}
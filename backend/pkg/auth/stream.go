@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"quickdesk-backend/internal/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const streamAudience = "stream"
+
+// StreamTokenTTL is how long a stream token can open a stream. Open
+// streams outlive it; each reconnect needs a new token.
+const StreamTokenTTL = time.Minute
+
+// StreamClaims identify who opens an event stream
+type StreamClaims struct {
+	UserID uuid.UUID   `json:"user_id"`
+	Role   models.Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// streamKey is derived from the JWT secret like calendarKey, so stream
+// tokens only open streams
+func streamKey() []byte {
+	return []byte(secret() + ":" + streamAudience)
+}
+
+// GenerateStreamToken signs a short-lived token for opening an event
+// stream. EventSource cannot set headers, so the token goes in the URL,
+// where access logs keep it; the session token must not.
+func GenerateStreamToken(userID uuid.UUID, role models.Role) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(StreamTokenTTL)
+	claims := &StreamClaims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{streamAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(streamKey())
+	return signed, expiresAt, err
+}
+
+// ValidateStreamToken returns who a stream token was issued to
+func ValidateStreamToken(tokenString string) (*StreamClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &StreamClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return streamKey(), nil
+	}, jwt.WithAudience(streamAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*StreamClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
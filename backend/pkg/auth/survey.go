@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const surveyAudience = "csat"
+
+// ErrTokenExpired is wrapped by validation errors for expired tokens
+var ErrTokenExpired = jwt.ErrTokenExpired
+
+// SurveyClaims identify the satisfaction survey a one-click link answers
+type SurveyClaims struct {
+	SurveyID uuid.UUID `json:"survey_id"`
+	jwt.RegisteredClaims
+}
+
+// surveyKey is derived from the JWT secret so survey links can never be
+// used as login tokens or the other way round
+func surveyKey() []byte {
+	return []byte(secret() + ":" + surveyAudience)
+}
+
+// GenerateSurveyToken signs a survey link that needs no login
+func GenerateSurveyToken(surveyID uuid.UUID, expiresAt time.Time) (string, error) {
+	claims := &SurveyClaims{
+		SurveyID: surveyID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{surveyAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(surveyKey())
+}
+
+// ValidateSurveyToken returns the survey a link belongs to. Expired or
+// tampered links are rejected.
+func ValidateSurveyToken(tokenString string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &SurveyClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return surveyKey(), nil
+	}, jwt.WithAudience(surveyAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	claims, ok := token.Claims.(*SurveyClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, errors.New("invalid token")
+	}
+
+	return claims.SurveyID, nil
+}
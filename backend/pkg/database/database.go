@@ -21,6 +21,26 @@ func Initialize(databaseURL string) (*gorm.DB, error) {
 		&models.Comment{},
 		&models.Vote{},
 		&models.Attachment{},
+		&models.Tag{},
+		&models.Team{},
+		&models.SavedView{},
+		&models.ImportRecord{},
+		&models.ReportSchedule{},
+		&models.ReportRun{},
+		&models.SatisfactionSurvey{},
+		&models.TimeEntry{},
+		&models.RecurringTicket{},
+		&models.TicketTemplate{},
+		&models.InboundEmail{},
+		&models.OutboundEmail{},
+		&models.EmailTemplate{},
+		&models.NotificationPreference{},
+		&models.DigestItem{},
+		&models.Notification{},
+		&models.TicketPresence{},
+		&models.TicketWatcher{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,229 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxAttempts is how often a message is tried before it is dead
+	MaxAttempts = 10
+	// retryBase and retryCap bound the backoff: 1, 2, 4 ... 60 minutes
+	retryBase = time.Minute
+	retryCap  = time.Hour
+	// sendLease is how long a worker holds a message; a message still
+	// sending after that, e.g. because its server died, is tried again
+	sendLease = 5 * time.Minute
+	// pollInterval picks up retries and mail queued by other instances
+	pollInterval = 15 * time.Second
+)
+
+// ErrNotFound is returned when re-sending a message that does not exist
+var ErrNotFound = errors.New("email not found")
+
+// enqueue renders a message into the outbox and wakes a worker. Without a
+// database the message is sent straight away.
+func (es *EmailService) enqueue(m *gomail.Message, ticketID string) error {
+	var raw bytes.Buffer
+	if _, err := m.WriteTo(&raw); err != nil {
+		return err
+	}
+	if es.db == nil {
+		return es.deliver(raw.Bytes())
+	}
+
+	outbound := models.OutboundEmail{
+		ID:            uuid.New(),
+		Recipients:    strings.Join(m.GetHeader("To"), ", "),
+		Subject:       strings.Join(m.GetHeader("Subject"), " "),
+		Message:       raw.Bytes(),
+		Status:        models.OutboundPending,
+		NextAttemptAt: time.Now(),
+	}
+	if id, err := uuid.Parse(ticketID); err == nil {
+		outbound.TicketID = &id
+	}
+	if err := es.db.Create(&outbound).Error; err != nil {
+		return err
+	}
+	es.wakeWorker()
+	return nil
+}
+
+// Resend queues a copy of an earlier message, e.g. one that went dead
+// during an SMTP outage. The original stays in the log.
+func (es *EmailService) Resend(id uuid.UUID) (*models.OutboundEmail, error) {
+	var original models.OutboundEmail
+	if err := es.db.First(&original, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	copied := models.OutboundEmail{
+		ID:            uuid.New(),
+		Recipients:    original.Recipients,
+		Subject:       original.Subject,
+		TicketID:      original.TicketID,
+		Message:       original.Message,
+		Status:        models.OutboundPending,
+		NextAttemptAt: time.Now(),
+		ResentFromID:  &original.ID,
+	}
+	if err := es.db.Create(&copied).Error; err != nil {
+		return nil, err
+	}
+	es.wakeWorker()
+	return &copied, nil
+}
+
+func (es *EmailService) wakeWorker() {
+	select {
+	case es.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs workers that deliver the outbox until ctx is cancelled.
+// Several server instances can share one outbox.
+func (es *EmailService) Start(ctx context.Context, workers int) {
+	if es.db == nil {
+		return
+	}
+	for i := 0; i < workers; i++ {
+		es.wg.Add(1)
+		go func() {
+			defer es.wg.Done()
+			ticker := time.NewTicker(pollInterval)
+			defer ticker.Stop()
+			for {
+				for ctx.Err() == nil && es.deliverNext(time.Now()) {
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-es.wake:
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until every worker has returned after ctx was cancelled
+func (es *EmailService) Wait() {
+	es.wg.Wait()
+}
+
+// deliverNext claims and sends one due message, reporting whether there
+// was one
+func (es *EmailService) deliverNext(now time.Time) bool {
+	var outbound models.OutboundEmail
+	err := es.db.Raw(`
+		UPDATE outbound_emails
+		SET status = ?, attempts = attempts + 1, next_attempt_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM outbound_emails
+			WHERE status IN ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.OutboundSending, now.Add(sendLease), now,
+		[]models.OutboundStatus{models.OutboundPending, models.OutboundSending}, now,
+	).Scan(&outbound).Error
+	if err != nil {
+		log.Printf("email: claiming outbox message: %v", err)
+		return false
+	}
+	if outbound.ID == uuid.Nil {
+		return false
+	}
+
+	err = es.deliver(outbound.Message)
+	updates := map[string]interface{}{"updated_at": time.Now()}
+	switch {
+	case err == nil:
+		updates["status"] = models.OutboundSent
+		updates["sent_at"] = time.Now()
+		updates["last_error"] = ""
+	case permanent(err) || outbound.Attempts >= MaxAttempts:
+		updates["status"] = models.OutboundDead
+		updates["last_error"] = err.Error()
+		log.Printf("email: giving up on %s to %s: %v", outbound.ID, outbound.Recipients, err)
+	default:
+		updates["status"] = models.OutboundPending
+		updates["next_attempt_at"] = time.Now().Add(retryDelay(outbound.Attempts))
+		updates["last_error"] = err.Error()
+	}
+	if err := es.db.Model(&outbound).Updates(updates).Error; err != nil {
+		log.Printf("email: recording delivery of %s: %v", outbound.ID, err)
+	}
+	return true
+}
+
+// retryDelay doubles the wait after every failed attempt, with jitter so
+// a backlog does not retry in lockstep
+func retryDelay(attempts int) time.Duration {
+	delay := retryCap
+	if attempts <= 6 {
+		delay = min(retryBase<<(attempts-1), retryCap)
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/10)+1))
+}
+
+// permanent reports whether the server refused a message for good, such
+// as an unknown mailbox. Other errors, including failed logins, are
+// retried.
+func permanent(err error) bool {
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 550
+}
+
+type envelope struct {
+	from string
+	to   []string
+}
+
+// readEnvelope takes the envelope of a rendered message from its headers
+func readEnvelope(raw []byte) (*envelope, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	e := &envelope{from: msg.Header.Get("From")}
+	if from, err := mail.ParseAddress(e.from); err == nil {
+		e.from = from.Address
+	}
+	for _, key := range []string{"To", "Cc"} {
+		list, err := msg.Header.AddressList(key)
+		if errors.Is(err, mail.ErrHeaderNotPresent) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range list {
+			e.to = append(e.to, a.Address)
+		}
+	}
+	if len(e.to) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+	return e, nil
+}
@@ -1,102 +1,261 @@
 package email
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	htmltemplate "html/template"
+	"io"
+	"quickdesk-backend/internal/config"
+	"quickdesk-backend/internal/models"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
 )
 
 type EmailService struct {
-	host     string
-	port     int
-	username string
-	password string
+	transport Transport // Nil when email is not configured
+	from      string
+	replyTo   string  // Support mailbox replies go to, tagged per ticket
+	threads   Threads // Signs the ticket tags in Message-IDs and reply addresses
+	domain    string  // For generated Message-IDs
+	appURL    string  // Frontend base URL for ticket links
+
+	// Outbox; messages are sent directly when db is nil
+	db   *gorm.DB
+	wake chan struct{}
+	wg   sync.WaitGroup
 }
 
-func NewEmailService() *EmailService {
-	port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
-	if port == 0 {
-		port = 587
+// NewEmailService builds the service with the transport cfg selects:
+// "smtp", "maildir" or "memory". Messages are queued in the database's
+// outbox; Start runs the workers that deliver them.
+func NewEmailService(db *gorm.DB, cfg *config.Config) (*EmailService, error) {
+	var transport Transport
+	switch cfg.EmailTransport {
+	case "smtp", "":
+		// Without credentials email is switched off
+		if cfg.SMTPUser != "" && cfg.SMTPPass != "" {
+			port, err := strconv.Atoi(cfg.SMTPPort)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP port %q", cfg.SMTPPort)
+			}
+			transport = &SMTPTransport{Host: cfg.SMTPHost, Port: port, Username: cfg.SMTPUser, Password: cfg.SMTPPass}
+		}
+	case "maildir":
+		transport = &MaildirTransport{Dir: cfg.MaildirPath}
+	case "memory":
+		transport = NewMemoryTransport()
+	default:
+		return nil, fmt.Errorf("unknown email transport %q", cfg.EmailTransport)
+	}
+
+	from := cfg.EmailFrom
+	if from == "" {
+		from = cfg.SMTPUser
 	}
+	es := NewWithTransport(db, transport, from, cfg.ReplyToAddress, cfg.FrontendURL)
+	es.threads = NewThreads(cfg.JWTSecret)
+	return es, nil
+}
 
+// NewWithTransport builds the service around a transport. Tests pass a
+// MemoryTransport and a nil db to capture messages as they are sent with
+// the built-in templates. Ticket tags are signed with an empty secret.
+func NewWithTransport(db *gorm.DB, transport Transport, from, replyTo, frontendURL string) *EmailService {
 	return &EmailService{
-		host:     os.Getenv("SMTP_HOST"),
-		port:     port,
-		username: os.Getenv("SMTP_USER"),
-		password: os.Getenv("SMTP_PASS"),
+		transport: transport,
+		from:      from,
+		replyTo:   replyTo,
+		domain:    messageDomain(replyTo, from),
+		threads:   NewThreads(""),
+		appURL:    strings.TrimRight(frontendURL, "/"),
+		db:        db,
+		wake:      make(chan struct{}, 1),
 	}
 }
 
-func (es *EmailService) SendTicketCreatedEmail(to, ticketSubject, ticketID string) error {
-	subject := "New Ticket Created: " + ticketSubject
-	body := fmt.Sprintf(`
-		<h2>New Ticket Created</h2>
-		<p>A new support ticket has been created:</p>
-		<p><strong>Subject:</strong> %s</p>
-		<p><strong>Ticket ID:</strong> %s</p>
-		<p>You can view and manage this ticket in the QuickDesk system.</p>
-	`, ticketSubject, ticketID)
+// Recipient is who an email goes to. Language picks the template locale.
+type Recipient struct {
+	Email    string
+	Name     string
+	Language string
+}
+
+// RecipientOf addresses a user in their language
+func RecipientOf(user *models.User) Recipient {
+	return Recipient{Email: user.Email, Name: strings.TrimSpace(user.FirstName + " " + user.LastName), Language: user.Language}
+}
+
+func (es *EmailService) SendTicketCreatedEmail(to Recipient, ticket *models.Ticket) error {
+	return es.sendTicketEmail(TemplateTicketCreated, to, ticket, TemplateData{}, true)
+}
+
+func (es *EmailService) SendTicketUpdatedEmail(to Recipient, ticket *models.Ticket) error {
+	return es.sendTicketEmail(TemplateTicketUpdated, to, ticket, TemplateData{}, false)
+}
+
+func (es *EmailService) SendTicketAssignedEmail(to Recipient, ticket *models.Ticket, assignee Person) error {
+	return es.sendTicketEmail(TemplateTicketAssigned, to, ticket, TemplateData{Assignee: assignee}, false)
+}
+
+// SendCommentAddedEmail quotes the comment, so it can be answered by
+// replying to the email
+func (es *EmailService) SendCommentAddedEmail(to Recipient, ticket *models.Ticket, comment CommentInfo) error {
+	return es.sendTicketEmail(TemplateCommentAdded, to, ticket, TemplateData{Comment: comment}, false)
+}
 
-	return es.sendEmail(to, subject, body)
+// SendMentionEmail tells someone a comment @mentioned them
+func (es *EmailService) SendMentionEmail(to Recipient, ticket *models.Ticket, comment CommentInfo) error {
+	return es.sendTicketEmail(TemplateMentioned, to, ticket, TemplateData{Comment: comment}, false)
 }
 
-func (es *EmailService) SendTicketUpdatedEmail(to, ticketSubject, ticketID, status string) error {
-	subject := "Ticket Updated: " + ticketSubject
-	body := fmt.Sprintf(`
-		<h2>Ticket Status Updated</h2>
-		<p>Your support ticket has been updated:</p>
-		<p><strong>Subject:</strong> %s</p>
-		<p><strong>Ticket ID:</strong> %s</p>
-		<p><strong>New Status:</strong> %s</p>
-		<p>You can view the details in the QuickDesk system.</p>
-	`, ticketSubject, ticketID, status)
+// SendSLAWarningEmail warns that a ticket is about to miss an SLA target
+func (es *EmailService) SendSLAWarningEmail(to Recipient, ticket *models.Ticket, sla SLAInfo) error {
+	return es.sendTicketEmail(TemplateSLAWarning, to, ticket, TemplateData{SLA: sla}, false)
+}
+
+// SendDigestEmail batches notifications about several tickets into one
+// email. It is not part of any ticket's thread.
+func (es *EmailService) SendDigestEmail(to Recipient, digest DigestInfo) error {
+	if !es.Configured() {
+		return nil
+	}
+
+	rendered, err := es.render(TemplateDigest, to.Language, TemplateData{
+		Recipient: Person{Name: to.Name, Email: to.Email},
+		Digest:    digest,
+	})
+	if err != nil {
+		return err
+	}
 
-	return es.sendEmail(to, subject, body)
+	m := es.newMessage(rendered)
+	m.SetHeader("To", to.Email)
+	m.SetHeader("Auto-Submitted", "auto-generated")
+	return es.enqueue(m, "")
 }
 
-func (es *EmailService) SendTicketAssignedEmail(to, ticketSubject, ticketID, assigneeName string) error {
-	subject := "Ticket Assigned: " + ticketSubject
-	body := fmt.Sprintf(`
-		<h2>Ticket Assigned</h2>
-		<p>A ticket has been assigned to you:</p>
-		<p><strong>Subject:</strong> %s</p>
-		<p><strong>Ticket ID:</strong> %s</p>
-		<p><strong>Assigned to:</strong> %s</p>
-		<p>Please check the QuickDesk system to begin working on this ticket.</p>
-	`, ticketSubject, ticketID, assigneeName)
+// ratingLabels name the survey ratings 1 to 5
+var ratingLabels = []string{"Very poor", "Poor", "Okay", "Good", "Excellent"}
 
-	return es.sendEmail(to, subject, body)
+// SendSatisfactionSurveyEmail asks the requester of a resolved ticket to
+// rate the support they got. Each rating is a one-click link to surveyURL.
+func (es *EmailService) SendSatisfactionSurveyEmail(to Recipient, ticket *models.Ticket, surveyURL string) error {
+	return es.sendTicketEmail(TemplateSurvey, to, ticket, surveyData(surveyURL), false)
 }
 
-func (es *EmailService) SendCommentAddedEmail(to, ticketSubject, ticketID, commenterName string) error {
-	subject := "New Comment on Ticket: " + ticketSubject
-	body := fmt.Sprintf(`
-		<h2>New Comment Added</h2>
-		<p>A new comment has been added to your ticket:</p>
-		<p><strong>Subject:</strong> %s</p>
-		<p><strong>Ticket ID:</strong> %s</p>
-		<p><strong>Comment by:</strong> %s</p>
-		<p>You can view the comment in the QuickDesk system.</p>
-	`, ticketSubject, ticketID, commenterName)
+func surveyData(surveyURL string) TemplateData {
+	data := TemplateData{SurveyURL: surveyURL}
+	for i, label := range ratingLabels {
+		data.Ratings = append(data.Ratings, Rating{Value: i + 1, Label: label, URL: fmt.Sprintf("%s?rating=%d", surveyURL, i+1)})
+	}
+	return data
+}
 
-	return es.sendEmail(to, subject, body)
+// SendDueReminderEmail reminds an assignee that a ticket is due soon, or
+// already overdue
+func (es *EmailService) SendDueReminderEmail(to Recipient, ticket *models.Ticket) error {
+	return es.sendTicketEmail(TemplateDueReminder, to, ticket, TemplateData{}, false)
 }
 
-func (es *EmailService) sendEmail(to, subject, body string) error {
-	if es.username == "" || es.password == "" {
+// SendReportEmail sends a report as an inline HTML table with the same
+// data attached as CSV
+func (es *EmailService) SendReportEmail(to []string, title, htmlTable, csvName string, csv []byte) error {
+	if !es.Configured() {
+		return ErrNotConfigured
+	}
+
+	rendered, err := es.render(TemplateReport, DefaultLocale, TemplateData{
+		Report: ReportInfo{Title: title, Table: htmltemplate.HTML(htmlTable)},
+	})
+	if err != nil {
+		return err
+	}
+
+	m := es.newMessage(rendered)
+	m.SetHeader("To", to...)
+	m.Attach(csvName, gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(csv)
+		return err
+	}), gomail.SetHeader(map[string][]string{"Content-Type": {"text/csv; charset=utf-8"}}))
+
+	return es.enqueue(m, "")
+}
+
+// TicketInfo is what templates see of a ticket
+func (es *EmailService) TicketInfo(ticket *models.Ticket) TicketInfo {
+	info := TicketInfo{
+		ID:       ticket.ID.String(),
+		Subject:  ticket.Subject,
+		Status:   string(ticket.Status),
+		Priority: string(ticket.Priority),
+		URL:      es.appURL + "/tickets/" + ticket.ID.String(),
+		Overdue:  ticket.IsOverdue(time.Now()),
+	}
+	if ticket.DueAt != nil {
+		info.DueAt = *ticket.DueAt
+	}
+	return info
+}
+
+// Transport returns the transport messages are sent with, e.g. for tests
+// to inspect a MemoryTransport
+func (es *EmailService) Transport() Transport {
+	return es.transport
+}
+
+// ErrNotConfigured is returned by senders that must not silently skip
+// delivery when no transport is configured
+var ErrNotConfigured = errors.New("email service is not configured")
+
+// Configured reports whether there is a transport to send with
+func (es *EmailService) Configured() bool {
+	return es.transport != nil
+}
+
+// sendTicketEmail renders a template for a recipient and sends it in the
+// ticket's email conversation; first marks the message that opens it
+func (es *EmailService) sendTicketEmail(name string, to Recipient, ticket *models.Ticket, data TemplateData, first bool) error {
+	if !es.Configured() {
 		// Email service not configured, skip sending
 		return nil
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", es.username)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
+	data.Recipient = Person{Name: to.Name, Email: to.Email}
+	data.Ticket = es.TicketInfo(ticket)
+	rendered, err := es.render(name, to.Language, data)
+	if err != nil {
+		return err
+	}
 
-	d := gomail.NewDialer(es.host, es.port, es.username, es.password)
+	m := es.newMessage(rendered)
+	m.SetHeader("To", to.Email)
+	es.threadHeaders(m, data.Ticket.ID, first)
+	m.SetHeader("Auto-Submitted", "auto-generated") // Keeps vacation replies from answering
 
-	return d.DialAndSend(m)
+	return es.enqueue(m, data.Ticket.ID)
+}
+
+// newMessage builds a message with a plaintext part and its HTML
+// alternative
+func (es *EmailService) newMessage(rendered *Rendered) *gomail.Message {
+	m := gomail.NewMessage()
+	m.SetHeader("From", es.from)
+	m.SetHeader("Subject", rendered.Subject)
+	m.SetBody("text/plain", rendered.Text)
+	m.AddAlternative("text/html", rendered.HTML)
+	return m
+}
+
+// deliver sends a rendered message to the addresses in its headers
+func (es *EmailService) deliver(raw []byte) error {
+	envelope, err := readEnvelope(raw)
+	if err != nil {
+		return err
+	}
+	return es.transport.Send(envelope.from, envelope.to, raw)
 }
@@ -0,0 +1,474 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+)
+
+// Template names
+const (
+	TemplateTicketCreated  = "ticket_created"
+	TemplateTicketUpdated  = "ticket_updated"
+	TemplateTicketAssigned = "ticket_assigned"
+	TemplateCommentAdded   = "comment_added"
+	TemplateSurvey         = "satisfaction_survey"
+	TemplateDueReminder    = "due_reminder"
+	TemplateReport         = "report"
+	TemplateMentioned      = "mentioned"
+	TemplateSLAWarning     = "sla_warning"
+	TemplateDigest         = "digest"
+)
+
+// DefaultLocale is the locale of the built-in templates. Recipients whose
+// language has no template of its own get these.
+const DefaultLocale = "en"
+
+// Template is the content of one email. Subject and Text are
+// text/template, HTML is html/template.
+type Template struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html_body"`
+	Text    string `json:"text_body"`
+}
+
+// TemplateData holds the variables every template is rendered with. The
+// comment on each field names the templates that set it.
+type TemplateData struct {
+	Recipient Person      // All: who the email goes to
+	Ticket    TicketInfo  // All but report
+	Assignee  Person      // ticket_assigned
+	Comment   CommentInfo // comment_added
+	SurveyURL string      // satisfaction_survey: add ?rating=1 to 5
+	Ratings   []Rating    // satisfaction_survey: one-click rating links
+	Report    ReportInfo  // report
+	SLA       SLAInfo     // sla_warning
+	Digest    DigestInfo  // digest
+}
+
+type Person struct {
+	Name  string
+	Email string
+}
+
+type TicketInfo struct {
+	ID       string
+	Subject  string
+	Status   string
+	Priority string
+	URL      string    // The ticket in the web app
+	DueAt    time.Time // Zero without a due date; format with {{date .Ticket.DueAt}}
+	Overdue  bool
+}
+
+type CommentInfo struct {
+	Author  string
+	Content string // Use {{nl2br .Comment.Content}} in HTML to keep line breaks
+}
+
+type Rating struct {
+	Value int
+	Label string // English, e.g. "Excellent"
+	URL   string
+}
+
+type ReportInfo struct {
+	Title string
+	Table htmltemplate.HTML // The report as an HTML table
+}
+
+type SLAInfo struct {
+	Target   string    // "first_response" or "resolution"
+	Deadline time.Time // When the target is missed
+}
+
+type DigestInfo struct {
+	Period string // "hourly" or "daily"
+	Items  []DigestEntry
+}
+
+// DigestEntry is one notification in a digest. Event names the preference
+// it was batched under, e.g. "commented", and decides which fields are set.
+type DigestEntry struct {
+	Event    string
+	Ticket   TicketInfo
+	Actor    string    // created, status_changed, commented, assigned, mentioned: who did it
+	Detail   string    // The comment, the new status, or the SLA target as in SLAInfo
+	Deadline time.Time // sla_warning
+	Time     time.Time // When it happened
+}
+
+// Rendered is a template executed for one recipient
+type Rendered struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format("Mon, 02 Jan 2006 15:04 MST")
+}
+
+var textFuncs = texttemplate.FuncMap{"date": formatDate}
+
+var htmlFuncs = htmltemplate.FuncMap{
+	"date": formatDate,
+	"nl2br": func(s string) htmltemplate.HTML {
+		return htmltemplate.HTML(strings.ReplaceAll(htmltemplate.HTMLEscapeString(s), "\n", "<br>"))
+	},
+}
+
+// Render executes a template against data
+func Render(t Template, data TemplateData) (*Rendered, error) {
+	subject, err := texttemplate.New("subject").Funcs(textFuncs).Parse(t.Subject)
+	if err != nil {
+		return nil, err
+	}
+	text, err := texttemplate.New("text").Funcs(textFuncs).Parse(t.Text)
+	if err != nil {
+		return nil, err
+	}
+	html, err := htmltemplate.New("html").Funcs(htmlFuncs).Parse(t.HTML)
+	if err != nil {
+		return nil, err
+	}
+
+	var out [3]bytes.Buffer
+	if err := subject.Execute(&out[0], data); err != nil {
+		return nil, err
+	}
+	if err := html.Execute(&out[1], data); err != nil {
+		return nil, err
+	}
+	if err := text.Execute(&out[2], data); err != nil {
+		return nil, err
+	}
+	return &Rendered{
+		// A subject is one line, however the template wraps it
+		Subject: strings.Join(strings.Fields(out[0].String()), " "),
+		HTML:    out[1].String(),
+		Text:    out[2].String(),
+	}, nil
+}
+
+// TemplateNames lists the templates in a stable order
+func TemplateNames() []string {
+	return []string{TemplateTicketCreated, TemplateTicketUpdated, TemplateTicketAssigned,
+		TemplateCommentAdded, TemplateSurvey, TemplateDueReminder, TemplateReport,
+		TemplateMentioned, TemplateSLAWarning, TemplateDigest}
+}
+
+// DefaultTemplate returns a built-in template
+func DefaultTemplate(name string) (Template, bool) {
+	t, ok := defaultTemplates[name]
+	return t, ok
+}
+
+// ErrUnknownTemplate is returned for a name with no built-in template
+var ErrUnknownTemplate = errors.New("unknown email template")
+
+// ErrInvalidLocale is returned for a locale that is not a BCP 47 tag
+var ErrInvalidLocale = errors.New("invalid locale")
+
+// CanonicalLocale normalizes a BCP 47 tag, e.g. "pt_br" to "pt-BR"
+func CanonicalLocale(locale string) (string, error) {
+	tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-"))
+	if err != nil {
+		return "", ErrInvalidLocale
+	}
+	return tag.String(), nil
+}
+
+// localeChain lists the locales tried for a language: the tag, its base
+// language and DefaultLocale
+func localeChain(locale string) []string {
+	var chain []string
+	if tag, err := language.Parse(strings.ReplaceAll(locale, "_", "-")); err == nil {
+		chain = append(chain, tag.String())
+		if base, confidence := tag.Base(); confidence != language.No && base.String() != tag.String() {
+			chain = append(chain, base.String())
+		}
+	}
+	return append(chain, DefaultLocale)
+}
+
+// ResolveTemplate returns the template used for name and a recipient's
+// language, and the locale it was found for. A stored template wins over
+// the built-in one; a language without templates falls back to its base
+// language and then to DefaultLocale.
+func (es *EmailService) ResolveTemplate(name, locale string) (Template, string, error) {
+	builtIn, ok := defaultTemplates[name]
+	if !ok {
+		return Template{}, "", ErrUnknownTemplate
+	}
+	chain := localeChain(locale)
+	if es.db != nil {
+		var stored []models.EmailTemplate
+		if err := es.db.Where("name = ? AND locale IN ?", name, chain).Find(&stored).Error; err != nil {
+			return Template{}, "", err
+		}
+		for _, l := range chain {
+			for _, t := range stored {
+				if t.Locale == l {
+					return Template{Subject: t.Subject, HTML: t.HTMLBody, Text: t.TextBody}, l, nil
+				}
+			}
+		}
+	}
+	return builtIn, DefaultLocale, nil
+}
+
+// render executes the template for a recipient. A stored template that
+// fails, e.g. after an edit referencing a missing variable, is logged and
+// the built-in one is used so the email still goes out.
+func (es *EmailService) render(name, locale string, data TemplateData) (*Rendered, error) {
+	t, found, err := es.ResolveTemplate(name, locale)
+	if errors.Is(err, ErrUnknownTemplate) {
+		return nil, err
+	}
+	if err == nil {
+		var rendered *Rendered
+		if rendered, err = Render(t, data); err == nil {
+			return rendered, nil
+		}
+	}
+	log.Printf("email: template %s (%s): %v", name, found, err)
+	return Render(defaultTemplates[name], data)
+}
+
+// SampleData fills every template variable for previews. The ticket and
+// its requester come from ticket when given, otherwise from a made-up one.
+func (es *EmailService) SampleData(ticket *models.Ticket) TemplateData {
+	if ticket == nil {
+		due := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		ticket = &models.Ticket{
+			ID:        uuid.MustParse("5f1c7a2e-9b4d-4e8a-a1f0-3c2d6b7e8f90"),
+			Subject:   "Printer on the third floor keeps jamming",
+			Status:    models.StatusInProgress,
+			Priority:  models.PriorityHigh,
+			DueAt:     &due,
+			CreatedBy: models.User{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com"},
+		}
+	}
+	requester := RecipientOf(&ticket.CreatedBy)
+
+	data := surveyData(es.appURL + "/csat/sample-token")
+	data.Recipient = Person{Name: requester.Name, Email: requester.Email}
+	data.Ticket = es.TicketInfo(ticket)
+	data.Assignee = Person{Name: "Alex Agent", Email: "alex.agent@example.com"}
+	data.Comment = CommentInfo{Author: "Alex Agent", Content: "I have ordered a replacement roller.\nIt should arrive on Thursday."}
+	data.Report = ReportInfo{
+		Title: "Agent performance",
+		Table: `<table><tr><th>Agent</th><th>Resolved</th></tr><tr><td>Alex Agent</td><td>12</td></tr></table>`,
+	}
+	deadline := time.Now().Add(time.Hour).Truncate(time.Minute)
+	data.SLA = SLAInfo{Target: "resolution", Deadline: deadline}
+	data.Digest = DigestInfo{Period: "daily", Items: []DigestEntry{
+		{Event: "status_changed", Ticket: data.Ticket, Actor: "Alex Agent", Detail: string(models.StatusInProgress), Time: deadline.Add(-3 * time.Hour)},
+		{Event: "commented", Ticket: data.Ticket, Actor: "Alex Agent", Detail: data.Comment.Content, Time: deadline.Add(-2 * time.Hour)},
+		{Event: "sla_warning", Ticket: data.Ticket, Detail: data.SLA.Target, Deadline: deadline, Time: deadline.Add(-time.Hour)},
+	}}
+	return data
+}
+
+var defaultTemplates = map[string]Template{
+	TemplateTicketCreated: {
+		Subject: `New Ticket Created: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>New Ticket Created</h2>
+		<p>A new support ticket has been created:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p>You can <a href="{{.Ticket.URL}}">view and manage this ticket</a> in the QuickDesk system.</p>
+	`,
+		Text: `A new support ticket has been created.
+
+Subject: {{.Ticket.Subject}}
+Ticket ID: {{.Ticket.ID}}
+
+View and manage this ticket in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateTicketUpdated: {
+		Subject: `Ticket Updated: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>Ticket Status Updated</h2>
+		<p>Your support ticket has been updated:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p><strong>New Status:</strong> {{.Ticket.Status}}</p>
+		<p>You can <a href="{{.Ticket.URL}}">view the details</a> in the QuickDesk system.</p>
+	`,
+		Text: `Your support ticket has been updated.
+
+Subject: {{.Ticket.Subject}}
+Ticket ID: {{.Ticket.ID}}
+New Status: {{.Ticket.Status}}
+
+View the details in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateTicketAssigned: {
+		Subject: `Ticket Assigned: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>Ticket Assigned</h2>
+		<p>A ticket has been assigned to you:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p><strong>Assigned to:</strong> {{.Assignee.Name}}</p>
+		<p>Please <a href="{{.Ticket.URL}}">open the ticket</a> in the QuickDesk system to begin working on it.</p>
+	`,
+		Text: `A ticket has been assigned to you.
+
+Subject: {{.Ticket.Subject}}
+Ticket ID: {{.Ticket.ID}}
+Assigned to: {{.Assignee.Name}}
+
+Open the ticket in the QuickDesk system to begin working on it: {{.Ticket.URL}}
+`,
+	},
+	TemplateCommentAdded: {
+		Subject: `New Comment on Ticket: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>New Comment Added</h2>
+		<p>A new comment has been added to your ticket:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p><strong>Comment by:</strong> {{.Comment.Author}}</p>
+		<blockquote>{{nl2br .Comment.Content}}</blockquote>
+		<p>Reply to this email to answer, or <a href="{{.Ticket.URL}}">view the comment</a> in the QuickDesk system.</p>
+	`,
+		Text: `{{.Comment.Author}} commented on your ticket "{{.Ticket.Subject}}":
+
+{{.Comment.Content}}
+
+Reply to this email to answer, or view the ticket in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateSurvey: {
+		Subject: `How did we do? {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>Your Ticket Was Resolved</h2>
+		<p>Your support ticket has been resolved:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p>How satisfied are you with the support you received?</p>
+		<p>{{range .Ratings}}<a href="{{.URL}}" style="margin-right: 12px;">{{.Value}} &ndash; {{.Label}}</a>{{end}}</p>
+		<p>You can add a comment after choosing a rating. This link expires in a few days.</p>
+	`,
+		Text: `Your support ticket "{{.Ticket.Subject}}" has been resolved.
+
+How satisfied are you with the support you received?
+{{range .Ratings}}
+{{.Value}} - {{.Label}}: {{.URL}}{{end}}
+
+You can add a comment after choosing a rating. This link expires in a few days.
+`,
+	},
+	TemplateDueReminder: {
+		Subject: `{{if .Ticket.Overdue}}Ticket Overdue{{else}}Ticket Due Soon{{end}}: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>{{if .Ticket.Overdue}}Ticket Overdue{{else}}Ticket Due Soon{{end}}</h2>
+		<p>A ticket assigned to you has a due date coming up:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p><strong>Due:</strong> {{date .Ticket.DueAt}}</p>
+		<p><a href="{{.Ticket.URL}}">Open the ticket</a> in the QuickDesk system.</p>
+	`,
+		Text: `A ticket assigned to you {{if .Ticket.Overdue}}is overdue{{else}}is due soon{{end}}.
+
+Subject: {{.Ticket.Subject}}
+Ticket ID: {{.Ticket.ID}}
+Due: {{date .Ticket.DueAt}}
+
+Open the ticket in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateReport: {
+		Subject: `Report: {{.Report.Title}}`,
+		HTML: `
+		<h2>{{.Report.Title}}</h2>
+		{{.Report.Table}}
+		<p>The full report is attached as CSV.</p>
+	`,
+		Text: `{{.Report.Title}}
+
+The report is attached as CSV.
+`,
+	},
+	TemplateMentioned: {
+		Subject: `{{.Comment.Author}} mentioned you: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>You Were Mentioned</h2>
+		<p>{{.Comment.Author}} mentioned you in a comment:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<blockquote>{{nl2br .Comment.Content}}</blockquote>
+		<p><a href="{{.Ticket.URL}}">Open the ticket</a> in the QuickDesk system.</p>
+	`,
+		Text: `{{.Comment.Author}} mentioned you on the ticket "{{.Ticket.Subject}}":
+
+{{.Comment.Content}}
+
+Open the ticket in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateSLAWarning: {
+		Subject: `SLA Warning: {{.Ticket.Subject}}`,
+		HTML: `
+		<h2>SLA Warning</h2>
+		<p>A ticket is about to miss its {{if eq .SLA.Target "first_response"}}first response{{else}}resolution{{end}} target:</p>
+		<p><strong>Subject:</strong> {{.Ticket.Subject}}</p>
+		<p><strong>Ticket ID:</strong> {{.Ticket.ID}}</p>
+		<p><strong>Priority:</strong> {{.Ticket.Priority}}</p>
+		<p><strong>Target:</strong> {{date .SLA.Deadline}}</p>
+		<p><a href="{{.Ticket.URL}}">Open the ticket</a> in the QuickDesk system.</p>
+	`,
+		Text: `A ticket is about to miss its {{if eq .SLA.Target "first_response"}}first response{{else}}resolution{{end}} target.
+
+Subject: {{.Ticket.Subject}}
+Ticket ID: {{.Ticket.ID}}
+Priority: {{.Ticket.Priority}}
+Target: {{date .SLA.Deadline}}
+
+Open the ticket in the QuickDesk system: {{.Ticket.URL}}
+`,
+	},
+	TemplateDigest: {
+		Subject: `Your {{.Digest.Period}} QuickDesk digest: {{len .Digest.Items}} update{{if ne (len .Digest.Items) 1}}s{{end}}`,
+		HTML: `
+		<h2>Your QuickDesk Digest</h2>
+		<p>Here is what happened since your last digest:</p>
+		<ul>
+		{{range .Digest.Items}}<li>
+			<p><a href="{{.Ticket.URL}}">{{.Ticket.Subject}}</a> &ndash; {{date .Time}}</p>
+			{{if eq .Event "created"}}<p>Your ticket was opened.</p>
+			{{else if eq .Event "status_changed"}}<p>Status changed to <strong>{{.Detail}}</strong>.</p>
+			{{else if eq .Event "commented"}}<p>{{.Actor}} commented:</p><blockquote>{{nl2br .Detail}}</blockquote>
+			{{else if eq .Event "assigned"}}<p>Assigned to you by {{.Actor}}.</p>
+			{{else if eq .Event "mentioned"}}<p>{{.Actor}} mentioned you:</p><blockquote>{{nl2br .Detail}}</blockquote>
+			{{else if eq .Event "sla_warning"}}<p>{{if eq .Detail "first_response"}}First response{{else}}Resolution{{end}} target at {{date .Deadline}}.</p>
+			{{end}}
+		</li>{{end}}
+		</ul>
+		<p>You can change how you are notified in your QuickDesk settings.</p>
+	`,
+		Text: `Here is what happened since your last digest:
+{{range .Digest.Items}}
+* {{.Ticket.Subject}} ({{date .Time}})
+  {{if eq .Event "created"}}Your ticket was opened.{{else if eq .Event "status_changed"}}Status changed to {{.Detail}}.{{else if eq .Event "commented"}}{{.Actor}} commented: {{.Detail}}{{else if eq .Event "assigned"}}Assigned to you by {{.Actor}}.{{else if eq .Event "mentioned"}}{{.Actor}} mentioned you: {{.Detail}}{{else if eq .Event "sla_warning"}}{{if eq .Detail "first_response"}}First response{{else}}Resolution{{end}} target at {{date .Deadline}}.{{end}}
+  {{.Ticket.URL}}
+{{end}}
+You can change how you are notified in your QuickDesk settings.
+`,
+	},
+}
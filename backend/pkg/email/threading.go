@@ -0,0 +1,128 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/gomail.v2"
+)
+
+// threadHeaders places a message in its ticket's email conversation. The
+// message announcing the ticket carries the conversation's root
+// Message-ID, <ticket.ID.SIG@domain>, and later ones reference it, so mail
+// clients group them. Replies find their ticket again through these IDs,
+// the ticket's reply address or the [QD#ID.SIG] token in the subject; SIG
+// proves we generated them.
+func (es *EmailService) threadHeaders(m *gomail.Message, ticketID string, first bool) {
+	if subject := m.GetHeader("Subject"); len(subject) == 1 {
+		m.SetHeader("Subject", subject[0]+" "+es.threads.SubjectToken(ticketID))
+	}
+	root := "<ticket." + es.threads.tag(ticketID) + "@" + es.domain + ">"
+	if first {
+		m.SetHeader("Message-ID", root)
+	} else {
+		suffix := make([]byte, 8)
+		rand.Read(suffix)
+		m.SetHeader("Message-ID", "<ticket."+es.threads.tag(ticketID)+"."+hex.EncodeToString(suffix)+"@"+es.domain+">")
+		m.SetHeader("In-Reply-To", root)
+		m.SetHeader("References", root)
+	}
+	if replyTo := es.ticketReplyTo(ticketID); replyTo != "" {
+		m.SetHeader("Reply-To", replyTo)
+	}
+}
+
+// ticketReplyTo is REPLY_TO_ADDRESS tagged with the signed ticket ID, e.g.
+// support+ID.SIG@example.com, or empty when no reply address is configured
+func (es *EmailService) ticketReplyTo(ticketID string) string {
+	at := strings.LastIndex(es.replyTo, "@")
+	if at < 0 {
+		return ""
+	}
+	return es.replyTo[:at] + "+" + es.threads.tag(ticketID) + es.replyTo[at:]
+}
+
+// messageDomain picks the domain for generated Message-IDs
+func messageDomain(addresses ...string) string {
+	for _, address := range addresses {
+		if a, err := mail.ParseAddress(address); err == nil {
+			return a.Address[strings.LastIndex(a.Address, "@")+1:]
+		}
+	}
+	return "quickdesk.local"
+}
+
+// Threads signs the ticket IDs in generated Message-IDs and reply
+// addresses. Ticket IDs are no secret, so only a signed one shows that a
+// reply answers mail we sent rather than a guess.
+type Threads struct {
+	key []byte
+}
+
+// NewThreads derives the signing key from a server secret
+func NewThreads(secret string) Threads {
+	return Threads{key: []byte("quickdesk email threads:" + secret)}
+}
+
+// SubjectToken is the tag ticket mail carries in its subject, so replies
+// find their ticket even when mail clients drop In-Reply-To and the reply
+// address
+func (t Threads) SubjectToken(ticketID string) string {
+	return "[QD#" + t.tag(ticketID) + "]"
+}
+
+// tag is a ticket ID followed by its signature
+func (t Threads) tag(ticketID string) string {
+	return ticketID + "." + t.sign(ticketID)
+}
+
+func (t Threads) sign(ticketID string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(strings.ToLower(ticketID)))
+	return hex.EncodeToString(mac.Sum(nil))[:signatureLength]
+}
+
+const signatureLength = 16
+
+var (
+	ticketMessageIDPattern = regexp.MustCompile(`^ticket\.([0-9a-fA-F-]{36})(?:\.([0-9a-f]+))?(?:\.[0-9a-f]+)?@`)
+	ticketAddressPattern   = regexp.MustCompile(`\+([0-9a-fA-F-]{36})(?:\.([0-9a-f]+))?@`)
+	subjectTokenPattern    = regexp.MustCompile(`\[QD#([0-9a-fA-F-]{36})(?:\.([0-9a-f]+))?\]`)
+)
+
+// FromMessageID returns the ticket a Message-ID (without angle brackets)
+// generated for its conversation belongs to. signed is false for IDs
+// without a valid signature, such as those sent before IDs were signed.
+func (t Threads) FromMessageID(id string) (ticketID uuid.UUID, signed, ok bool) {
+	return t.ticketFrom(ticketMessageIDPattern, id)
+}
+
+// FromAddress returns the ticket a reply address belongs to, like
+// FromMessageID
+func (t Threads) FromAddress(address string) (ticketID uuid.UUID, signed, ok bool) {
+	return t.ticketFrom(ticketAddressPattern, address)
+}
+
+// FromSubject returns the ticket a subject token names, like FromMessageID
+func (t Threads) FromSubject(subject string) (ticketID uuid.UUID, signed, ok bool) {
+	return t.ticketFrom(subjectTokenPattern, subject)
+}
+
+func (t Threads) ticketFrom(pattern *regexp.Regexp, s string) (uuid.UUID, bool, bool) {
+	m := pattern.FindStringSubmatch(s)
+	if m == nil {
+		return uuid.Nil, false, false
+	}
+	id, err := uuid.Parse(m[1])
+	if err != nil {
+		return uuid.Nil, false, false
+	}
+	signed := len(m[2]) == signatureLength && hmac.Equal([]byte(m[2]), []byte(t.sign(id.String())))
+	return id, signed, true
+}
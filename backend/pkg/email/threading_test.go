@@ -0,0 +1,112 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"quickdesk-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestThreadHeaders(t *testing.T) {
+	transport := NewMemoryTransport()
+	es := NewWithTransport(nil, transport, "QuickDesk <noreply@example.com>", "support@example.com", "https://desk.example.com")
+	ticket := &models.Ticket{ID: uuid.New(), Subject: "Printer jam", Status: models.StatusOpen, Priority: models.PriorityHigh}
+	to := Recipient{Email: "requester@example.com", Name: "Requester"}
+
+	if err := es.SendTicketCreatedEmail(to, ticket); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.SendTicketUpdatedEmail(to, ticket); err != nil {
+		t.Fatal(err)
+	}
+	sent := transport.To("requester@example.com")
+	if len(sent) != 2 {
+		t.Fatalf("%d messages sent, want 2", len(sent))
+	}
+	first, second := sent[0].Header, sent[1].Header
+
+	root := first.Get("Message-ID")
+	if !strings.HasPrefix(root, "<ticket."+ticket.ID.String()+".") || !strings.HasSuffix(root, "@example.com>") {
+		t.Errorf("first Message-ID = %q", root)
+	}
+	if second.Get("In-Reply-To") != root || second.Get("References") != root {
+		t.Errorf("follow-up In-Reply-To = %q, References = %q, want %q", second.Get("In-Reply-To"), second.Get("References"), root)
+	}
+	if second.Get("Message-ID") == root {
+		t.Error("follow-up reuses the root Message-ID")
+	}
+	if first.Get("Auto-Submitted") != "auto-generated" {
+		t.Errorf("Auto-Submitted = %q", first.Get("Auto-Submitted"))
+	}
+
+	for _, h := range []string{root, second.Get("Message-ID")} {
+		id, signed, ok := es.threads.FromMessageID(strings.Trim(h, "<>"))
+		if !ok || !signed || id != ticket.ID {
+			t.Errorf("FromMessageID(%s) = %s, signed %v, ok %v; want the ticket, signed", h, id, signed, ok)
+		}
+	}
+	token := "[QD#" + ticket.ID.String() + "."
+	if subject := sent[0].Subject; !strings.Contains(subject, "Printer jam") || !strings.Contains(subject, token) {
+		t.Errorf("subject %q lacks the ticket's subject or token", subject)
+	}
+	if id, signed, ok := es.threads.FromSubject("Re: " + sent[1].Subject); !ok || !signed || id != ticket.ID {
+		t.Errorf("FromSubject(%s) = %s, signed %v, ok %v; want the ticket, signed", sent[1].Subject, id, signed, ok)
+	}
+	replyTo := first.Get("Reply-To")
+	if !strings.HasPrefix(replyTo, "support+"+ticket.ID.String()+".") || !strings.HasSuffix(replyTo, "@example.com") {
+		t.Errorf("Reply-To = %q", replyTo)
+	}
+	if id, signed, ok := es.threads.FromAddress(replyTo); !ok || !signed || id != ticket.ID {
+		t.Errorf("FromAddress(%s) = %s, signed %v, ok %v; want the ticket, signed", replyTo, id, signed, ok)
+	}
+}
+
+func TestThreadsRejectForgedTags(t *testing.T) {
+	threads := NewThreads("server secret")
+	ticketID := uuid.New()
+	other := uuid.New()
+	tag := threads.tag(ticketID.String())
+	sig := tag[strings.LastIndex(tag, ".")+1:]
+
+	if _, signed, _ := threads.FromAddress("support+" + tag + "@example.com"); !signed {
+		t.Fatal("a genuine reply address is not signed")
+	}
+	tests := []struct {
+		name, address string
+	}{
+		{"unsigned", "support+" + ticketID.String() + "@example.com"},
+		{"signed with another secret", "support+" + NewThreads("guess").tag(ticketID.String()) + "@example.com"},
+		{"signature of another ticket", "support+" + other.String() + "." + sig + "@example.com"},
+		{"truncated signature", "support+" + ticketID.String() + "." + sig[:8] + "@example.com"},
+	}
+	for _, tt := range tests {
+		id, signed, ok := threads.FromAddress(tt.address)
+		if !ok {
+			t.Errorf("%s: no ticket found in %s", tt.name, tt.address)
+		}
+		if signed {
+			t.Errorf("%s: %s (ticket %s) counts as signed", tt.name, tt.address, id)
+		}
+	}
+
+	forged := "Re: [QD#" + ticketID.String() + "." + NewThreads("guess").sign(ticketID.String()) + "] Printer jam"
+	if id, signed, ok := threads.FromSubject(forged); !ok || signed || id != ticketID {
+		t.Errorf("FromSubject(%s) = %s, signed %v, ok %v; want the ticket, unsigned", forged, id, signed, ok)
+	}
+
+	// Message-IDs sent before they were signed still name their ticket
+	legacy := "ticket." + ticketID.String() + ".0123456789abcdef@example.com"
+	if id, signed, ok := threads.FromMessageID(legacy); !ok || signed || id != ticketID {
+		t.Errorf("FromMessageID(%s) = %s, signed %v, ok %v; want the ticket, unsigned", legacy, id, signed, ok)
+	}
+	for _, s := range []string{"someone@example.com", "ticket.not-a-uuid@example.com", "support@example.com"} {
+		if _, _, ok := threads.FromMessageID(s); ok {
+			t.Errorf("FromMessageID(%s) found a ticket", s)
+		}
+		if _, _, ok := threads.FromAddress(s); ok {
+			t.Errorf("FromAddress(%s) found a ticket", s)
+		}
+	}
+}
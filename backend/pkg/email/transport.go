@@ -0,0 +1,141 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Transport hands a rendered RFC 5322 message to its recipients
+type Transport interface {
+	Send(from string, to []string, raw []byte) error
+}
+
+// SMTPTransport sends through an SMTP server, using STARTTLS when offered
+type SMTPTransport struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(from string, to []string, raw []byte) error {
+	s, err := gomail.NewDialer(t.Host, t.Port, t.Username, t.Password).Dial()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.Send(from, to, rawMessage(raw))
+}
+
+type rawMessage []byte
+
+func (r rawMessage) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(r)
+	return int64(n), err
+}
+
+// MaildirTransport drops messages into a maildir for local development;
+// any mail client that reads maildirs can open it. The envelope is kept in
+// X-Envelope-From and X-Envelope-To headers.
+type MaildirTransport struct {
+	Dir string
+}
+
+func (t *MaildirTransport) Send(from string, to []string, raw []byte) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(t.Dir, sub), 0o755); err != nil {
+			return err
+		}
+	}
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%s.%s", time.Now().UnixNano(), hex.EncodeToString(suffix), strings.ReplaceAll(host, "/", "_"))
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "X-Envelope-From: %s\r\nX-Envelope-To: %s\r\n", from, strings.Join(to, ", "))
+	message.Write(raw)
+
+	// Writing to tmp and renaming into new is how maildir delivery stays
+	// atomic for readers
+	tmp := filepath.Join(t.Dir, "tmp", name)
+	if err := os.WriteFile(tmp, message.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.Dir, "new", name))
+}
+
+var wordDecoder = new(mime.WordDecoder)
+
+// MemoryTransport keeps sent messages in memory so tests can inspect them
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []SentMessage
+}
+
+// SentMessage is a message captured by a MemoryTransport
+type SentMessage struct {
+	From    string
+	To      []string
+	Subject string
+	Header  mail.Header
+	Raw     []byte
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{}
+}
+
+func (t *MemoryTransport) Send(from string, to []string, raw []byte) error {
+	sent := SentMessage{From: from, To: append([]string(nil), to...), Raw: append([]byte(nil), raw...)}
+	if m, err := mail.ReadMessage(bytes.NewReader(raw)); err == nil {
+		sent.Header = m.Header
+		sent.Subject, _ = wordDecoder.DecodeHeader(m.Header.Get("Subject"))
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, sent)
+	return nil
+}
+
+// Messages returns every captured message in the order sent
+func (t *MemoryTransport) Messages() []SentMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SentMessage(nil), t.messages...)
+}
+
+// To returns the captured messages addressed to one recipient
+func (t *MemoryTransport) To(address string) []SentMessage {
+	var matched []SentMessage
+	for _, m := range t.Messages() {
+		for _, recipient := range m.To {
+			if strings.EqualFold(recipient, address) {
+				matched = append(matched, m)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// Reset forgets every captured message
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) WriteHeader(columns []string) error {
+	return cw.w.Write(columns)
+}
+
+func (cw *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = FormatValue(v)
+		if _, text := v.(string); text {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+
+	// Flush regularly so large exports reach the client as they are built
+	cw.rows++
+	if cw.rows%100 == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// escapeFormula keeps spreadsheets from running text that starts like a
+// formula, such as a subject of "=HYPERLINK(...)", by prefixing a quote.
+// Numbers and dates are not text and are written as they are.
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonWriter streams an array with one object per row, keyed by column
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	rows    int
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *jsonWriter) WriteHeader(columns []string) error {
+	jw.columns = columns
+	_, err := jw.w.WriteString("[")
+	return err
+}
+
+func (jw *jsonWriter) WriteRow(values []interface{}) error {
+	if jw.rows > 0 {
+		if _, err := jw.w.WriteString(",\n"); err != nil {
+			return err
+		}
+	}
+	jw.rows++
+
+	// Write keys in column order rather than map order
+	if _, err := jw.w.WriteString("{"); err != nil {
+		return err
+	}
+	for i, column := range jw.columns {
+		if i > 0 {
+			jw.w.WriteString(",")
+		}
+		key, _ := json.Marshal(column)
+		jw.w.Write(key)
+		jw.w.WriteString(":")
+
+		var v interface{}
+		if i < len(values) {
+			v = values[i]
+		}
+		if t, ok := v.(time.Time); ok && t.IsZero() {
+			v = nil
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := jw.w.Write(value); err != nil {
+			return err
+		}
+	}
+	_, err := jw.w.WriteString("}")
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	if jw.columns == nil {
+		jw.w.WriteString("[")
+	}
+	if _, err := jw.w.WriteString("]\n"); err != nil {
+		return err
+	}
+	return jw.w.Flush()
+}